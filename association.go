@@ -1,12 +1,20 @@
 package gorm
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
 
 	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
 	"gorm.io/gorm/schema"
 	"gorm.io/gorm/utils"
 )
@@ -16,331 +24,2446 @@ type Association struct {
 	DB           *DB
 	Relationship *schema.Relationship
 	Error        error
+	joinAttrs    map[string]interface{}
+	createdJoins *[]interface{}
+	joinConds    []clause.Expression
+	joinAlias    string
+	broadcast    bool
+	cascade      bool
+	indexHints   []string
+	withDeleted  bool
+	maxDetach    *int
+	hop          *Association // association of the intermediate relation, whose record is the owner of nested associations
+	operation    string       // operation tagging sessions of the association, see tag
 }
 
+// AssociationOperation association mode operation passed to association callbacks, operations delegating to others
+// report the delegated operations too, e.g: Append of has one relations reports replace and append
+type AssociationOperation struct {
+	Relation  string
+	Type      schema.RelationshipType
+	Operation string
+}
+
+// AssociationLink a link between an owner and a value about to be saved by association mode, passed to association link
+// callbacks, foreign keys of References are assigned after the callbacks, e.g: the value's foreign key for has many relations
+type AssociationLink struct {
+	Relation   string
+	Type       schema.RelationshipType
+	Owner      reflect.Value
+	Value      reflect.Value
+	References []*schema.Reference
+}
+
+// Association returns association mode of relation column, its operations are executed in db's transaction if there is one,
+// use DB.Transaction to edit several associations atomically, in dry run mode, SQL is generated without changing the owner,
+// column could be a dotted path to traverse has one or belongs to relations, e.g: db.Model(&user).Association("Company.Departments")
+//
+// Options chained before Association apply to finding associations:
+//   - preloads are applied to found associations, e.g: db.Model(&user).Preload("Departments").Association("Company")
+//   - distinct only applies to the associations's columns for many2many, e.g: db.Model(&user).Distinct().Association("Languages")
+//   - selected columns could be aggregated with clause.GroupBy in Find's conds, for many2many, each association is counted
+//     once per join record, e.g: db.Model(&user).Select("category", "count(*) AS total").Association("Roles")
+//   - set "gorm:association:record_not_found" to true to return ErrRecordNotFound if no has one, belongs to association is found
 func (db *DB) Association(column string) *Association {
-	association := &Association{DB: db}
-	table := db.Statement.Table
+	if strings.Contains(column, ".") {
+		return db.nestedAssociation(column)
+	}
+
+	var (
+		association = &Association{DB: db}
+		table       = db.Statement.Table
+		err         error
+	)
 
-	if err := db.Statement.Parse(db.Statement.Model); err == nil {
+	if db.Statement.Model == nil {
+		association.Error = &AssociationError{Relation: column, Err: fmt.Errorf("%w: use db.Model to set the owner of %v", ErrModelValueRequired, column)}
+	} else if db.Statement.Model, err = typedOwners(db.Statement.Model); err != nil {
+		association.Error = &AssociationError{Relation: column, Err: err}
+	} else if err = db.Statement.Parse(db.Statement.Model); err == nil {
 		db.Statement.Table = table
 		association.Relationship = db.Statement.Schema.Relationships.Relations[column]
 
 		if association.Relationship == nil {
-			association.Error = fmt.Errorf("%w: %v", ErrUnsupportedRelation, column)
+			association.Error = &AssociationError{Relation: column, Err: fmt.Errorf("%w: %v", ErrUnsupportedRelation, column)}
 		}
 
 		db.Statement.ReflectValue = reflect.ValueOf(db.Statement.Model)
 		for db.Statement.ReflectValue.Kind() == reflect.Ptr {
 			db.Statement.ReflectValue = db.Statement.ReflectValue.Elem()
 		}
+
+		if !db.Statement.ReflectValue.IsValid() && association.Error == nil {
+			association.Error = &AssociationError{Relation: column, Err: fmt.Errorf("%w: owner of %v is nil", ErrModelValueRequired, column)}
+		}
 	} else {
-		association.Error = err
+		association.Error = &AssociationError{Relation: column, Err: err}
 	}
 
 	return association
 }
 
-func (association *Association) Find(out interface{}, conds ...interface{}) error {
-	if association.Error == nil {
-		association.Error = association.buildCondition().Find(out, conds...).Error
+// typedOwners converts owners of an interface slice to a pointer to slice of their type, e.g: []interface{}{&user1, &user2}
+// to *[]*User, owners of different types are rejected as their relations couldn't be saved with one schema
+func typedOwners(model interface{}) (interface{}, error) {
+	reflectValue := reflect.Indirect(reflect.ValueOf(model))
+	if (reflectValue.Kind() != reflect.Slice && reflectValue.Kind() != reflect.Array) || reflectValue.Type().Elem().Kind() != reflect.Interface || reflectValue.Len() == 0 {
+		return model, nil
 	}
-	return association.Error
-}
 
-func (association *Association) Append(values ...interface{}) error {
-	if association.Error == nil {
-		switch association.Relationship.Type {
-		case schema.HasOne, schema.BelongsTo:
-			if len(values) > 0 {
-				association.Error = association.Replace(values...)
-			}
-		default:
-			association.saveAssociation( /*clear*/ false, values...)
+	var ownerType reflect.Type
+	for i := 0; i < reflectValue.Len(); i++ {
+		if owner := reflectValue.Index(i).Elem(); !owner.IsValid() {
+			return model, fmt.Errorf("%w: owner at index %d is nil", ErrInvalidData, i)
+		} else if ownerType == nil {
+			ownerType = owner.Type()
+		} else if owner.Type() != ownerType {
+			return model, fmt.Errorf("%w: owners should have the same type, but got %v at index 0 and %v at index %d", ErrInvalidData, ownerType, owner.Type(), i)
 		}
 	}
 
-	return association.Error
+	owners := reflect.New(reflect.SliceOf(ownerType))
+	owners.Elem().Set(reflect.MakeSlice(owners.Elem().Type(), reflectValue.Len(), reflectValue.Len()))
+	for i := 0; i < reflectValue.Len(); i++ {
+		owners.Elem().Index(i).Set(reflectValue.Index(i).Elem())
+	}
+	return owners.Interface(), nil
 }
 
-func (association *Association) Replace(values ...interface{}) error {
-	if association.Error == nil {
-		// save associations
-		association.saveAssociation( /*clear*/ true, values...)
+// nestedAssociation returns the association of the last relation in path, whose owner is the intermediate record of the
+// previous hop, queries select owners with subqueries of the hops, and writes load the owner first, hops in the middle
+// must be has one or belongs to relations of a single owner
+func (db *DB) nestedAssociation(path string) *Association {
+	names := strings.Split(path, ".")
+	association := db.Session(&Session{NewDB: true}).Model(db.Statement.Model).Association(names[0])
 
-		// set old associations's foreign key to null
-		reflectValue := association.DB.Statement.ReflectValue
-		rel := association.Relationship
-		switch rel.Type {
-		case schema.BelongsTo:
-			if len(values) == 0 {
-				updateMap := map[string]interface{}{}
-				switch reflectValue.Kind() {
-				case reflect.Slice, reflect.Array:
-					for i := 0; i < reflectValue.Len(); i++ {
-						association.Error = rel.Field.Set(reflectValue.Index(i), reflect.Zero(rel.Field.FieldType).Interface())
-					}
-				case reflect.Struct:
-					association.Error = rel.Field.Set(reflectValue, reflect.Zero(rel.Field.FieldType).Interface())
-				}
+	for idx, name := range names[1:] {
+		if association.Error != nil {
+			return &Association{DB: db, Error: association.Error}
+		}
 
-				for _, ref := range rel.References {
-					updateMap[ref.ForeignKey.DBName] = nil
-				}
+		if rel := association.Relationship; association.DB.Statement.ReflectValue.Kind() != reflect.Struct || (rel.Type != schema.HasOne && rel.Type != schema.BelongsTo) {
+			return &Association{DB: db, Error: &AssociationError{
+				Relation: path, Err: fmt.Errorf("%w: %v is ambiguous as it refers to many records", ErrUnsupportedRelation, names[idx]),
+			}}
+		}
 
-				association.Error = association.DB.UpdateColumns(updateMap).Error
-			}
-		case schema.HasOne, schema.HasMany:
-			var (
-				primaryFields []*schema.Field
-				foreignKeys   []string
-				updateMap     = map[string]interface{}{}
-				relValues     = schema.GetRelationsValues(reflectValue, []*schema.Relationship{rel})
-				modelValue    = reflect.New(rel.FieldSchema.ModelType).Interface()
-				tx            = association.DB.Model(modelValue)
-			)
+		tx := db.Session(&Session{NewDB: true})
+		if idx == len(names)-2 {
+			tx = db.Session(&Session{})
+		}
 
-			if _, rvs := schema.GetIdentityFieldValuesMap(relValues, rel.FieldSchema.PrimaryFields); len(rvs) > 0 {
-				if column, values := schema.ToQueryValues(rel.FieldSchema.Table, rel.FieldSchema.PrimaryFieldDBNames, rvs); len(values) > 0 {
-					tx.Not(clause.IN{Column: column, Values: values})
-				}
-			}
+		hop := association
+		association = tx.Model(reflect.New(hop.Relationship.FieldSchema.ModelType).Interface()).Association(name)
+		association.hop = hop
+	}
+	return association
+}
 
-			for _, ref := range rel.References {
-				if ref.OwnPrimaryKey {
-					primaryFields = append(primaryFields, ref.PrimaryKey)
-					foreignKeys = append(foreignKeys, ref.ForeignKey.DBName)
-					updateMap[ref.ForeignKey.DBName] = nil
-				} else if ref.PrimaryValue != "" {
-					tx.Where(clause.Eq{Column: ref.ForeignKey.DBName, Value: ref.PrimaryValue})
-				}
-			}
+// ReplaceAllAssociations replaces all associations of model with its relation fields in a transaction, relations with
+// empty fields are cleared, belongs to relations are replaced first so the owner's foreign keys refer to saved records,
+// then has one, has many and many2many relations, whose foreign keys refer to the owner,
+// e.g: db.ReplaceAllAssociations(&user) saves user's Company, Profile, Orders and Roles as they are
+func (db *DB) ReplaceAllAssociations(model interface{}) error {
+	stmt := &Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
 
-			if _, pvs := schema.GetIdentityFieldValuesMap(reflectValue, primaryFields); len(pvs) > 0 {
-				column, values := schema.ToQueryValues(rel.FieldSchema.Table, foreignKeys, pvs)
-				association.Error = tx.Where(clause.IN{Column: column, Values: values}).UpdateColumns(updateMap).Error
-			}
-		case schema.Many2Many:
+	reflectValue := reflect.ValueOf(model)
+	if reflectValue.Kind() != reflect.Ptr || reflectValue.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%w: replace all associations of %T, it should be a pointer to struct", ErrInvalidData, model)
+	}
+	reflectValue = reflectValue.Elem()
+
+	var rels []*schema.Relationship
+	rels = append(rels, stmt.Schema.Relationships.BelongsTo...)
+	rels = append(rels, stmt.Schema.Relationships.HasOne...)
+	rels = append(rels, stmt.Schema.Relationships.HasMany...)
+	rels = append(rels, stmt.Schema.Relationships.Many2Many...)
+
+	return db.Transaction(func(tx *DB) error {
+		for _, rel := range rels {
 			var (
-				primaryFields, relPrimaryFields     []*schema.Field
-				joinPrimaryKeys, joinRelPrimaryKeys []string
-				modelValue                          = reflect.New(rel.JoinTable.ModelType).Interface()
-				tx                                  = association.DB.Model(modelValue)
+				values     []interface{}
+				fieldValue = rel.Field.ReflectValueOf(reflectValue)
 			)
 
-			for _, ref := range rel.References {
-				if ref.PrimaryValue == "" {
-					if ref.OwnPrimaryKey {
-						primaryFields = append(primaryFields, ref.PrimaryKey)
-						joinPrimaryKeys = append(joinPrimaryKeys, ref.ForeignKey.DBName)
-					} else {
-						relPrimaryFields = append(relPrimaryFields, ref.PrimaryKey)
-						joinRelPrimaryKeys = append(joinRelPrimaryKeys, ref.ForeignKey.DBName)
-					}
-				} else {
-					tx.Clauses(clause.Eq{Column: ref.ForeignKey.DBName, Value: ref.PrimaryValue})
+			switch fieldValue.Kind() {
+			case reflect.Ptr:
+				if !fieldValue.IsNil() {
+					values = append(values, fieldValue.Interface())
+				}
+			case reflect.Slice:
+				if fieldValue.Len() > 0 {
+					values = append(values, fieldValue.Addr().Interface())
+				}
+			default:
+				if !fieldValue.IsZero() {
+					values = append(values, fieldValue.Addr().Interface())
 				}
 			}
 
-			_, pvs := schema.GetIdentityFieldValuesMap(reflectValue, primaryFields)
-			if column, values := schema.ToQueryValues(rel.JoinTable.Table, joinPrimaryKeys, pvs); len(values) > 0 {
-				tx.Where(clause.IN{Column: column, Values: values})
-			} else {
-				return ErrPrimaryKeyRequired
-			}
-
-			_, rvs := schema.GetIdentityFieldValuesMapFromValues(values, relPrimaryFields)
-			if relColumn, relValues := schema.ToQueryValues(rel.JoinTable.Table, joinRelPrimaryKeys, rvs); len(relValues) > 0 {
-				tx.Where(clause.Not(clause.IN{Column: relColumn, Values: relValues}))
+			if err := tx.Model(model).Association(rel.Name).Replace(values...); err != nil {
+				return err
 			}
-
-			association.Error = tx.Delete(modelValue).Error
 		}
-	}
-	return association.Error
+		return nil
+	})
 }
 
-func (association *Association) Delete(values ...interface{}) error {
-	if association.Error == nil {
-		var (
-			reflectValue  = association.DB.Statement.ReflectValue
-			rel           = association.Relationship
-			primaryFields []*schema.Field
-			foreignKeys   []string
-			updateAttrs   = map[string]interface{}{}
-			conds         []clause.Expression
-		)
+// Use switches the association to the relation column of the same owner in place, resets its error and options set for
+// the previous relation, e.g: Broadcast, JoinWhere, so generic code could reuse one association for all relations of the
+// owner without parsing the owner again, e.g: for _, rel := range rels { association.Use(rel.Name).Count() }
+func (association *Association) Use(column string) *Association {
+	stmt := association.DB.Statement
+	if strings.Contains(column, ".") || stmt.Schema == nil || !stmt.ReflectValue.IsValid() {
+		*association = *association.DB.Association(column)
+		return association
+	}
 
-		for _, ref := range rel.References {
-			if ref.PrimaryValue == "" {
-				primaryFields = append(primaryFields, ref.PrimaryKey)
-				foreignKeys = append(foreignKeys, ref.ForeignKey.DBName)
-				updateAttrs[ref.ForeignKey.DBName] = nil
-			} else {
-				conds = append(conds, clause.Eq{Column: ref.ForeignKey.DBName, Value: ref.PrimaryValue})
-			}
-		}
+	*association = Association{DB: association.DB, Relationship: stmt.Schema.Relationships.Relations[column], hop: association.hop}
+	if association.Relationship == nil {
+		association.Error = &AssociationError{Relation: column, Err: fmt.Errorf("%w: %v", ErrUnsupportedRelation, column)}
+	}
+	return association
+}
 
-		switch rel.Type {
-		case schema.BelongsTo:
-			tx := association.DB.Model(reflect.New(rel.Schema.ModelType).Interface())
+// clone returns a copy of the association keeping all its options, which is modified by options returning new associations
+func (association *Association) clone() *Association {
+	newAssociation := *association
+	return &newAssociation
+}
 
-			_, pvs := schema.GetIdentityFieldValuesMap(reflectValue, rel.Schema.PrimaryFields)
-			pcolumn, pvalues := schema.ToQueryValues(rel.Schema.Table, rel.Schema.PrimaryFieldDBNames, pvs)
-			conds = append(conds, clause.IN{Column: pcolumn, Values: pvalues})
+// WithContext returns a new association whose operations are executed with ctx
+func (association *Association) WithContext(ctx context.Context) *Association {
+	newAssociation := association.clone()
+	newAssociation.DB = association.DB.WithContext(ctx)
+	return newAssociation
+}
 
-			_, rvs := schema.GetIdentityFieldValuesMapFromValues(values, primaryFields)
-			relColumn, relValues := schema.ToQueryValues(rel.Schema.Table, foreignKeys, rvs)
-			conds = append(conds, clause.IN{Column: relColumn, Values: relValues})
+// Unscoped returns a new association that ignores soft delete, join records will be deleted permanently when detaching associations
+func (association *Association) Unscoped() *Association {
+	newAssociation := association.clone()
+	newAssociation.DB = association.DB.Session(&Session{}).Unscoped()
+	return newAssociation
+}
 
-			association.Error = tx.Clauses(conds...).UpdateColumns(updateAttrs).Error
-		case schema.HasOne, schema.HasMany:
-			tx := association.DB.Model(reflect.New(rel.FieldSchema.ModelType).Interface())
+// Broadcast returns a new association that appends or replaces all values for each owner of a slice owner,
+// instead of assigning values to owners one by one, only many2many associations could be shared by owners
+func (association *Association) Broadcast() *Association {
+	newAssociation := association.clone()
+	newAssociation.broadcast = true
+	if newAssociation.Error == nil && association.Relationship.Type != schema.Many2Many {
+		newAssociation.Error = fmt.Errorf("%w: broadcast values for %v", ErrUnsupportedRelation, association.Relationship.Name)
+	}
+	return newAssociation
+}
 
-			_, pvs := schema.GetIdentityFieldValuesMap(reflectValue, primaryFields)
-			pcolumn, pvalues := schema.ToQueryValues(rel.FieldSchema.Table, foreignKeys, pvs)
-			conds = append(conds, clause.IN{Column: pcolumn, Values: pvalues})
+// Cascade returns a new association whose Delete deletes detached has one/has many records instead of clearing their
+// foreign keys, nested has one/has many records of deleted records are deleted recursively and their many2many join
+// records are deleted too, e.g: deleting orders with their order items, records are soft deleted unless it's Unscoped
+func (association *Association) Cascade() *Association {
+	newAssociation := association.clone()
+	newAssociation.cascade = true
+	if newAssociation.Error == nil && association.Relationship.Type != schema.HasOne && association.Relationship.Type != schema.HasMany {
+		newAssociation.Error = fmt.Errorf("%w: cascade delete for %v", ErrUnsupportedRelation, association.Relationship.Name)
+	}
+	return newAssociation
+}
 
-			_, rvs := schema.GetIdentityFieldValuesMapFromValues(values, rel.FieldSchema.PrimaryFields)
-			relColumn, relValues := schema.ToQueryValues(rel.FieldSchema.Table, rel.FieldSchema.PrimaryFieldDBNames, rvs)
-			conds = append(conds, clause.IN{Column: relColumn, Values: relValues})
+// JoinWhere returns a new association with conditions on the many2many join table, which are applied when finding, counting,
+// replacing and deleting associations, the conditions are merged with the relation's own join table conditions
+func (association *Association) JoinWhere(query interface{}, args ...interface{}) *Association {
+	newAssociation := association.clone()
+	if newAssociation.Error != nil {
+		return newAssociation
+	}
 
-			association.Error = tx.Clauses(conds...).UpdateColumns(updateAttrs).Error
-		case schema.Many2Many:
-			var (
-				primaryFields, relPrimaryFields     []*schema.Field
-				joinPrimaryKeys, joinRelPrimaryKeys []string
-				joinValue                           = reflect.New(rel.JoinTable.ModelType).Interface()
-			)
+	joinTable := association.Relationship.JoinTable
+	if joinTable == nil {
+		newAssociation.Error = fmt.Errorf("%w: join conditions for %v", ErrUnsupportedRelation, association.Relationship.Name)
+		return newAssociation
+	}
 
-			for _, ref := range rel.References {
-				if ref.PrimaryValue == "" {
-					if ref.OwnPrimaryKey {
-						primaryFields = append(primaryFields, ref.PrimaryKey)
-						joinPrimaryKeys = append(joinPrimaryKeys, ref.ForeignKey.DBName)
-					} else {
-						relPrimaryFields = append(relPrimaryFields, ref.PrimaryKey)
-						joinRelPrimaryKeys = append(joinRelPrimaryKeys, ref.ForeignKey.DBName)
-					}
-				} else {
-					conds = append(conds, clause.Eq{Column: ref.ForeignKey.DBName, Value: ref.PrimaryValue})
+	// qualify columns with the join table, as the join table is joined with the associations's table when finding
+	qualify := func(column interface{}) interface{} {
+		switch c := column.(type) {
+		case string:
+			if !strings.Contains(c, ".") {
+				if field := joinTable.LookUpField(c); field != nil {
+					c = field.DBName
 				}
+				return clause.Column{Table: joinTable.Table, Name: c}
+			}
+		case clause.Column:
+			if c.Table == clause.CurrentTable {
+				c.Table = joinTable.Table
 			}
+			return c
+		}
+		return column
+	}
 
-			_, pvs := schema.GetIdentityFieldValuesMap(reflectValue, primaryFields)
-			pcolumn, pvalues := schema.ToQueryValues(rel.JoinTable.Table, joinPrimaryKeys, pvs)
-			conds = append(conds, clause.IN{Column: pcolumn, Values: pvalues})
+	conds := association.DB.Statement.BuildCondition(query, args...)
+	for idx, cond := range conds {
+		switch c := cond.(type) {
+		case clause.Eq:
+			c.Column = qualify(c.Column)
+			conds[idx] = c
+		case clause.IN:
+			c.Column = qualify(c.Column)
+			conds[idx] = c
+		}
+	}
 
-			_, rvs := schema.GetIdentityFieldValuesMapFromValues(values, relPrimaryFields)
-			relColumn, relValues := schema.ToQueryValues(rel.JoinTable.Table, joinRelPrimaryKeys, rvs)
-			conds = append(conds, clause.IN{Column: relColumn, Values: relValues})
+	newAssociation.joinConds = append(append(make([]clause.Expression, 0, len(association.joinConds)+len(conds)), association.joinConds...), conds...)
+	return newAssociation
+}
 
-			association.Error = association.DB.Where(clause.Where{Exprs: conds}).Model(nil).Delete(joinValue).Error
-		}
+// JoinAlias returns a new association that joins the many2many join table with alias when querying associations,
+// so the query could be composed with other queries using the same join table, e.g: self-referential relations
+func (association *Association) JoinAlias(alias string) *Association {
+	newAssociation := association.clone()
+	newAssociation.joinAlias = alias
+	if newAssociation.Error == nil && association.Relationship.JoinTable == nil {
+		newAssociation.Error = fmt.Errorf("%w: join table alias for %v", ErrUnsupportedRelation, association.Relationship.Name)
+	}
+	return newAssociation
+}
 
-		if association.Error == nil {
-			// clean up deleted values's foreign key
-			relValuesMap, _ := schema.GetIdentityFieldValuesMapFromValues(values, rel.FieldSchema.PrimaryFields)
+// WithDeleted returns a new association whose queries (e.g: Find, Count) include soft deleted associations, unlike Unscoped,
+// soft deleted join records are still excluded, and writes like Replace, Delete keep soft deleting join records
+func (association *Association) WithDeleted() *Association {
+	newAssociation := association.clone()
+	newAssociation.withDeleted = true
+	return newAssociation
+}
 
-			cleanUpDeletedRelations := func(data reflect.Value) {
-				if _, zero := rel.Field.ValueOf(data); !zero {
-					fieldValue := reflect.Indirect(rel.Field.ReflectValueOf(data))
-					primaryValues := make([]interface{}, len(rel.FieldSchema.PrimaryFields))
+// MaxDetach returns a new association whose Replace detaches at most n current associations missing from the new ones,
+// Replace counts them before writing anything and returns ErrTooManyDetached if there are more, e.g: replacing with an
+// empty set on db.Model(&user).Association("Pets").MaxDetach(0) fails instead of detaching all pets
+func (association *Association) MaxDetach(n int) *Association {
+	newAssociation := association.clone()
+	newAssociation.maxDetach = &n
+	return newAssociation
+}
 
-					switch fieldValue.Kind() {
-					case reflect.Slice, reflect.Array:
-						validFieldValues := reflect.Zero(rel.Field.IndirectFieldType)
-						for i := 0; i < fieldValue.Len(); i++ {
-							for idx, field := range rel.FieldSchema.PrimaryFields {
-								primaryValues[idx], _ = field.ValueOf(fieldValue.Index(i))
-							}
+// IndexHint returns a new association whose queries hint the database to use indexes, the hint is applied to the
+// associations's table, or the join table for many2many relations, e.g: USE INDEX (`idx_user_speaks_user_id`),
+// it's ignored by dialects without index hints, which are only supported by mysql for now
+func (association *Association) IndexHint(indexes ...string) *Association {
+	newAssociation := association.clone()
+	newAssociation.indexHints = indexes
+	return newAssociation
+}
 
-							if _, ok := relValuesMap[utils.ToStringKey(primaryValues...)]; !ok {
-								validFieldValues = reflect.Append(validFieldValues, fieldValue.Index(i))
-							}
-						}
+// Active returns a new association only with join records whose time window contains at, fromColumn and toColumn are
+// the join table's columns of the window, both bounds are inclusive and a NULL toColumn means the window is still open
+func (association *Association) Active(at time.Time, fromColumn, toColumn string) *Association {
+	if association.Error != nil || association.Relationship.JoinTable == nil {
+		return association.JoinWhere(nil)
+	}
 
-						association.Error = rel.Field.Set(data, validFieldValues.Interface())
-					case reflect.Struct:
-						for idx, field := range rel.FieldSchema.PrimaryFields {
-							primaryValues[idx], _ = field.ValueOf(fieldValue)
-						}
+	column := func(name string) clause.Column {
+		if field := association.Relationship.JoinTable.LookUpField(name); field != nil {
+			name = field.DBName
+		}
+		return clause.Column{Table: association.Relationship.JoinTable.Table, Name: name}
+	}
 
-						if _, ok := relValuesMap[utils.ToStringKey(primaryValues...)]; ok {
-							if association.Error = rel.Field.Set(data, reflect.Zero(rel.FieldSchema.ModelType).Interface()); association.Error != nil {
-								break
-							}
+	return association.JoinWhere(clause.And(
+		clause.Lte{Column: column(fromColumn), Value: at},
+		clause.Or(clause.Eq{Column: column(toColumn), Value: nil}, clause.Gte{Column: column(toColumn), Value: at}),
+	))
+}
 
-							if rel.JoinTable == nil {
-								for _, ref := range rel.References {
-									if ref.OwnPrimaryKey || ref.PrimaryValue != "" {
-										association.Error = ref.ForeignKey.Set(fieldValue, reflect.Zero(ref.ForeignKey.FieldType).Interface())
-									} else {
-										association.Error = ref.ForeignKey.Set(data, reflect.Zero(ref.ForeignKey.FieldType).Interface())
-									}
-								}
-							}
-						}
-					}
-				}
-			}
+// Find find associations into out, which could be other structs than the associations's model whose fields are assigned
+// by column names, clauses in conds (e.g: clause.OrderBy, clause.GroupBy, subqueries) are added to the query, their
+// columns refer to the associations's table
+func (association *Association) Find(out interface{}, conds ...interface{}) error {
+	association.tag("find")
+	if association.Error == nil {
+		if rv := reflect.Indirect(reflect.ValueOf(out)); association.IsCollection() && rv.Kind() == reflect.Struct {
+			association.Error = fmt.Errorf("%w: %v is a collection, find it with a pointer to slice, but got %T", ErrInvalidData, association.Relationship.Name, out)
+			return association.wrapError("find")
+		}
 
-			switch reflectValue.Kind() {
-			case reflect.Slice, reflect.Array:
-				for i := 0; i < reflectValue.Len(); i++ {
-					cleanUpDeletedRelations(reflect.Indirect(reflectValue.Index(i)))
-				}
-			case reflect.Struct:
-				cleanUpDeletedRelations(reflectValue)
+		tx, queryConds := association.buildCondition().splitClauses(conds)
+		result := association.qualifySelects(association.qualifyGroupBy(tx)).Find(out, association.qualifyConds(tx, queryConds)...)
+		if association.Error = result.Error; association.Error == nil && result.RowsAffected == 0 && !association.IsCollection() {
+			if notFound, ok := association.DB.Get("gorm:association:record_not_found"); ok && notFound == true {
+				association.Error = ErrRecordNotFound
 			}
 		}
 	}
-
-	return association.Error
+	return association.wrapError("find")
 }
 
-func (association *Association) Clear() error {
-	return association.Replace()
+// Reload refreshes the owner's relation field with current associations, e.g: picks up values assigned by database defaults
+// or triggers after Append, a missing has one/belongs to association clears the field, owners of a slice are reloaded one by one
+func (association *Association) Reload() error {
+	association.tag("reload")
+	if association.Error == nil {
+		association.Error = association.checkAddressableOwner()
+	}
+
+	if association.Error == nil && !association.DB.DryRun {
+		switch reflectValue := association.DB.Statement.ReflectValue; reflectValue.Kind() {
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < reflectValue.Len() && association.Error == nil; i++ {
+				association.Error = association.reload(reflect.Indirect(reflectValue.Index(i)))
+			}
+		case reflect.Struct:
+			association.Error = association.reload(reflectValue)
+		}
+	}
+	return association.wrapError("reload")
 }
 
-func (association *Association) Count() (count int64) {
-	if association.Error == nil {
-		association.Error = association.buildCondition().Count(&count).Error
+func (association *Association) reload(owner reflect.Value) error {
+	rel := association.Relationship
+	if !owner.CanAddr() {
+		return fmt.Errorf("%w: owner of %v should be addressable, but got %v", ErrInvalidData, rel.Name, owner.Type())
 	}
-	return
+
+	finder := association.newSession().Model(owner.Addr().Interface()).Association(rel.Name)
+	finder.Relationship, finder.joinConds, finder.joinAlias = rel, association.joinConds, association.joinAlias
+	finder.DB.Statement.Unscoped = association.DB.Statement.Unscoped
+
+	if association.IsCollection() {
+		values := reflect.New(rel.Field.IndirectFieldType)
+		if err := finder.Find(values.Interface()); err != nil {
+			return err
+		}
+		return rel.Field.Set(owner, values.Elem().Interface())
+	}
+
+	values := reflect.New(reflect.SliceOf(reflect.PtrTo(rel.FieldSchema.ModelType)))
+	if err := finder.Find(values.Interface()); err != nil {
+		return err
+	} else if values.Elem().Len() == 0 {
+		return rel.Field.Set(owner, reflect.Zero(rel.Field.FieldType).Interface())
+	}
+	return rel.Field.Set(owner, values.Elem().Index(0).Interface())
 }
 
-type assignBack struct {
-	Source reflect.Value
-	Index  int
-	Dest   reflect.Value
+// FindInBatches find associations in batches of batchSize ordered by the associations's primary key, fc is called for each batch,
+// e.g: db.Model(&user).Association("Orders").FindInBatches(&orders, 500, func(tx *gorm.DB, batch int) error { ... })
+func (association *Association) FindInBatches(dest interface{}, batchSize int, fc func(tx *DB, batch int) error) error {
+	association.tag("find")
+	if association.Error == nil {
+		association.Error = association.qualifySelects(association.buildCondition()).FindInBatches(dest, batchSize, fc).Error
+	}
+	return association.wrapError("find")
 }
 
-func (association *Association) saveAssociation(clear bool, values ...interface{}) {
+// FindChan streams associations found with the same conditions as Find over the returned channel, each value is a pointer
+// to a newly allocated struct of the associations's model, the channel is buffered with bufferSize, once it's full,
+// scanning rows is blocked until the receiver catches up, so the connection is held until all values are received or
+// ctx is done. Both channels are closed after the last row, an error or ctx's cancellation, the error channel receives
+// at most one error, drain values before reading it, e.g: values, errs := db.Model(&user).Association("Orders").FindChan(ctx, 100)
+func (association *Association) FindChan(ctx context.Context, bufferSize int) (<-chan interface{}, <-chan error) {
 	var (
-		reflectValue = association.DB.Statement.ReflectValue
-		assignBacks  []assignBack // assign association values back to arguments after save
+		values = make(chan interface{}, bufferSize)
+		errs   = make(chan error, 1)
+		rows   *sql.Rows
+		tx     = association.WithContext(ctx)
 	)
 
-	appendToRelations := func(source, rv reflect.Value, clear bool) {
-		switch association.Relationship.Type {
-		case schema.HasOne, schema.BelongsTo:
-			switch rv.Kind() {
-			case reflect.Slice, reflect.Array:
-				if rv.Len() > 0 {
-					association.Error = association.Relationship.Field.Set(source, rv.Index(0).Addr().Interface())
+	tx.tag("find")
+	if tx.Error == nil {
+		rows, tx.Error = tx.qualifySelects(tx.buildCondition()).Rows()
+	}
 
-					if association.Relationship.Field.FieldType.Kind() == reflect.Struct {
-						assignBacks = append(assignBacks, assignBack{Source: source, Dest: rv.Index(0)})
-					}
-				}
-			case reflect.Struct:
-				association.Error = association.Relationship.Field.Set(source, rv.Addr().Interface())
+	if err := tx.wrapError("find"); err != nil {
+		errs <- err
+		close(values)
+		close(errs)
+		return values, errs
+	}
 
-				if association.Relationship.Field.FieldType.Kind() == reflect.Struct {
-					assignBacks = append(assignBacks, assignBack{Source: source, Dest: rv})
-				}
-			}
+	go func() {
+		defer close(errs)
+		defer close(values)
+		defer rows.Close()
+
+		var (
+			rel     = tx.Relationship
+			scanner = tx.DB.Session(&Session{NewDB: true})
+			sendErr = func(err error) { errs <- &AssociationError{Relation: rel.Name, Operation: "find", Err: err} }
+		)
+
+		for rows.Next() {
+			// select picks randomly when both cases are ready, check ctx first to stop sending once it's done
+			if err := ctx.Err(); err != nil {
+				sendErr(err)
+				return
+			}
+
+			value := reflect.New(rel.FieldSchema.ModelType).Interface()
+			if err := scanner.ScanRows(rows, value); err != nil {
+				sendErr(err)
+				return
+			}
+
+			select {
+			case values <- value:
+			case <-ctx.Done():
+				sendErr(ctx.Err())
+				return
+			}
+		}
+
+		if err := rows.Err(); err != nil {
+			sendErr(err)
+		}
+	}()
+
+	return values, errs
+}
+
+// IsCollection returns true for has many, many2many associations, whose records should be found with a slice,
+// returns false for has one, belongs to associations, whose record could be found with a struct
+func (association *Association) IsCollection() bool {
+	if association.Relationship == nil {
+		return false
+	}
+	switch association.Relationship.Type {
+	case schema.HasMany, schema.Many2Many, schema.Many2ManyJSON, schema.HasManyThrough:
+		return true
+	}
+	return false
+}
+
+// First find the first association ordered by orders chained before Association and then the primary key,
+// returns ErrRecordNotFound if there are no associations, e.g: db.Model(&user).Order("created_at desc").Association("Pets").First(&pet)
+func (association *Association) First(out interface{}, conds ...interface{}) error {
+	association.tag("first")
+	if association.Error == nil {
+		tx, queryConds := association.buildCondition().splitClauses(conds)
+		association.Error = association.qualifySelects(tx).First(out, association.qualifyConds(tx, queryConds)...).Error
+	}
+	return association.wrapError("first")
+}
+
+// Pluck queries a single column of associations into dest, which should be a pointer to a slice,
+// the column refers to the associations's table if it's a field of the associations
+func (association *Association) Pluck(column string, dest interface{}) error {
+	association.tag("pluck")
+	if association.Error == nil {
+		if rv := reflect.ValueOf(dest); rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+			association.Error = fmt.Errorf("%w: pluck destination should be a pointer to slice, but got %T", ErrInvalidData, dest)
+		} else {
+			tx := association.buildCondition()
+			if field := association.Relationship.FieldSchema.LookUpField(column); field != nil {
+				// qualify the column to avoid ambiguous columns with the join table
+				tx.Statement.AddClause(clause.Select{
+					Distinct: tx.Statement.Distinct,
+					Columns:  []clause.Column{{Table: association.Relationship.FieldSchema.Table, Name: field.DBName}},
+				})
+			}
+			association.Error = tx.Pluck(column, dest).Error
+		}
+	}
+	return association.wrapError("pluck")
+}
+
+// FindWithJoin find many2many associations into out and their join table records into joinOut, the n-th join record
+// belongs to the n-th association, joinOut's element type is mapped to the join table by field names
+func (association *Association) FindWithJoin(out interface{}, joinOut interface{}, conds ...interface{}) error {
+	association.tag("find")
+	if association.Error == nil {
+		association.Error = association.findWithJoin(out, joinOut, conds...)
+	}
+	return association.wrapError("find")
+}
+
+func (association *Association) findWithJoin(out interface{}, joinOut interface{}, conds ...interface{}) error {
+	var (
+		rel          = association.Relationship
+		reflectValue = association.DB.Statement.ReflectValue
+		joinValue    = reflect.ValueOf(joinOut)
+	)
+
+	if rel.JoinTable == nil {
+		return fmt.Errorf("%w: join records for %v", ErrUnsupportedRelation, rel.Name)
+	}
+
+	if reflectValue.Kind() != reflect.Struct {
+		return fmt.Errorf("%w: join records can only be found for a single owner", ErrInvalidData)
+	}
+
+	if joinValue.Kind() != reflect.Ptr || joinValue.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("%w: join records should be found into a pointer of slice, but got %v", ErrInvalidData, joinValue.Type())
+	}
+
+	joinSchema, err := schema.Parse(joinOut, association.DB.cacheStore, association.DB.NamingStrategy)
+	if err != nil {
+		return err
+	}
+
+	if err := association.Find(out, conds...); err != nil {
+		return err
+	}
+
+	var (
+		elems                            = addressableValues(out)
+		joinConds                        = append([]clause.Expression{}, association.joinConds...)
+		primaryFields, relPrimaryFields  []*schema.Field
+		joinPrimaryKeys, joinForeignKeys []string
+		joinForeignFields                []*schema.Field
+	)
+
+	for _, ref := range rel.References {
+		if ref.PrimaryValue != "" {
+			joinConds = append(joinConds, clause.Eq{Column: clause.Column{Table: rel.JoinTable.Table, Name: ref.ForeignKey.DBName}, Value: ref.PrimaryValue})
+		} else if ref.OwnPrimaryKey {
+			primaryFields = append(primaryFields, ref.PrimaryKey)
+			joinPrimaryKeys = append(joinPrimaryKeys, ref.ForeignKey.DBName)
+		} else {
+			field := joinSchema.LookUpField(ref.ForeignKey.DBName)
+			if field == nil {
+				return fmt.Errorf("%w: %v for join records of %v", ErrInvalidField, ref.ForeignKey.DBName, rel.Name)
+			}
+			relPrimaryFields = append(relPrimaryFields, ref.PrimaryKey)
+			joinForeignKeys = append(joinForeignKeys, ref.ForeignKey.DBName)
+			joinForeignFields = append(joinForeignFields, field)
+		}
+	}
+
+	joinRecords := reflect.MakeSlice(joinValue.Elem().Type(), 0, len(elems))
+	if len(elems) > 0 {
+		_, pvs := schema.GetIdentityFieldValuesMap(reflectValue, primaryFields)
+		column, values := schema.ToQueryValues(rel.JoinTable.Table, joinPrimaryKeys, pvs)
+		joinConds = append(joinConds, clause.IN{Column: column, Values: values})
+
+		_, rvs := schema.GetIdentityFieldValuesMapFromValues([]interface{}{out}, relPrimaryFields)
+		relColumn, relValues := schema.ToQueryValues(rel.JoinTable.Table, joinForeignKeys, rvs)
+		joinConds = append(joinConds, clause.IN{Column: relColumn, Values: relValues})
+
+		results := reflect.New(joinValue.Elem().Type())
+		if err := association.newSession().Table(rel.JoinTable.Table).Where(clause.Where{Exprs: joinConds}).Find(results.Interface()).Error; err != nil {
+			return err
+		}
+
+		// pair join records with associations by foreign keys
+		var (
+			resultsValue = results.Elem()
+			joinMap      = make(map[string]reflect.Value, resultsValue.Len())
+			joinKey      = func(rv reflect.Value, fields []*schema.Field) string {
+				fieldValues := make([]interface{}, len(fields))
+				for idx, field := range fields {
+					fieldValues[idx], _ = field.ValueOf(rv)
+				}
+				return utils.ToStringKey(fieldValues...)
+			}
+		)
+
+		for i := 0; i < resultsValue.Len(); i++ {
+			joinMap[joinKey(reflect.Indirect(resultsValue.Index(i)), joinForeignFields)] = resultsValue.Index(i)
+		}
+
+		for _, elem := range elems {
+			if record, ok := joinMap[joinKey(elem, relPrimaryFields)]; ok {
+				joinRecords = reflect.Append(joinRecords, record)
+			} else {
+				joinRecords = reflect.Append(joinRecords, reflect.Zero(joinRecords.Type().Elem()))
+			}
+		}
+	}
+
+	joinValue.Elem().Set(joinRecords)
+	return nil
+}
+
+// Append append new associations for many2many, has many, replace current association for has one, belongs to
+// associations are created with their hooks while updating the owner, after the owner's BeforeSave, BeforeUpdate hooks
+// and before its AfterUpdate, AfterSave hooks, owners without primary keys are created with their create hooks like DB.Save,
+// has many associations could be appended with maps of their fields or columns,
+// e.g: db.Model(&user).Association("Pets").Append(map[string]interface{}{"Name": "pet"}), the maps aren't changed,
+// set "gorm:association:skip_owner_save" to true to save has many associations without updating owners with primary keys
+func (association *Association) Append(values ...interface{}) error {
+	association.tag("append")
+	if association.Error == nil {
+		association.Error = association.checkWritable()
+	}
+	if association.Error == nil {
+		if association.keepsFields() {
+			defer association.restoreFieldsOnError(association.snapshotFields())
+		}
+
+		switch association.Relationship.Type {
+		case schema.HasOne, schema.BelongsTo:
+			if len(values) > 0 {
+				association.Error = association.Replace(values...)
+			}
+		case schema.Many2ManyJSON:
+			_, association.Error = association.saveJSONKeys("append", values...)
+		case schema.HasMany:
+			if values, association.Error = association.valuesFromMaps(values...); association.Error == nil {
+				association.saveAssociation( /*clear*/ false, values...)
+			}
+		default:
+			association.saveAssociation( /*clear*/ false, values...)
+		}
+	}
+
+	return association.wrapError("append")
+}
+
+// AppendWith append values to many2many association, assigns joinAttrs to the created join table records,
+// existing join table records are kept unless a clause.OnConflict is chained before Association, which is used to create them,
+// clause.Expr values are emitted raw when creating join table records, e.g: map[string]interface{}{"CreatedAt": gorm.Expr("now()")}
+func (association *Association) AppendWith(joinAttrs map[string]interface{}, values ...interface{}) error {
+	association.tag("append")
+	if association.Error == nil {
+		association.Error = association.checkWritable()
+	}
+	if association.Error == nil {
+		if association.keepsFields() {
+			defer association.restoreFieldsOnError(association.snapshotFields())
+		}
+
+		if association.Relationship.Type != schema.Many2Many {
+			association.Error = fmt.Errorf("%w: join attributes for %v", ErrUnsupportedRelation, association.Relationship.Name)
+			return association.wrapError("append")
+		}
+
+		for key := range joinAttrs {
+			if association.Relationship.JoinTable.LookUpField(key) == nil {
+				association.Error = fmt.Errorf("%w: %v for join table %v", ErrInvalidField, key, association.Relationship.JoinTable.Table)
+				return association.wrapError("append")
+			}
+		}
+
+		association.joinAttrs = joinAttrs
+		association.saveAssociation( /*clear*/ false, values...)
+		association.joinAttrs = nil
+	}
+
+	return association.wrapError("append")
+}
+
+// AppendReturningJoin append values to many2many association like Append, and assigns the created join table records
+// to joinRows, which is a pointer to slice of the join model or other structs whose fields are assigned by column names,
+// e.g: AppendReturningJoin(&userRoles, &roles), join records of values that are already appended aren't returned
+func (association *Association) AppendReturningJoin(joinRows interface{}, values ...interface{}) error {
+	association.tag("append")
+	if association.Error == nil {
+		association.Error = association.checkWritable()
+	}
+	if association.Error == nil {
+		rel := association.Relationship
+		if rel.Type != schema.Many2Many {
+			association.Error = fmt.Errorf("%w: join rows for %v", ErrUnsupportedRelation, rel.Name)
+			return association.wrapError("append")
+		}
+
+		rowsValue := reflect.ValueOf(joinRows)
+		if rowsValue.Kind() != reflect.Ptr || rowsValue.Elem().Kind() != reflect.Slice {
+			association.Error = fmt.Errorf("%w: join rows of %v should be a pointer to slice, but got %T", ErrInvalidData, rel.Name, joinRows)
+			return association.wrapError("append")
+		}
+
+		if association.keepsFields() {
+			defer association.restoreFieldsOnError(association.snapshotFields())
+		}
+
+		var createdJoins []interface{}
+		association.createdJoins = &createdJoins
+		association.saveAssociation( /*clear*/ false, values...)
+		association.createdJoins = nil
+
+		if association.Error == nil {
+			association.Error = association.assignJoinRows(rowsValue.Elem(), createdJoins)
+		}
+	}
+	return association.wrapError("append")
+}
+
+// assignJoinRows assigns join records to rows by column names
+func (association *Association) assignJoinRows(rows reflect.Value, joins []interface{}) error {
+	var (
+		joinTable = association.Relationship.JoinTable
+		rowType   = rows.Type().Elem()
+		isPtr     = rowType.Kind() == reflect.Ptr
+	)
+
+	if isPtr {
+		rowType = rowType.Elem()
+	}
+
+	rowSchema, err := schema.Parse(reflect.New(rowType).Interface(), association.DB.cacheStore, association.DB.NamingStrategy)
+	if err != nil {
+		return err
+	}
+
+	results := reflect.MakeSlice(rows.Type(), 0, len(joins))
+	for _, join := range joins {
+		row := reflect.New(rowType)
+		for _, field := range rowSchema.Fields {
+			if joinField := joinTable.LookUpField(field.DBName); field.DBName != "" && joinField != nil {
+				value, _ := joinField.ValueOf(reflect.ValueOf(join))
+				if err := field.Set(row, value); err != nil {
+					return err
+				}
+			}
+		}
+
+		if isPtr {
+			results = reflect.Append(results, row)
+		} else {
+			results = reflect.Append(results, row.Elem())
+		}
+	}
+	rows.Set(results)
+	return nil
+}
+
+// AppendUnique append has many associations whose values of columns by don't exist in current associations, existing
+// associations are looked up with one query, duplicated values are appended once, e.g: Association("Tags").AppendUnique([]string{"Name"}, &tags)
+func (association *Association) AppendUnique(by []string, values ...interface{}) error {
+	association.tag("append")
+	if association.Error == nil {
+		association.Error = association.checkWritable()
+	}
+	if association.Error == nil {
+		association.Error = association.appendUnique(by, values...)
+	}
+	return association.wrapError("append")
+}
+
+func (association *Association) appendUnique(by []string, values ...interface{}) error {
+	var (
+		rel      = association.Relationship
+		fields   = make([]*schema.Field, len(by))
+		dbNames  = make([]string, len(by))
+		elems    []reflect.Value
+		keys     = map[string]bool{}
+		appended []interface{}
+	)
+
+	if rel.Type != schema.HasMany || association.DB.Statement.ReflectValue.Kind() != reflect.Struct {
+		return fmt.Errorf("%w: append unique %v, only has many relations of a single owner are supported", ErrUnsupportedRelation, rel.Name)
+	}
+
+	if len(by) == 0 {
+		return fmt.Errorf("%w: columns to compare %v are required", ErrInvalidField, rel.Name)
+	}
+
+	for idx, name := range by {
+		if fields[idx] = rel.FieldSchema.LookUpField(name); fields[idx] == nil {
+			return fmt.Errorf("%w: %v for relation %v", ErrInvalidField, name, rel.Name)
+		}
+		dbNames[idx] = fields[idx].DBName
+	}
+
+	if err := association.validateValues(values...); err != nil {
+		return err
+	}
+
+	if elems = addressableValues(values...); len(elems) == 0 {
+		return nil
+	}
+
+	valuesOf := func(elem reflect.Value) []interface{} {
+		values := make([]interface{}, len(fields))
+		for idx, field := range fields {
+			values[idx], _ = field.ValueOf(elem)
+		}
+		return values
+	}
+
+	identityValues := make([][]interface{}, len(elems))
+	for idx, elem := range elems {
+		identityValues[idx] = valuesOf(elem)
+	}
+
+	var (
+		finder              = association.finder()
+		column, queryValues = schema.ToQueryValues(rel.FieldSchema.Table, dbNames, identityValues)
+		existing            = reflect.New(reflect.SliceOf(rel.FieldSchema.ModelType))
+	)
+
+	if err := finder.buildCondition().Select(dbNames).Where(clause.IN{Column: column, Values: queryValues}).Find(existing.Interface()).Error; err != nil {
+		return err
+	}
+
+	for i := 0; i < existing.Elem().Len(); i++ {
+		keys[utils.ToStringKey(valuesOf(existing.Elem().Index(i))...)] = true
+	}
+
+	for idx, elem := range elems {
+		if key := utils.ToStringKey(identityValues[idx]...); !keys[key] {
+			keys[key] = true
+			appended = append(appended, elem.Addr().Interface())
+		}
+	}
+
+	if len(appended) > 0 {
+		return association.Append(appended...)
+	}
+	return nil
+}
+
+// AppendByID append has many/many2many associations by primary keys without loading them, e.g: ids submitted by a form,
+// has many associations are attached by updating their foreign keys, and join records are created for many2many associations,
+// ids could be slices, set "gorm:association:validate_ids" to true to return ErrRecordNotFound if any of ids doesn't exist,
+// the owner's field isn't changed as associations aren't loaded, use Reload to load them
+func (association *Association) AppendByID(ids ...interface{}) error {
+	association.tag("append")
+	if association.Error == nil {
+		association.Error = association.checkWritable()
+	}
+	if association.Error == nil {
+		association.Error = association.appendByID(flattenValues(ids))
+	}
+	return association.wrapError("append")
+}
+
+func (association *Association) appendByID(ids []interface{}) error {
+	var (
+		rel          = association.Relationship
+		reflectValue = association.DB.Statement.ReflectValue
+		primaryField = rel.FieldSchema.PrioritizedPrimaryField
+	)
+
+	switch {
+	case rel.Type != schema.HasMany && rel.Type != schema.Many2Many:
+		return fmt.Errorf("%w: append %v by id", ErrUnsupportedRelation, rel.Name)
+	case reflectValue.Kind() != reflect.Struct:
+		return fmt.Errorf("%w: append %v by id for slice owners", ErrUnsupportedRelation, rel.Name)
+	case primaryField == nil:
+		return fmt.Errorf("%w: append %v by id, %v has no single primary key", ErrUnsupportedRelation, rel.Name, rel.FieldSchema.Name)
+	case len(ids) == 0:
+		return nil
+	}
+
+	if err := association.checkDeletedOwner(); err != nil {
+		return err
+	}
+
+	if validate, ok := association.DB.Get("gorm:association:validate_ids"); ok && validate == true {
+		if err := association.validateIDs(ids); err != nil {
+			return err
+		}
+	}
+
+	db := association.newSession()
+	ownerValue := func(ref *schema.Reference) (interface{}, error) {
+		if pv, zero := ref.PrimaryKey.ValueOf(reflectValue); !zero {
+			return pv, nil
+		}
+		return nil, ErrPrimaryKeyRequired
+	}
+
+	if rel.Type == schema.HasMany {
+		updateAttrs := map[string]interface{}{}
+		for _, ref := range rel.References {
+			if ref.OwnPrimaryKey {
+				pv, err := ownerValue(ref)
+				if err != nil {
+					return err
+				}
+				updateAttrs[ref.ForeignKey.DBName] = pv
+			} else if ref.PrimaryValue != "" {
+				updateAttrs[ref.ForeignKey.DBName] = ref.PrimaryValue
+			}
+		}
+
+		return db.Model(reflect.New(rel.FieldSchema.ModelType).Interface()).Where(clause.IN{
+			Column: clause.Column{Table: rel.FieldSchema.Table, Name: primaryField.DBName}, Values: ids,
+		}).UpdateColumns(updateAttrs).Error
+	}
+
+	var (
+		joins   = reflect.MakeSlice(reflect.SliceOf(reflect.PtrTo(rel.JoinTable.ModelType)), 0, len(ids))
+		curTime = db.NowFunc()
+	)
+
+	for _, id := range ids {
+		joinValue := reflect.New(rel.JoinTable.ModelType)
+		for _, ref := range rel.References {
+			var value interface{} = ref.PrimaryValue
+			switch {
+			case ref.OwnPrimaryKey:
+				pv, err := ownerValue(ref)
+				if err != nil {
+					return err
+				}
+				value = pv
+			case ref.PrimaryValue != "":
+			case ref.PrimaryKey == primaryField:
+				value = id
+			default:
+				return fmt.Errorf("%w: append %v by id, it references %v", ErrUnsupportedRelation, rel.Name, ref.PrimaryKey.Name)
+			}
+
+			if err := ref.ForeignKey.Set(joinValue, value); err != nil {
+				return err
+			}
+		}
+
+		// join records' timestamps come from NowFunc like join records created by Append
+		for _, field := range rel.JoinTable.Fields {
+			if field.AutoCreateTime > 0 || field.AutoUpdateTime > 0 {
+				if err := field.Set(joinValue, curTime); err != nil {
+					return err
+				}
+			}
+		}
+		joins = reflect.Append(joins, joinValue)
+	}
+
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(joins.Interface()).Error
+}
+
+// validateIDs returns ErrRecordNotFound with ids whose associations don't exist
+func (association *Association) validateIDs(ids []interface{}) error {
+	var (
+		rel          = association.Relationship
+		primaryField = rel.FieldSchema.PrioritizedPrimaryField
+		found        = reflect.New(reflect.SliceOf(primaryField.FieldType))
+		foundKeys    = map[string]bool{}
+		missingIDs   []interface{}
+	)
+
+	if err := association.newSession().Model(reflect.New(rel.FieldSchema.ModelType).Interface()).Where(clause.IN{
+		Column: clause.Column{Table: rel.FieldSchema.Table, Name: primaryField.DBName}, Values: ids,
+	}).Pluck(primaryField.DBName, found.Interface()).Error; err != nil {
+		return err
+	}
+
+	for i := 0; i < found.Elem().Len(); i++ {
+		foundKeys[utils.ToStringKey(found.Elem().Index(i).Interface())] = true
+	}
+
+	for _, id := range ids {
+		if !foundKeys[utils.ToStringKey(id)] {
+			missingIDs = append(missingIDs, id)
+		}
+	}
+
+	if len(missingIDs) > 0 {
+		return fmt.Errorf("%w: %v with primary keys %v", ErrRecordNotFound, rel.FieldSchema.Name, missingIDs)
+	}
+	return nil
+}
+
+// flattenValues expands slices and arrays in values, except []byte
+func flattenValues(values []interface{}) (results []interface{}) {
+	for _, value := range values {
+		if rv := reflect.ValueOf(value); (rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8) || rv.Kind() == reflect.Array {
+			for i := 0; i < rv.Len(); i++ {
+				results = append(results, rv.Index(i).Interface())
+			}
+		} else {
+			results = append(results, value)
+		}
+	}
+	return
+}
+
+// AppendInBatches append has many, many2many associations in batches of batchSize, each batch is saved with the owner's
+// update in its own transaction, a failed batch returns BatchError with the batch's index, set "gorm:association:continue_on_error"
+// to true to save the left batches and get BatchErrors of all failed batches.
+// It falls back to Append for other relations and slice owners
+func (association *Association) AppendInBatches(batchSize int, values ...interface{}) error {
+	association.tag("append")
+	if association.Error == nil {
+		association.Error = association.checkWritable()
+	}
+	if association.Error == nil {
+		if association.keepsFields() {
+			defer association.restoreFieldsOnError(association.snapshotFields())
+		}
+		association.Error = association.appendInBatches(batchSize, values...)
+	}
+	return association.wrapError("append")
+}
+
+func (association *Association) appendInBatches(batchSize int, values ...interface{}) error {
+	var (
+		reflectValue = association.DB.Statement.ReflectValue
+		rel          = association.Relationship
+		batchErrs    BatchErrors
+		savedElems   []reflect.Value
+	)
+
+	if (rel.Type != schema.HasMany && rel.Type != schema.Many2Many) || reflectValue.Kind() != reflect.Struct || batchSize <= 0 {
+		return association.Append(values...)
+	}
+
+	if association.Error = association.validateValues(values...); association.Error != nil {
+		return association.Error
+	}
+
+	if association.Error = association.checkAddressableOwner(); association.Error != nil {
+		return association.Error
+	}
+
+	if association.Error = association.checkDeletedOwner(); association.Error != nil {
+		return association.Error
+	}
+
+	continueOnError, _ := association.DB.Get("gorm:association:continue_on_error")
+	elems := addressableValues(values...)
+
+	for i := 0; i < len(elems); i += batchSize {
+		ends := i + batchSize
+		if ends > len(elems) {
+			ends = len(elems)
+		}
+
+		// save the batch with a copy of the owner, which only holds the batch's values
+		var (
+			batch      = elems[i:ends]
+			batchValue = reflect.MakeSlice(reflect.SliceOf(reflect.PtrTo(rel.FieldSchema.ModelType)), 0, len(batch))
+			owner      = reflect.New(reflectValue.Type())
+		)
+
+		for _, elem := range batch {
+			batchValue = reflect.Append(batchValue, elem.Addr())
+		}
+
+		owner.Elem().Set(reflectValue)
+		if association.Error = rel.Field.Set(owner, reflect.Zero(rel.Field.FieldType).Interface()); association.Error != nil {
+			return association.Error
+		}
+
+		batchAssociation := association.newSession().Set("gorm:association:allow_deleted_owner", true).Model(owner.Interface()).Association(rel.Name)
+		if batchAssociation.Error == nil {
+			batchAssociation.saveAssociation( /*clear*/ false, batchValue.Interface())
+		}
+
+		if batchAssociation.Error != nil {
+			batchErr := &BatchError{Index: i / batchSize, Err: batchAssociation.Error}
+			for _, elem := range batch {
+				if _, pvs := schema.GetIdentityFieldValuesMap(elem, rel.FieldSchema.PrimaryFields); len(pvs) > 0 {
+					batchErr.PrimaryKeys = append(batchErr.PrimaryKeys, pvs[0]...)
+				}
+			}
+
+			if ok, _ := continueOnError.(bool); !ok {
+				association.Error = batchErr
+				break
+			}
+			batchErrs = append(batchErrs, batchErr)
+		} else {
+			savedElems = append(savedElems, batch...)
+		}
+	}
+
+	if len(savedElems) > 0 {
+		if err := association.setFieldValues(savedElems, false); association.Error == nil {
+			association.Error = err
+		}
+	}
+
+	if association.Error == nil && len(batchErrs) > 0 {
+		association.Error = batchErrs
+	}
+	return association.Error
+}
+
+// Save updates already associated values, blank foreign keys are set to the owner's, values belong to another owner are refused,
+// join table records of many2many associations are not changed
+func (association *Association) Save(values ...interface{}) error {
+	association.tag("save")
+	if association.Error == nil {
+		association.Error = association.checkWritable()
+	}
+	if association.Error == nil {
+		if association.keepsFields() {
+			defer association.restoreFieldsOnError(association.snapshotFields())
+		}
+		association.Error = association.save(values...)
+	}
+
+	return association.wrapError("save")
+}
+
+func (association *Association) save(values ...interface{}) error {
+	var (
+		rel          = association.Relationship
+		reflectValue = association.DB.Statement.ReflectValue
+		errNotOwned  = fmt.Errorf("%w: %v doesn't belong to %v", ErrInvalidData, rel.Name, association.DB.Statement.Schema.Name)
+	)
+
+	if err := association.validateValues(values...); err != nil {
+		return err
+	}
+
+	if reflectValue.Kind() != reflect.Struct {
+		return fmt.Errorf("%w: save %v for multiple owners", ErrUnsupportedRelation, rel.Name)
+	}
+
+	elems := addressableValues(values...)
+	for _, elem := range elems {
+		for _, field := range rel.FieldSchema.PrimaryFields {
+			if _, zero := field.ValueOf(elem); zero {
+				return fmt.Errorf("%w: can't save %v without primary key", ErrPrimaryKeyRequired, rel.Name)
+			}
+		}
+
+		for _, ref := range rel.References {
+			var ownerValue, value interface{}
+			switch {
+			case rel.JoinTable != nil:
+				continue
+			case rel.Type == schema.BelongsTo:
+				var zero bool
+				if ownerValue, zero = ref.ForeignKey.ValueOf(reflectValue); zero {
+					return errNotOwned
+				}
+				value, _ = ref.PrimaryKey.ValueOf(elem)
+			case ref.OwnPrimaryKey:
+				ownerValue, _ = ref.PrimaryKey.ValueOf(reflectValue)
+				if _, zero := ref.ForeignKey.ValueOf(elem); zero {
+					ref.ForeignKey.Set(elem, ownerValue)
+				}
+				value, _ = ref.ForeignKey.ValueOf(elem)
+			case ref.PrimaryValue != "":
+				ownerValue = ref.PrimaryValue
+				if _, zero := ref.ForeignKey.ValueOf(elem); zero {
+					ref.ForeignKey.Set(elem, ownerValue)
+				}
+				value, _ = ref.ForeignKey.ValueOf(elem)
+			}
+
+			if utils.ToStringKey(ownerValue) != utils.ToStringKey(value) {
+				return errNotOwned
+			}
+		}
+	}
+
+	return association.saveDB().Transaction(func(tx *DB) error {
+		for _, elem := range elems {
+			value := elem.Addr().Interface()
+			if err := tx.Model(value).Select("*").Omit(clause.Associations).Updates(value).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Replace replace current associations with new ones, it's retried on deadlocks if "gorm:association:deadlock_retries" is set
+func (association *Association) Replace(values ...interface{}) error {
+	association.tag("replace")
+	if association.Error == nil {
+		association.Error = association.checkWritable()
+	}
+	if association.Error == nil {
+		association.Error = association.retryOnDeadlock(func() error {
+			return association.replace(values...)
+		})
+	}
+	return association.wrapError("replace")
+}
+
+func (association *Association) replace(values ...interface{}) error {
+	if association.Error == nil {
+		// restore the owner's field if failed, keeps it consistent with the database
+		defer association.restoreFieldsOnError(association.snapshotFields())
+
+		if association.Relationship.Type == schema.Many2ManyJSON {
+			_, association.Error = association.saveJSONKeys("replace", values...)
+			return association.Error
+		}
+
+		if association.maxDetach != nil {
+			if association.Error = association.checkDetaching(values...); association.Error != nil {
+				return association.Error
+			}
+		}
+
+		// has many associations already owned by the owner are neither saved nor detached, only the delta is written
+		var elems, kept, changed []reflect.Value
+		if association.Relationship.Type == schema.HasMany {
+			if elems, kept, changed, association.Error = association.splitOwnedValues(values...); association.Error != nil {
+				return association.Error
+			}
+		}
+
+		saveValues := values
+		if len(kept) > 0 {
+			saveValues = make([]interface{}, len(changed))
+			for idx, elem := range changed {
+				saveValues[idx] = elem.Addr().Interface()
+			}
+		}
+
+		// save associations, which assigns values to the owner's field, stop before detaching old associations
+		// if the context is done meanwhile, so the owner's field is restored
+		if association.saveAssociation( /*clear*/ true, saveValues...); association.Error != nil {
+			return association.Error
+		} else if ctx := association.DB.Statement.Context; ctx != nil && ctx.Err() != nil {
+			association.Error = ctx.Err()
+			return association.Error
+		}
+
+		if len(kept) > 0 {
+			// kept values are assigned to the owner's field with saved values in the order of values
+			if association.Error = association.setFieldValues(elems, true); association.Error != nil {
+				return association.Error
+			}
+		}
+
+		association.detach(values...)
+	}
+	return association.Error
+}
+
+// checkDetaching counts current associations missing from values, which would be detached by replace, returns
+// ErrTooManyDetached if there are more than maxDetach
+func (association *Association) checkDetaching(values ...interface{}) error {
+	var (
+		rel   = association.Relationship
+		count int64
+	)
+
+	if err := association.validateValues(values...); err != nil {
+		return err
+	}
+
+	tx := withoutLimit(association.buildCondition())
+	if _, pvs := schema.GetIdentityFieldValuesMapFromValues(values, rel.FieldSchema.PrimaryFields); len(pvs) > 0 {
+		column, values := schema.ToQueryValues(rel.FieldSchema.Table, rel.FieldSchema.PrimaryFieldDBNames, pvs)
+		tx.Where(clause.Not(clause.IN{Column: column, Values: values}))
+	}
+
+	if err := tx.Count(&count).Error; err != nil {
+		return err
+	} else if count > int64(*association.maxDetach) {
+		return fmt.Errorf("%w: replacing %v would detach %v associations, at most %v are allowed", ErrTooManyDetached, rel.Name, count, *association.maxDetach)
+	}
+	return nil
+}
+
+// splitOwnedValues splits has many values of a saved owner into values already owned by the owner, whose foreign keys are
+// assigned in memory only, and changed values need to be saved, it doesn't split them with FullSaveAssociations,
+// for slice owners, broadcast or ordered relations, all values are saved then
+func (association *Association) splitOwnedValues(values ...interface{}) (elems, kept, changed []reflect.Value, err error) {
+	var (
+		rel          = association.Relationship
+		reflectValue = association.DB.Statement.ReflectValue
+		ownedKeys    = map[string]bool{}
+	)
+
+	if reflectValue.Kind() != reflect.Struct || association.broadcast || association.DB.FullSaveAssociations || rel.PositionField() != nil || len(values) == 0 {
+		return nil, nil, nil, nil
+	}
+
+	if err = association.validateValues(values...); err != nil {
+		return nil, nil, nil, err
+	}
+
+	for _, ref := range rel.References {
+		if !ref.OwnPrimaryKey {
+			continue
+		} else if _, zero := ref.PrimaryKey.ValueOf(reflectValue); zero {
+			return nil, nil, nil, nil
+		}
+	}
+
+	elems = addressableValues(values...)
+	hasPrimaryKey := func(elem reflect.Value) bool {
+		for _, field := range rel.FieldSchema.PrimaryFields {
+			if _, zero := field.ValueOf(elem); zero {
+				return false
+			}
+		}
+		return len(rel.FieldSchema.PrimaryFields) > 0
+	}
+
+	var candidates [][]interface{}
+	for _, elem := range elems {
+		if hasPrimaryKey(elem) {
+			candidates = append(candidates, association.primaryValues(elem))
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, nil, nil, nil
+	}
+
+	owned := reflect.New(reflect.SliceOf(rel.FieldSchema.ModelType))
+	column, candidateValues := schema.ToQueryValues(rel.FieldSchema.Table, rel.FieldSchema.PrimaryFieldDBNames, candidates)
+	if err = association.buildCondition().Select(rel.FieldSchema.PrimaryFieldDBNames).Where(clause.IN{Column: column, Values: candidateValues}).Find(owned.Interface()).Error; err != nil {
+		return nil, nil, nil, err
+	}
+
+	for i := 0; i < owned.Elem().Len(); i++ {
+		ownedKeys[utils.ToLengthPrefixedKey(association.primaryValues(owned.Elem().Index(i))...)] = true
+	}
+
+	for _, elem := range elems {
+		if !hasPrimaryKey(elem) || !ownedKeys[utils.ToLengthPrefixedKey(association.primaryValues(elem)...)] {
+			changed = append(changed, elem)
+			continue
+		}
+
+		for _, ref := range rel.References {
+			if ref.OwnPrimaryKey {
+				ownerValue, _ := ref.PrimaryKey.ValueOf(reflectValue)
+				err = ref.ForeignKey.Set(elem, ownerValue)
+			} else if ref.PrimaryValue != "" {
+				err = ref.ForeignKey.Set(elem, ref.PrimaryValue)
+			}
+
+			if err != nil {
+				return nil, nil, nil, err
+			}
+		}
+		kept = append(kept, elem)
+	}
+	return elems, kept, changed, nil
+}
+
+// primaryValues returns values of the associations's primary fields of elem
+func (association *Association) primaryValues(elem reflect.Value) []interface{} {
+	values := make([]interface{}, len(association.Relationship.FieldSchema.PrimaryFields))
+	for idx, field := range association.Relationship.FieldSchema.PrimaryFields {
+		values[idx], _ = field.ValueOf(elem)
+	}
+	return values
+}
+
+// detach set foreign keys of the owner's old associations, which are neither in the owner's field nor values, to null,
+// for many2many, deletes their join records instead
+func (association *Association) detach(values ...interface{}) {
+	reflectValue := association.DB.Statement.ReflectValue
+	rel := association.Relationship
+	switch rel.Type {
+	case schema.BelongsTo:
+		if len(values) == 0 {
+			updateMap := map[string]interface{}{}
+			switch reflectValue.Kind() {
+			case reflect.Slice, reflect.Array:
+				for i := 0; i < reflectValue.Len(); i++ {
+					association.Error = rel.Field.Set(reflectValue.Index(i), reflect.Zero(rel.Field.FieldType).Interface())
+				}
+			case reflect.Struct:
+				association.Error = rel.Field.Set(reflectValue, reflect.Zero(rel.Field.FieldType).Interface())
+			}
+
+			for _, ref := range rel.References {
+				updateMap[ref.ForeignKey.DBName] = nil
+			}
+
+			association.Error = association.session().UpdateColumns(updateMap).Error
+		}
+	case schema.HasOne, schema.HasMany:
+		var (
+			primaryFields []*schema.Field
+			foreignKeys   []string
+			updateMap     = map[string]interface{}{}
+			relValues     = schema.GetRelationsValues(reflectValue, []*schema.Relationship{rel})
+			modelValue    = reflect.New(rel.FieldSchema.ModelType).Interface()
+			tx            = association.session().Model(modelValue)
+		)
+
+		if _, rvs := schema.GetIdentityFieldValuesMap(relValues, rel.FieldSchema.PrimaryFields); len(rvs) > 0 {
+			if column, values := schema.ToQueryValues(rel.FieldSchema.Table, rel.FieldSchema.PrimaryFieldDBNames, rvs); len(values) > 0 {
+				tx.Not(clause.IN{Column: column, Values: values})
+			}
+		}
+
+		for _, ref := range rel.References {
+			if ref.OwnPrimaryKey {
+				primaryFields = append(primaryFields, ref.PrimaryKey)
+				foreignKeys = append(foreignKeys, ref.ForeignKey.DBName)
+				updateMap[ref.ForeignKey.DBName] = nil
+			} else if ref.PrimaryValue != "" {
+				// also clear polymorphic type, otherwise detached records still look like owned by the owner's type
+				tx.Where(clause.Eq{Column: ref.ForeignKey.DBName, Value: ref.PrimaryValue})
+				updateMap[ref.ForeignKey.DBName] = nil
+			}
+		}
+
+		if _, pvs := schema.GetIdentityFieldValuesMap(reflectValue, primaryFields); len(pvs) > 0 {
+			column, values := schema.ToQueryValues(rel.FieldSchema.Table, foreignKeys, pvs)
+			association.Error = tx.Where(clause.IN{Column: column, Values: values}).UpdateColumns(updateMap).Error
+		}
+	case schema.Many2Many:
+		var (
+			primaryFields, relPrimaryFields     []*schema.Field
+			joinPrimaryKeys, joinRelPrimaryKeys []string
+			modelValue                          = reflect.New(rel.JoinTable.ModelType).Interface()
+			tx                                  = association.session().Model(modelValue)
+		)
+
+		for _, ref := range rel.References {
+			if ref.PrimaryValue == "" {
+				if ref.OwnPrimaryKey {
+					primaryFields = append(primaryFields, ref.PrimaryKey)
+					joinPrimaryKeys = append(joinPrimaryKeys, ref.ForeignKey.DBName)
+				} else {
+					relPrimaryFields = append(relPrimaryFields, ref.PrimaryKey)
+					joinRelPrimaryKeys = append(joinRelPrimaryKeys, ref.ForeignKey.DBName)
+				}
+			} else {
+				tx.Clauses(clause.Eq{Column: ref.ForeignKey.DBName, Value: ref.PrimaryValue})
+			}
+		}
+
+		if len(association.joinConds) > 0 {
+			tx.Clauses(clause.Where{Exprs: association.joinConds})
+		}
+
+		// owners could be a large slice, collect their primary keys without building an identity map
+		var pvs [][]interface{}
+		schema.ForEachIdentityFieldValues(reflectValue, primaryFields, func(_ reflect.Value, values []interface{}) {
+			pvs = append(pvs, values)
+		})
+
+		if column, values := schema.ToQueryValues(rel.JoinTable.Table, joinPrimaryKeys, pvs); len(values) > 0 {
+			tx.Where(clause.IN{Column: column, Values: values})
+		} else {
+			association.Error = ErrPrimaryKeyRequired
+			return
+		}
+
+		// only delete join records of removed associations, kept join records (including their extra columns)
+		// stay untouched, as join records are created with ON CONFLICT DO NOTHING when saving associations
+		_, rvs := schema.GetIdentityFieldValuesMapFromValues(values, relPrimaryFields)
+		if relColumn, relValues := schema.ToQueryValues(rel.JoinTable.Table, joinRelPrimaryKeys, rvs); len(relValues) > 0 {
+			tx.Where(clause.Not(clause.IN{Column: relColumn, Values: relValues}))
+		}
+
+		// tx shares the owner DB's statement context, deleting a large set of join records is aborted once it's done
+		if association.Error = tx.Delete(modelValue).Error; association.Error == nil && rel.PositionField() != nil && reflectValue.Kind() == reflect.Struct {
+			// renumber positions in the order of the replaced associations
+			fieldValue := reflect.Indirect(rel.Field.ReflectValueOf(reflectValue))
+			targets := make([]reflect.Value, fieldValue.Len())
+			for i := range targets {
+				targets[i] = reflect.Indirect(fieldValue.Index(i))
+			}
+			association.Error = association.updatePositions(reflectValue, targets)
+		}
+	}
+}
+
+// ReplaceInBatches replace many2many associations like Replace, but diffs the current associations in batches of batchSize,
+// passed values are appended in batches, join records are paginated by the associations's foreign key, and each batch
+// is executed in its own transaction, set "gorm:association:replace_in_transaction" to true to replace them in one
+// transaction. It falls back to Replace for other relations, slice owners and composite foreign keys
+func (association *Association) ReplaceInBatches(batchSize int, values ...interface{}) error {
+	association.tag("replace")
+	if association.Error == nil {
+		association.Error = association.checkWritable()
+	}
+	if association.Error == nil {
+		// restore the owner's field if failed, as it's changed by batches
+		defer association.restoreFieldsOnError(association.snapshotFields())
+
+		if inTransaction, ok := association.DB.Get("gorm:association:replace_in_transaction"); ok && inTransaction == true {
+			association.Error = association.DB.Transaction(func(tx *DB) error {
+				txAssociation := association.clone()
+				txAssociation.DB = tx
+				return txAssociation.replaceInBatches(batchSize, values...)
+			})
+		} else {
+			association.Error = association.replaceInBatches(batchSize, values...)
+		}
+	}
+	return association.wrapError("replace")
+}
+
+func (association *Association) replaceInBatches(batchSize int, values ...interface{}) error {
+	var (
+		reflectValue                        = association.DB.Statement.ReflectValue
+		rel                                 = association.Relationship
+		primaryFields, relPrimaryFields     []*schema.Field
+		joinPrimaryKeys, joinRelPrimaryKeys []string
+		joinTableConds                      []clause.Expression
+	)
+
+	if rel.Type != schema.Many2Many || reflectValue.Kind() != reflect.Struct || batchSize <= 0 {
+		return association.replace(values...)
+	}
+
+	for _, ref := range rel.References {
+		if ref.PrimaryValue == "" {
+			if ref.OwnPrimaryKey {
+				primaryFields = append(primaryFields, ref.PrimaryKey)
+				joinPrimaryKeys = append(joinPrimaryKeys, ref.ForeignKey.DBName)
+			} else {
+				relPrimaryFields = append(relPrimaryFields, ref.PrimaryKey)
+				joinRelPrimaryKeys = append(joinRelPrimaryKeys, ref.ForeignKey.DBName)
+			}
+		} else {
+			joinTableConds = append(joinTableConds, clause.Eq{Column: ref.ForeignKey.DBName, Value: ref.PrimaryValue})
+		}
+	}
+
+	if len(relPrimaryFields) != 1 {
+		return association.replace(values...)
+	}
+	joinTableConds = append(joinTableConds, association.joinConds...)
+
+	if association.Error = association.validateValues(values...); association.Error != nil {
+		return association.Error
+	}
+
+	if association.Error = association.checkAddressableOwner(); association.Error != nil {
+		return association.Error
+	}
+
+	if len(values) > 0 {
+		if association.Error = association.checkDeletedOwner(); association.Error != nil {
+			return association.Error
+		}
+	}
+
+	if rel.IsSelfReferential() {
+		if association.Error = association.checkSelfReferences(values...); association.Error != nil {
+			return association.Error
+		}
+	}
+
+	_, pvs := schema.GetIdentityFieldValuesMap(reflectValue, primaryFields)
+	column, ownerValues := schema.ToQueryValues(rel.JoinTable.Table, joinPrimaryKeys, pvs)
+	if len(ownerValues) == 0 {
+		association.Error = ErrPrimaryKeyRequired
+		return association.Error
+	}
+
+	elems := addressableValues(values...)
+
+	db := association.newScopedSession()
+
+	// create associations and join records of passed values in batches like appending them, so hooks, join attributes,
+	// positions and the association's Select/Omit apply to them
+	for i := 0; i < len(elems); i += batchSize {
+		ends := i + batchSize
+		if ends > len(elems) {
+			ends = len(elems)
+		}
+
+		batch := make([]interface{}, 0, ends-i)
+		for _, elem := range elems[i:ends] {
+			batch = append(batch, elem.Addr().Interface())
+		}
+
+		if association.saveAssociation(true, batch...); association.Error != nil {
+			return association.Error
+		}
+	}
+
+	// delete join records of associations not in passed values, page by page
+	var (
+		relPrimaryField   = relPrimaryFields[0]
+		joinRelPrimaryKey = joinRelPrimaryKeys[0]
+		keptKeys          = map[string]bool{}
+		lastKey           interface{}
+	)
+
+	for _, elem := range elems {
+		fv, _ := relPrimaryField.ValueOf(elem)
+		keptKeys[utils.ToStringKey(fv)] = true
+	}
+
+	for {
+		var (
+			modelValue = reflect.New(rel.JoinTable.ModelType).Interface()
+			keys       = reflect.New(reflect.SliceOf(relPrimaryField.FieldType))
+			tx         = db.Model(modelValue).Where(clause.IN{Column: column, Values: ownerValues})
+		)
+
+		if len(joinTableConds) > 0 {
+			tx = tx.Clauses(clause.Where{Exprs: joinTableConds})
+		}
+
+		if lastKey != nil {
+			tx = tx.Where(clause.Gt{Column: clause.Column{Table: rel.JoinTable.Table, Name: joinRelPrimaryKey}, Value: lastKey})
+		}
+
+		if association.Error = tx.Order(clause.OrderByColumn{
+			Column: clause.Column{Table: rel.JoinTable.Table, Name: joinRelPrimaryKey},
+		}).Limit(batchSize).Pluck(joinRelPrimaryKey, keys.Interface()).Error; association.Error != nil {
+			return association.Error
+		}
+
+		keys = keys.Elem()
+		if keys.Len() == 0 {
+			break
+		}
+		lastKey = keys.Index(keys.Len() - 1).Interface()
+
+		var removedKeys []interface{}
+		for i := 0; i < keys.Len(); i++ {
+			if key := keys.Index(i).Interface(); !keptKeys[utils.ToStringKey(key)] {
+				removedKeys = append(removedKeys, key)
+			}
+		}
+
+		if len(removedKeys) > 0 {
+			association.Error = db.Transaction(func(tx *DB) error {
+				tx = tx.Where(clause.IN{Column: column, Values: ownerValues}).Where(clause.IN{
+					Column: clause.Column{Table: rel.JoinTable.Table, Name: joinRelPrimaryKey}, Values: removedKeys,
+				})
+				if len(joinTableConds) > 0 {
+					tx = tx.Clauses(clause.Where{Exprs: joinTableConds})
+				}
+				return tx.Delete(modelValue).Error
+			})
+
+			if association.Error != nil {
+				return association.Error
+			}
+		}
+
+		if keys.Len() < batchSize {
+			break
+		}
+	}
+
+	association.Error = association.setFieldValues(elems, true)
+	return association.Error
+}
+
+func (association *Association) Delete(values ...interface{}) error {
+	_, err := association.DeleteWithResult(values...)
+	return err
+}
+
+// DeleteWithResult delete relationship between source & passed arguments, returns the number of detached records,
+// for has one/has many it is the number of cleared foreign keys, for many2many the number of deleted join records,
+// it's retried on deadlocks if "gorm:association:deadlock_retries" is set
+func (association *Association) DeleteWithResult(values ...interface{}) (rowsAffected int64, err error) {
+	association.tag("delete")
+	if association.Error == nil {
+		association.Error = association.checkWritable()
+	}
+	err = association.retryOnDeadlock(func() error {
+		rowsAffected, err = association.deleteWithResult(values...)
+		return err
+	})
+	return rowsAffected, err
+}
+
+func (association *Association) deleteWithResult(values ...interface{}) (rowsAffected int64, err error) {
+	if association.Error == nil {
+		association.Error = association.checkAddressableOwner()
+	}
+
+	if association.Error == nil && association.Relationship.Type == schema.Many2ManyJSON {
+		rowsAffected, association.Error = association.saveJSONKeys("delete", values...)
+		return rowsAffected, association.wrapError("delete")
+	}
+
+	if association.Error == nil {
+		if association.keepsFields() {
+			defer association.restoreFieldsOnError(association.snapshotFields())
+		}
+
+		var (
+			result        *DB
+			reflectValue  = association.DB.Statement.ReflectValue
+			rel           = association.Relationship
+			primaryFields []*schema.Field
+			foreignKeys   []string
+			updateAttrs   = map[string]interface{}{}
+			conds         []clause.Expression
+		)
+
+		for _, ref := range rel.References {
+			if ref.PrimaryValue == "" {
+				primaryFields = append(primaryFields, ref.PrimaryKey)
+				foreignKeys = append(foreignKeys, ref.ForeignKey.DBName)
+				updateAttrs[ref.ForeignKey.DBName] = nil
+			} else {
+				conds = append(conds, clause.Eq{Column: ref.ForeignKey.DBName, Value: ref.PrimaryValue})
+			}
+		}
+
+		switch rel.Type {
+		case schema.BelongsTo:
+			tx := association.session().Model(reflect.New(rel.Schema.ModelType).Interface())
+
+			_, pvs := schema.GetIdentityFieldValuesMap(reflectValue, rel.Schema.PrimaryFields)
+			pcolumn, pvalues := schema.ToQueryValues(rel.Schema.Table, rel.Schema.PrimaryFieldDBNames, pvs)
+			conds = append(conds, clause.IN{Column: pcolumn, Values: pvalues})
+
+			_, rvs := schema.GetIdentityFieldValuesMapFromValues(values, primaryFields)
+			relColumn, relValues := schema.ToQueryValues(rel.Schema.Table, foreignKeys, rvs)
+			conds = append(conds, clause.IN{Column: relColumn, Values: relValues})
+
+			result = tx.Clauses(conds...).UpdateColumns(updateAttrs)
+		case schema.HasOne, schema.HasMany:
+			tx := association.session().Model(reflect.New(rel.FieldSchema.ModelType).Interface())
+
+			_, pvs := schema.GetIdentityFieldValuesMap(reflectValue, primaryFields)
+			pcolumn, pvalues := schema.ToQueryValues(rel.FieldSchema.Table, foreignKeys, pvs)
+			conds = append(conds, clause.IN{Column: pcolumn, Values: pvalues})
+
+			_, rvs := schema.GetIdentityFieldValuesMapFromValues(values, rel.FieldSchema.PrimaryFields)
+			relColumn, relValues := schema.ToQueryValues(rel.FieldSchema.Table, rel.FieldSchema.PrimaryFieldDBNames, rvs)
+			conds = append(conds, clause.IN{Column: relColumn, Values: relValues})
+
+			if association.cascade {
+				result = association.cascadeDelete(conds)
+			} else {
+				result = tx.Clauses(conds...).UpdateColumns(updateAttrs)
+			}
+		case schema.Many2Many:
+			var (
+				primaryFields, relPrimaryFields     []*schema.Field
+				joinPrimaryKeys, joinRelPrimaryKeys []string
+				joinValue                           = reflect.New(rel.JoinTable.ModelType).Interface()
+			)
+
+			for _, ref := range rel.References {
+				if ref.PrimaryValue == "" {
+					if ref.OwnPrimaryKey {
+						primaryFields = append(primaryFields, ref.PrimaryKey)
+						joinPrimaryKeys = append(joinPrimaryKeys, ref.ForeignKey.DBName)
+					} else {
+						relPrimaryFields = append(relPrimaryFields, ref.PrimaryKey)
+						joinRelPrimaryKeys = append(joinRelPrimaryKeys, ref.ForeignKey.DBName)
+					}
+				} else {
+					conds = append(conds, clause.Eq{Column: ref.ForeignKey.DBName, Value: ref.PrimaryValue})
+				}
+			}
+
+			_, pvs := schema.GetIdentityFieldValuesMap(reflectValue, primaryFields)
+			pcolumn, pvalues := schema.ToQueryValues(rel.JoinTable.Table, joinPrimaryKeys, pvs)
+			conds = append(conds, clause.IN{Column: pcolumn, Values: pvalues})
+
+			_, rvs := schema.GetIdentityFieldValuesMapFromValues(values, relPrimaryFields)
+			relColumn, relValues := schema.ToQueryValues(rel.JoinTable.Table, joinRelPrimaryKeys, rvs)
+			conds = append(conds, clause.IN{Column: relColumn, Values: relValues})
+			conds = append(conds, association.joinConds...)
+
+			result = association.session().Where(clause.Where{Exprs: conds}).Model(nil).Delete(joinValue)
+		}
+
+		if association.Error, rowsAffected = result.Error, result.RowsAffected; association.Error == nil {
+			// clean up deleted values's foreign key, keyed with the collision free encoding as string primary keys may contain any delimiter
+			relValuesMap := map[string]bool{}
+			_, relValues := schema.GetIdentityFieldValuesMapFromValues(values, rel.FieldSchema.PrimaryFields)
+			for _, relValue := range relValues {
+				relValuesMap[utils.ToLengthPrefixedKey(relValue...)] = true
+			}
+
+			cleanUpDeletedRelations := func(data reflect.Value) {
+				if _, zero := rel.Field.ValueOf(data); !zero {
+					fieldValue := reflect.Indirect(rel.Field.ReflectValueOf(data))
+					primaryValues := make([]interface{}, len(rel.FieldSchema.PrimaryFields))
+
+					switch fieldValue.Kind() {
+					case reflect.Slice, reflect.Array:
+						validFieldValues := reflect.Zero(rel.Field.IndirectFieldType)
+						for i := 0; i < fieldValue.Len(); i++ {
+							for idx, field := range rel.FieldSchema.PrimaryFields {
+								primaryValues[idx], _ = field.ValueOf(fieldValue.Index(i))
+							}
+
+							if !relValuesMap[utils.ToLengthPrefixedKey(primaryValues...)] {
+								validFieldValues = reflect.Append(validFieldValues, fieldValue.Index(i))
+							}
+						}
+
+						association.Error = rel.Field.Set(data, validFieldValues.Interface())
+					case reflect.Struct:
+						for idx, field := range rel.FieldSchema.PrimaryFields {
+							primaryValues[idx], _ = field.ValueOf(fieldValue)
+						}
+
+						if relValuesMap[utils.ToLengthPrefixedKey(primaryValues...)] {
+							if association.Error = rel.Field.Set(data, reflect.Zero(rel.FieldSchema.ModelType).Interface()); association.Error != nil {
+								break
+							}
+
+							if rel.JoinTable == nil {
+								for _, ref := range rel.References {
+									if ref.OwnPrimaryKey || ref.PrimaryValue != "" {
+										association.Error = ref.ForeignKey.Set(fieldValue, reflect.Zero(ref.ForeignKey.FieldType).Interface())
+									} else {
+										association.Error = ref.ForeignKey.Set(data, reflect.Zero(ref.ForeignKey.FieldType).Interface())
+									}
+								}
+							}
+						}
+					}
+				}
+			}
+
+			// the owner's foreign keys referencing deleted values are nulled in the database, reset them and the relation field
+			// even if the relation field isn't loaded
+			deletedKeys := map[string]bool{}
+			if rel.Type == schema.BelongsTo {
+				_, rvs := schema.GetIdentityFieldValuesMapFromValues(values, primaryFields)
+				for _, rv := range rvs {
+					deletedKeys[utils.ToLengthPrefixedKey(rv...)] = true
+				}
+			}
+
+			cleanUpDeletedForeignKeys := func(data reflect.Value) {
+				foreignValues := make([]interface{}, 0, len(primaryFields))
+				for _, ref := range rel.References {
+					if ref.PrimaryValue == "" {
+						foreignValue, zero := ref.ForeignKey.ValueOf(data)
+						if zero {
+							return
+						}
+						foreignValues = append(foreignValues, foreignValue)
+					}
+				}
+
+				if deletedKeys[utils.ToLengthPrefixedKey(foreignValues...)] {
+					association.Error = rel.Field.Set(data, reflect.Zero(rel.Field.FieldType).Interface())
+					for _, ref := range rel.References {
+						if ref.PrimaryValue == "" && association.Error == nil {
+							association.Error = ref.ForeignKey.Set(data, reflect.Zero(ref.ForeignKey.FieldType).Interface())
+						}
+					}
+				}
+			}
+
+			cleanUp := func(data reflect.Value) {
+				if len(deletedKeys) > 0 {
+					cleanUpDeletedForeignKeys(data)
+				}
+				cleanUpDeletedRelations(data)
+			}
+
+			switch reflectValue.Kind() {
+			case reflect.Slice, reflect.Array:
+				for i := 0; i < reflectValue.Len(); i++ {
+					cleanUp(reflect.Indirect(reflectValue.Index(i)))
+				}
+			case reflect.Struct:
+				cleanUp(reflectValue)
+			}
+
+			// keep positions of left associations contiguous
+			if association.Error == nil && rel.PositionField() != nil && reflectValue.Kind() == reflect.Struct {
+				association.Error = association.compactPositions(reflectValue)
+			}
+		}
+	}
+
+	return rowsAffected, association.wrapError("delete")
+}
+
+// cascadeDelete deletes has one/has many records matching conds with their nested associations in a transaction
+func (association *Association) cascadeDelete(conds []clause.Expression) *DB {
+	db := association.newScopedSession()
+
+	var rowsAffected int64
+	db.Error = db.Transaction(func(tx *DB) error {
+		fieldSchema := association.Relationship.FieldSchema
+		records := reflect.New(reflect.SliceOf(reflect.PtrTo(fieldSchema.ModelType)))
+		if err := tx.Model(records.Interface()).Clauses(conds...).Find(records.Interface()).Error; err != nil || records.Elem().Len() == 0 {
+			return err
+		}
+
+		visited := map[string]bool{}
+		markVisitedRecords(fieldSchema, records.Elem(), visited)
+		if err := deleteNestedAssociations(tx, fieldSchema, records.Elem(), visited); err != nil {
+			return err
+		}
+
+		result := tx.Delete(records.Interface())
+		rowsAffected = result.RowsAffected
+		return result.Error
+	})
+	db.RowsAffected = rowsAffected
+	return db
+}
+
+// deleteNestedAssociations deletes has one/has many records of records and their nested associations recursively,
+// and deletes many2many join records of records, visited records are skipped to break cycles, e.g: self-referential
+// relations whose records refer to each other
+func deleteNestedAssociations(tx *DB, s *schema.Schema, records reflect.Value, visited map[string]bool) error {
+	ownerConds := func(rel *schema.Relationship, table string) (conds []clause.Expression, ok bool) {
+		var (
+			primaryFields []*schema.Field
+			foreignKeys   []string
+		)
+
+		for _, ref := range rel.References {
+			if ref.PrimaryValue == "" {
+				if ref.OwnPrimaryKey {
+					primaryFields = append(primaryFields, ref.PrimaryKey)
+					foreignKeys = append(foreignKeys, ref.ForeignKey.DBName)
+				}
+			} else {
+				conds = append(conds, clause.Eq{Column: clause.Column{Table: table, Name: ref.ForeignKey.DBName}, Value: ref.PrimaryValue})
+			}
+		}
+
+		_, pvs := schema.GetIdentityFieldValuesMap(records, primaryFields)
+		column, values := schema.ToQueryValues(table, foreignKeys, pvs)
+		return append(conds, clause.IN{Column: column, Values: values}), len(values) > 0
+	}
+
+	relations := append([]*schema.Relationship{}, s.Relationships.HasOne...)
+	relations = append(append(relations, s.Relationships.HasMany...), s.Relationships.Many2Many...)
+	for _, rel := range relations {
+		if rel.JoinTable != nil {
+			if conds, ok := ownerConds(rel, rel.JoinTable.Table); ok {
+				if err := tx.Clauses(clause.Where{Exprs: conds}).Delete(reflect.New(rel.JoinTable.ModelType).Interface()).Error; err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		conds, ok := ownerConds(rel, rel.FieldSchema.Table)
+		if !ok {
+			continue
+		}
+
+		found := reflect.New(reflect.SliceOf(reflect.PtrTo(rel.FieldSchema.ModelType)))
+		if err := tx.Model(found.Interface()).Clauses(conds...).Find(found.Interface()).Error; err != nil {
+			return err
+		}
+
+		nested := markVisitedRecords(rel.FieldSchema, found.Elem(), visited)
+		if nested.Len() == 0 {
+			continue
+		}
+
+		if err := deleteNestedAssociations(tx, rel.FieldSchema, nested, visited); err != nil {
+			return err
+		}
+
+		if err := tx.Delete(nested.Interface()).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markVisitedRecords marks records as visited, returns records that haven't been visited before
+func markVisitedRecords(s *schema.Schema, records reflect.Value, visited map[string]bool) reflect.Value {
+	unvisited := reflect.MakeSlice(records.Type(), 0, records.Len())
+	values := make([]interface{}, len(s.PrimaryFields)+1)
+	values[0] = s.Table
+	for i := 0; i < records.Len(); i++ {
+		for idx, field := range s.PrimaryFields {
+			values[idx+1], _ = field.ValueOf(records.Index(i))
+		}
+
+		if key := utils.ToLengthPrefixedKey(values...); !visited[key] {
+			visited[key] = true
+			unvisited = reflect.Append(unvisited, records.Index(i))
+		}
+	}
+	return unvisited
+}
+
+// DeleteWhere delete relationship between source & associations matching the conditions, returns the number of detached records
+// like DeleteWithResult, e.g: db.Model(&user).Association("Orders").DeleteWhere("status = ?", "cancelled")
+func (association *Association) DeleteWhere(query interface{}, args ...interface{}) (rowsAffected int64, err error) {
+	association.tag("delete")
+	if association.Error == nil {
+		association.Error = association.checkWritable()
+	}
+	if association.Error != nil {
+		return 0, association.wrapError("delete")
+	}
+
+	var (
+		finder = association.finder()
+		values = reflect.New(reflect.SliceOf(reflect.PtrTo(association.Relationship.FieldSchema.ModelType)))
+	)
+
+	if err := finder.Find(values.Interface(), append([]interface{}{query}, args...)...); err != nil {
+		association.Error = err
+		return 0, association.wrapError("delete")
+	}
+
+	if values.Elem().Len() == 0 {
+		return 0, nil
+	}
+	return association.DeleteWithResult(values.Interface())
+}
+
+// Clear remove references between the owner and its associations without deleting them, foreign keys of belongs to,
+// has one and has many relations are set to null, join records of many2many relations are deleted, and the owner's
+// field is zeroed, e.g: nil for pointers & slices, the database isn't touched if owners have no primary key
+func (association *Association) Clear() error {
+	association.tag("clear")
+	if association.Error == nil {
+		association.Error = association.checkWritable()
+	}
+	if association.Error == nil {
+		if association.Relationship.Type == schema.Many2ManyJSON {
+			association.Error = association.replace()
+		} else {
+			association.clear()
+		}
+	}
+	return association.wrapError("clear")
+}
+
+func (association *Association) clear() {
+	if association.Error = association.checkAddressableOwner(); association.Error != nil {
+		return
+	}
+
+	// restore the owner's field if failed, keeps it consistent with the database
+	defer association.restoreFieldsOnError(association.snapshotFields())
+
+	var (
+		rel          = association.Relationship
+		reflectValue = association.DB.Statement.ReflectValue
+	)
+
+	zeroField := func(owner reflect.Value) {
+		if association.Error == nil {
+			association.Error = rel.Field.Set(owner, reflect.Zero(rel.Field.FieldType).Interface())
+		}
+
+		if rel.Type == schema.BelongsTo {
+			for _, ref := range rel.References {
+				if !ref.OwnPrimaryKey && ref.PrimaryValue == "" && association.Error == nil {
+					association.Error = ref.ForeignKey.Set(owner, reflect.Zero(ref.ForeignKey.FieldType).Interface())
+				}
+			}
+		}
+	}
+
+	switch reflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < reflectValue.Len(); i++ {
+			zeroField(reflectValue.Index(i))
+		}
+	case reflect.Struct:
+		zeroField(reflectValue)
+	}
+
+	if association.Error == nil {
+		_, association.Error = association.deleteAll()
+	}
+}
+
+// DeleteAll detach all associations of the owners without deleting them or changing the owners in memory, foreign keys
+// of has one/has many associations and the owners's belongs to foreign keys are set to null, join records of many2many
+// associations are deleted, returns the number of affected records, use Clear to reset the owners's fields too
+func (association *Association) DeleteAll() (rowsAffected int64, err error) {
+	association.tag("delete")
+	if association.Error == nil {
+		association.Error = association.checkWritable()
+	}
+	if association.Error == nil {
+		if association.Relationship.Type == schema.Many2ManyJSON {
+			association.Error = fmt.Errorf("%w: delete all %v", ErrUnsupportedRelation, association.Relationship.Name)
+		} else {
+			rowsAffected, association.Error = association.deleteAll()
+		}
+	}
+	return rowsAffected, association.wrapError("delete")
+}
+
+// deleteAll detach all associations of the owners in the database, owners without primary keys are skipped
+func (association *Association) deleteAll() (int64, error) {
+	var (
+		rel          = association.Relationship
+		reflectValue = association.DB.Statement.ReflectValue
+		updateMap    = map[string]interface{}{}
+		tx           *DB
+	)
+
+	_, pvs := schema.GetIdentityFieldValuesMap(reflectValue, rel.Schema.PrimaryFields)
+	if len(pvs) == 0 {
+		return 0, nil
+	}
+
+	switch rel.Type {
+	case schema.BelongsTo:
+		column, values := schema.ToQueryValues(rel.Schema.Table, rel.Schema.PrimaryFieldDBNames, pvs)
+		tx = association.session().Model(reflect.New(rel.Schema.ModelType).Interface()).Where(clause.IN{Column: column, Values: values})
+		for _, ref := range rel.References {
+			if !ref.OwnPrimaryKey && ref.PrimaryValue == "" {
+				updateMap[ref.ForeignKey.DBName] = nil
+			}
+		}
+	case schema.HasOne, schema.HasMany:
+		tx = association.session().Model(reflect.New(rel.FieldSchema.ModelType).Interface()).Where(clause.Where{Exprs: rel.ToQueryConditions(reflectValue)})
+		for _, ref := range rel.References {
+			// also clear polymorphic type, otherwise detached records still look like owned by the owner's type
+			updateMap[ref.ForeignKey.DBName] = nil
+		}
+	case schema.Many2Many:
+		var (
+			primaryFields   []*schema.Field
+			joinPrimaryKeys []string
+			modelValue      = reflect.New(rel.JoinTable.ModelType).Interface()
+		)
+
+		tx = association.session().Model(modelValue)
+		for _, ref := range rel.References {
+			if ref.PrimaryValue != "" {
+				tx.Clauses(clause.Eq{Column: ref.ForeignKey.DBName, Value: ref.PrimaryValue})
+			} else if ref.OwnPrimaryKey {
+				primaryFields = append(primaryFields, ref.PrimaryKey)
+				joinPrimaryKeys = append(joinPrimaryKeys, ref.ForeignKey.DBName)
+			}
+		}
+
+		if len(association.joinConds) > 0 {
+			tx.Clauses(clause.Where{Exprs: association.joinConds})
+		}
+
+		_, ownerValues := schema.GetIdentityFieldValuesMap(reflectValue, primaryFields)
+		column, values := schema.ToQueryValues(rel.JoinTable.Table, joinPrimaryKeys, ownerValues)
+		result := tx.Where(clause.IN{Column: column, Values: values}).Delete(modelValue)
+		return result.RowsAffected, result.Error
+	}
+
+	result := tx.UpdateColumns(updateMap)
+	return result.RowsAffected, result.Error
+}
+
+// Count count associations matching conds, which are ANDed with the relationship's conditions like Find's,
+// e.g: db.Model(&user).Association("Orders").Count("status = ?", "paid")
+func (association *Association) Count(conds ...interface{}) (count int64) {
+	count, _ = association.CountI64(conds...)
+	return
+}
+
+// CountI64 count associations matching conds, returns the count and error directly
+func (association *Association) CountI64(conds ...interface{}) (count int64, err error) {
+	association.tag("count")
+	if association.Error == nil {
+		tx, queryConds := withoutLimit(association.buildCondition()).splitClauses(conds)
+		if queryConds = association.qualifyConds(tx, queryConds); len(queryConds) > 0 {
+			tx = tx.Where(queryConds[0], queryConds[1:]...)
+		}
+		association.Error = tx.Count(&count).Error
+	}
+	return count, association.wrapError("count")
+}
+
+// CountUpTo count associations up to limit, it stops counting once limit associations matched by counting a subquery
+// with LIMIT, e.g: show "99+" when db.Model(&user).Association("Roles").CountUpTo(100) returns 100, non positive limit
+// counts all associations like Count
+func (association *Association) CountUpTo(limit int64) (count int64, err error) {
+	if limit <= 0 {
+		return association.CountI64()
+	}
+
+	association.tag("count")
+	if association.Error == nil {
+		subQuery := withoutLimit(association.buildCondition()).Select("1").Limit(int(limit))
+		association.Error = association.newSession().Clauses(resolverHint(ResolverReadHint)).
+			Table("(?) AS bounded_associations", subQuery).Count(&count).Error
+	}
+	return count, association.wrapError("count")
+}
+
+// CountEach count associations of each owner with a grouped query, returns a map from the owner's primary key to its count,
+// owners without associations are counted as 0, primary keys of owners with composite primary keys are joined as string keys
+func (association *Association) CountEach() (counts map[interface{}]int64, err error) {
+	association.tag("count")
+	if association.Error == nil {
+		counts, association.Error = association.countEach()
+	}
+	return counts, association.wrapError("count")
+}
+
+func (association *Association) countEach() (map[interface{}]int64, error) {
+	var (
+		rel           = association.Relationship
+		reflectValue  = association.DB.Statement.ReflectValue
+		ownerSchema   = association.DB.Statement.Schema
+		table         = rel.FieldSchema.Table
+		ownerFields   []*schema.Field
+		groupColumns  []clause.Column
+		groupedCounts = map[string]int64{}
+		counts        = map[interface{}]int64{}
+	)
+
+	if rel.Type == schema.Many2ManyJSON {
+		return nil, fmt.Errorf("%w: count each %v", ErrUnsupportedRelation, rel.Name)
+	}
+
+	if rel.JoinTable != nil {
+		table = rel.JoinTable.Table
+	}
+
+	for _, ref := range rel.References {
+		switch {
+		case ref.OwnPrimaryKey:
+			ownerFields = append(ownerFields, ref.PrimaryKey)
+			groupColumns = append(groupColumns, clause.Column{Table: table, Name: ref.ForeignKey.DBName})
+		case ref.PrimaryValue == "" && rel.JoinTable == nil:
+			ownerFields = append(ownerFields, ref.ForeignKey)
+			groupColumns = append(groupColumns, clause.Column{Table: table, Name: ref.PrimaryKey.DBName})
+		}
+	}
+
+	selectColumns := append(append([]clause.Column{}, groupColumns...), clause.Column{Name: "count(1)", Raw: true})
+	rows, err := withoutLimit(association.buildCondition()).Clauses(clause.Select{Columns: selectColumns}, clause.GroupBy{Columns: groupColumns}).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			values = make([]interface{}, len(groupColumns))
+			dests  = make([]interface{}, len(groupColumns)+1)
+			count  int64
+		)
+
+		for idx := range values {
+			dests[idx] = &values[idx]
+		}
+		dests[len(values)] = &count
+
+		if err := rows.Scan(dests...); err != nil {
+			return nil, err
+		}
+		groupedCounts[utils.ToStringKey(values...)] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	countOwner := func(owner reflect.Value) {
+		var (
+			primaryValues = make([]interface{}, len(ownerSchema.PrimaryFields))
+			ownerValues   = make([]interface{}, len(ownerFields))
+			key           interface{}
+		)
+
+		for idx, field := range ownerSchema.PrimaryFields {
+			primaryValues[idx], _ = field.ValueOf(owner)
+		}
+
+		if key = utils.ToStringKey(primaryValues...); len(primaryValues) == 1 {
+			key = primaryValues[0]
+		}
+
+		counts[key] = 0
+		for idx, field := range ownerFields {
+			var zero bool
+			if ownerValues[idx], zero = field.ValueOf(owner); zero {
+				return
+			}
+		}
+		counts[key] = groupedCounts[utils.ToStringKey(ownerValues...)]
+	}
+
+	switch reflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < reflectValue.Len(); i++ {
+			if owner := reflect.Indirect(reflectValue.Index(i)); owner.Kind() == reflect.Struct {
+				countOwner(owner)
+			}
+		}
+	case reflect.Struct:
+		countOwner(reflectValue)
+	}
+
+	return counts, nil
+}
+
+// Exists check whether there are any associations, the query stops at the first matched record rather than counting all of them
+func (association *Association) Exists() (exists bool, err error) {
+	association.tag("exists")
+	if association.Error == nil {
+		var result int
+		tx := association.buildCondition().Select("1").Limit(1).Find(&result)
+		association.Error, exists = tx.Error, tx.RowsAffected > 0
+	}
+	return exists, association.wrapError("exists")
+}
+
+// QueryConditions returns conditions used to query associations without executing anything, they're built the same
+// as Find, for relations with join tables, they include join conditions with the join table or its alias, and the join
+// table's soft delete scope, which should be added to FROM by the caller, the conditions are bound to the owner's
+// current values
+func (association *Association) QueryConditions() ([]clause.Expression, error) {
+	if association.Error != nil {
+		return nil, association.Error
+	}
+
+	var (
+		tx    = association.buildCondition()
+		conds []clause.Expression
+	)
+
+	if from, ok := tx.Statement.Clauses["FROM"].Expression.(clause.From); ok {
+		for _, join := range from.Joins {
+			conds = append(conds, join.ON.Exprs...)
+		}
+	}
+	if where, ok := tx.Statement.Clauses["WHERE"].Expression.(clause.Where); ok {
+		conds = append(conds, where.Exprs...)
+	}
+	return conds, tx.Error
+}
+
+// Rows returns rows of associations with the same conditions as Find, scan them with DB.ScanRows,
+// the caller should close the rows after iterating
+func (association *Association) Rows() (*sql.Rows, error) {
+	association.tag("rows")
+	if association.Error != nil {
+		return nil, association.wrapError("rows")
+	}
+
+	rows, err := association.qualifySelects(association.buildCondition()).Rows()
+	if err != nil {
+		association.Error = err
+	}
+	return rows, association.wrapError("rows")
+}
+
+type assignBack struct {
+	Source reflect.Value
+	Index  int
+	Dest   reflect.Value
+}
+
+func (association *Association) saveAssociation(clear bool, values ...interface{}) {
+	var (
+		reflectValue = association.DB.Statement.ReflectValue
+		assignBacks  []assignBack // assign association values back to arguments after save
+	)
+
+	appendToRelations := func(source, rv reflect.Value, clear bool) {
+		// values passed as structs are not addressable, append their copies
+		if rv.Kind() == reflect.Struct && !rv.CanAddr() {
+			addressable := reflect.New(rv.Type()).Elem()
+			addressable.Set(rv)
+			rv = addressable
+		}
+
+		switch association.Relationship.Type {
+		case schema.HasOne, schema.BelongsTo:
+			switch rv.Kind() {
+			case reflect.Slice, reflect.Array:
+				if rv.Len() > 0 {
+					if association.Error = association.callLinkCallbacks(source, rv.Index(0)); association.Error != nil {
+						return
+					}
+					association.Error = association.Relationship.Field.Set(source, rv.Index(0).Addr().Interface())
+
+					if association.Relationship.Field.FieldType.Kind() == reflect.Struct {
+						assignBacks = append(assignBacks, assignBack{Source: source, Dest: rv.Index(0)})
+					}
+				}
+			case reflect.Struct:
+				if association.Error = association.callLinkCallbacks(source, rv); association.Error != nil {
+					return
+				}
+				association.Error = association.Relationship.Field.Set(source, rv.Addr().Interface())
+
+				if association.Relationship.Field.FieldType.Kind() == reflect.Struct {
+					assignBacks = append(assignBacks, assignBack{Source: source, Dest: rv})
+				}
+			}
 		case schema.HasMany, schema.Many2Many:
 			elemType := association.Relationship.Field.IndirectFieldType.Elem()
 			fieldValue := reflect.Indirect(association.Relationship.Field.ReflectValueOf(source))
@@ -348,121 +2471,1193 @@ func (association *Association) saveAssociation(clear bool, values ...interface{
 				fieldValue = reflect.New(association.Relationship.Field.IndirectFieldType).Elem()
 			}
 
-			appendToFieldValues := func(ev reflect.Value) {
-				if ev.Type().AssignableTo(elemType) {
-					fieldValue = reflect.Append(fieldValue, ev)
-				} else if ev.Type().Elem().AssignableTo(elemType) {
-					fieldValue = reflect.Append(fieldValue, ev.Elem())
-				} else {
-					association.Error = fmt.Errorf("unsupported data type: %v for relation %v", ev.Type(), association.Relationship.Name)
+			appendToFieldValues := func(ev reflect.Value) {
+				if association.Error != nil {
+					return
+				} else if association.Error = association.callLinkCallbacks(source, reflect.Indirect(ev)); association.Error != nil {
+					return
+				}
+
+				if ev.Type().AssignableTo(elemType) {
+					fieldValue = reflect.Append(fieldValue, ev)
+				} else if ev.Type().Elem().AssignableTo(elemType) {
+					fieldValue = reflect.Append(fieldValue, ev.Elem())
+				} else {
+					association.Error = fmt.Errorf("%w: relation %v expects %v, but got %v", ErrInvalidData, association.Relationship.Name, elemType, ev.Type())
+				}
+
+				if elemType.Kind() == reflect.Struct {
+					assignBacks = append(assignBacks, assignBack{Source: source, Dest: ev, Index: fieldValue.Len()})
+				}
+			}
+
+			switch rv.Kind() {
+			case reflect.Slice, reflect.Array:
+				for i := 0; i < rv.Len(); i++ {
+					appendToFieldValues(reflect.Indirect(rv.Index(i)).Addr())
+				}
+			case reflect.Struct:
+				appendToFieldValues(rv.Addr())
+			}
+
+			if association.Error == nil {
+				association.Error = association.Relationship.Field.Set(source, fieldValue.Interface())
+			}
+		}
+	}
+
+	// created associations's primary keys & default values are filled by the create callback (with RETURNING for dialects
+	// supporting it, otherwise with the last insert id), assign them back to the passed values
+	assignBackValues := func() {
+		for _, assignBack := range assignBacks {
+			fieldValue := reflect.Indirect(association.Relationship.Field.ReflectValueOf(assignBack.Source))
+			if assignBack.Index > 0 {
+				reflect.Indirect(assignBack.Dest).Set(fieldValue.Index(assignBack.Index - 1))
+			} else {
+				reflect.Indirect(assignBack.Dest).Set(fieldValue)
+			}
+		}
+		assignBacks = nil
+	}
+
+	if association.Error = association.validateValues(values...); association.Error != nil {
+		return
+	}
+
+	if association.Error = association.checkAddressableOwner(); association.Error != nil {
+		return
+	}
+
+	if len(values) > 0 {
+		if association.Error = association.checkDeletedOwner(); association.Error != nil {
+			return
+		}
+	}
+
+	if association.Relationship.IsSelfReferential() {
+		if association.Error = association.checkSelfReferences(values...); association.Error != nil {
+			return
+		}
+	}
+
+	// only the relation and its foreign keys of the owner are selected, so no UPDATE is issued for the owner
+	// if there are no owner's foreign keys to update, e.g: appending has many, many2many associations
+	selectedSaveColumns := []string{association.Relationship.Name}
+	for _, ref := range association.Relationship.References {
+		if !ref.OwnPrimaryKey {
+			selectedSaveColumns = append(selectedSaveColumns, ref.ForeignKey.Name)
+		}
+	}
+
+	// the owner is updated by its primary keys, for composite primary keys, select all of them, so an omitted key
+	// won't leave the owner's UPDATE with an incomplete WHERE matching other owners
+	if primaryFields := association.Relationship.Schema.PrimaryFields; len(primaryFields) > 1 {
+		var hasZero bool
+		checkZero := func(owner reflect.Value) {
+			for _, field := range primaryFields {
+				if _, isZero := field.ValueOf(reflect.Indirect(owner)); isZero {
+					hasZero = true
+				}
+			}
+		}
+
+		if reflectValue.Kind() == reflect.Struct {
+			checkZero(reflectValue)
+		} else {
+			for i := 0; i < reflectValue.Len(); i++ {
+				checkZero(reflectValue.Index(i))
+			}
+		}
+
+		if !hasZero {
+			for _, field := range primaryFields {
+				selectedSaveColumns = append(selectedSaveColumns, field.Name)
+			}
+		}
+	}
+
+	// merge user's select, omit columns, the relation and its foreign keys are always saved
+	var omittedSaveColumns []string
+	for _, column := range association.DB.Statement.Omits {
+		if !utils.Contains(selectedSaveColumns, column) {
+			omittedSaveColumns = append(omittedSaveColumns, column)
+		}
+	}
+	selectedSaveColumns = append(selectedSaveColumns, association.DB.Statement.Selects...)
+
+	// owners are saved like DB.Save, owners with zero primary keys are created with their create hooks and all columns,
+	// others are updated with their update hooks and the selected columns only
+	saveDB := association.saveDB()
+	saveOwner := func(tx *DB, owner reflect.Value) error {
+		for _, field := range association.Relationship.Schema.PrimaryFields {
+			if _, isZero := field.ValueOf(owner); isZero {
+				tx = tx.Omit(omittedSaveColumns...)
+				if len(association.DB.Statement.Selects) > 0 {
+					tx = tx.Select(selectedSaveColumns)
+				}
+				return tx.Create(owner.Addr().Interface()).Error
+			}
+		}
+
+		if skip, ok := association.DB.Get("gorm:association:skip_owner_save"); ok && skip == true && association.Relationship.Type == schema.HasMany &&
+			len(association.DB.Statement.Selects) == 0 && len(association.DB.Statement.Omits) == 0 {
+			return association.saveHasMany(tx, owner)
+		}
+		return tx.Select(selectedSaveColumns).Omit(omittedSaveColumns...).Model(nil).Updates(owner.Addr().Interface()).Error
+	}
+
+	// has many, many2many associations are created in batches of the session's CreateBatchSize, or under the dialect's
+	// placeholder limit, the owner's field holds one batch when saving it, all batches are saved in one transaction
+	batchSize := association.createBatchSize(values...)
+	saveInBatches := func(owner reflect.Value, clear bool, values ...interface{}) error {
+		var elems []reflect.Value
+		for _, value := range values {
+			if rv := reflect.Indirect(reflect.ValueOf(value)); rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+				for i := 0; i < rv.Len(); i++ {
+					elems = append(elems, reflect.Indirect(rv.Index(i)))
+				}
+			} else {
+				elems = append(elems, rv)
+			}
+		}
+
+		fieldValue := reflect.New(association.Relationship.Field.IndirectFieldType).Elem()
+		if !clear {
+			fieldValue = reflect.AppendSlice(fieldValue, reflect.Indirect(association.Relationship.Field.ReflectValueOf(owner)))
+		}
+
+		err := saveDB.Transaction(func(tx *DB) error {
+			for i := 0; i < len(elems); i += batchSize {
+				ends := i + batchSize
+				if ends > len(elems) {
+					ends = len(elems)
+				}
+
+				for idx, elem := range elems[i:ends] {
+					appendToRelations(owner, elem, idx == 0)
+				}
+
+				if association.Error != nil {
+					return association.Error
+				} else if err := saveOwner(tx, owner); err != nil {
+					return err
+				}
+
+				assignBackValues()
+				fieldValue = reflect.AppendSlice(fieldValue, reflect.Indirect(association.Relationship.Field.ReflectValueOf(owner)))
+			}
+			return nil
+		})
+
+		if err == nil {
+			err = association.Relationship.Field.Set(owner, fieldValue.Interface())
+		}
+		return err
+	}
+
+	switch reflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		if association.broadcast && len(values) > 0 {
+			for i := 0; i < reflectValue.Len() && association.Error == nil; i++ {
+				if batchSize > 0 {
+					association.Error = saveInBatches(reflectValue.Index(i), clear, values...)
+					continue
+				}
+
+				for idx, value := range values {
+					appendToRelations(reflectValue.Index(i), reflect.Indirect(reflect.ValueOf(value)), clear && idx == 0)
+				}
+
+				if association.Error == nil {
+					association.Error = saveOwner(saveDB, reflectValue.Index(i))
+				}
+
+				// values created for the first owner are linked to the others
+				assignBackValues()
+			}
+			break
+		}
+
+		if len(values) != reflectValue.Len() {
+			// clear old data
+			if clear && len(values) == 0 {
+				for i := 0; i < reflectValue.Len(); i++ {
+					if err := association.Relationship.Field.Set(reflectValue.Index(i), reflect.New(association.Relationship.Field.IndirectFieldType).Interface()); err != nil {
+						association.Error = err
+						break
+					}
+
+					if association.Relationship.JoinTable == nil {
+						for _, ref := range association.Relationship.References {
+							if !ref.OwnPrimaryKey && ref.PrimaryValue == "" {
+								if err := ref.ForeignKey.Set(reflectValue.Index(i), reflect.Zero(ref.ForeignKey.FieldType).Interface()); err != nil {
+									association.Error = err
+									break
+								}
+							}
+						}
+					}
+				}
+				break
+			}
+
+			association.Error = fmt.Errorf("invalid association values, length doesn't match, expects %v values but got %v", reflectValue.Len(), len(values))
+			return
+		}
+
+		for i := 0; i < reflectValue.Len() && association.Error == nil; i++ {
+			if batchSize > 0 {
+				association.Error = saveInBatches(reflectValue.Index(i), clear, values[i])
+				continue
+			}
+
+			appendToRelations(reflectValue.Index(i), reflect.Indirect(reflect.ValueOf(values[i])), clear)
+
+			// TODO support save slice data, sql with case?
+			if association.Error == nil {
+				association.Error = saveOwner(saveDB, reflectValue.Index(i))
+			}
+		}
+	case reflect.Struct:
+		// clear old data
+		if clear && len(values) == 0 {
+			association.Error = association.Relationship.Field.Set(reflectValue, reflect.New(association.Relationship.Field.IndirectFieldType).Interface())
+
+			if association.Relationship.JoinTable == nil && association.Error == nil {
+				for _, ref := range association.Relationship.References {
+					if !ref.OwnPrimaryKey && ref.PrimaryValue == "" {
+						association.Error = ref.ForeignKey.Set(reflectValue, reflect.Zero(ref.ForeignKey.FieldType).Interface())
+					}
+				}
+			}
+		}
+
+		if batchSize > 0 && association.Error == nil {
+			association.Error = saveInBatches(reflectValue, clear, values...)
+			break
+		}
+
+		for idx, value := range values {
+			rv := reflect.Indirect(reflect.ValueOf(value))
+			appendToRelations(reflectValue, rv, clear && idx == 0)
+		}
+
+		if len(values) > 0 && association.Error == nil {
+			association.Error = saveOwner(saveDB, reflectValue)
+		}
+	}
+
+	assignBackValues()
+}
+
+// placeholder limit of sqlite, whose dialector doesn't implement PlaceholderLimitDialectorInterface
+const sqlitePlaceholderLimit = 999
+
+// saveHasMany saves the owner's has many associations without saving the owner, which is used when
+// "gorm:association:skip_owner_save" is true, so neither the owner's UPDATE nor its hooks are issued
+func (association *Association) saveHasMany(tx *DB, owner reflect.Value) error {
+	var (
+		rel               = association.Relationship
+		fieldValue        = reflect.Indirect(rel.Field.ReflectValueOf(owner))
+		elems             = reflect.MakeSlice(reflect.SliceOf(reflect.PtrTo(rel.FieldSchema.ModelType)), 0, fieldValue.Len())
+		assignmentColumns []string
+		conflictColumns   []clause.Column
+	)
+
+	for i := 0; i < fieldValue.Len(); i++ {
+		elem := reflect.Indirect(fieldValue.Index(i))
+		for _, ref := range rel.References {
+			if ref.OwnPrimaryKey {
+				pv, _ := ref.PrimaryKey.ValueOf(owner)
+				ref.ForeignKey.Set(elem, pv)
+			} else if ref.PrimaryValue != "" {
+				ref.ForeignKey.Set(elem, ref.PrimaryValue)
+			}
+		}
+		elems = reflect.Append(elems, elem.Addr())
+	}
+
+	if elems.Len() == 0 {
+		return nil
+	}
+
+	for _, ref := range rel.References {
+		assignmentColumns = append(assignmentColumns, ref.ForeignKey.DBName)
+	}
+	for _, dbName := range rel.FieldSchema.PrimaryFieldDBNames {
+		conflictColumns = append(conflictColumns, clause.Column{Name: dbName})
+	}
+
+	// associations that already exist are linked to the owner by updating their foreign keys like saving the owner
+	return tx.Session(&Session{NewDB: true}).Clauses(clause.OnConflict{Columns: conflictColumns, DoUpdates: clause.AssignmentColumns(assignmentColumns)}).Create(elems.Interface()).Error
+}
+
+// createBatchSize returns the batch size to create has many, many2many values, which is the smaller one of the session's
+// CreateBatchSize and the batch size under the dialect's placeholder limit, returns 0 if values fit in one batch or
+// neither of them is known
+func (association *Association) createBatchSize(values ...interface{}) int {
+	if rel := association.Relationship; rel.Type != schema.HasMany && rel.Type != schema.Many2Many {
+		return 0
+	}
+
+	var count int
+	for _, value := range values {
+		if rv := reflect.Indirect(reflect.ValueOf(value)); rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+			count += rv.Len()
+		} else {
+			count++
+		}
+	}
+
+	batchSize := association.placeholderBatchSize()
+	if size := association.DB.CreateBatchSize; size > 0 && (batchSize == 0 || size < batchSize) {
+		batchSize = size
+	}
+
+	if batchSize == 0 || count <= batchSize {
+		return 0
+	}
+	return batchSize
+}
+
+// placeholderBatchSize returns the max number of associations or join records created in one statement under the
+// dialect's placeholder limit, returns 0 if the limit is unknown
+func (association *Association) placeholderBatchSize() int {
+	var (
+		rel     = association.Relationship
+		columns = len(rel.FieldSchema.DBNames)
+		limit   int
+	)
+
+	if rel.JoinTable != nil && len(rel.JoinTable.DBNames) > columns {
+		columns = len(rel.JoinTable.DBNames)
+	}
+
+	if dialector, ok := association.DB.Dialector.(PlaceholderLimitDialectorInterface); ok {
+		limit = dialector.MaxPlaceholders()
+	} else if association.DB.Dialector.Name() == "sqlite" {
+		limit = sqlitePlaceholderLimit
+	}
+
+	if limit <= 0 {
+		return 0
+	} else if columns > 0 && limit/columns > 1 {
+		return limit / columns
+	}
+	return 1
+}
+
+// valuesFromMaps replaces maps in values with new associations whose fields are assigned from the maps's values,
+// keys of the maps could be field names or columns of the associations
+func (association *Association) valuesFromMaps(values ...interface{}) ([]interface{}, error) {
+	var (
+		rel      = association.Relationship
+		results  = make([]interface{}, 0, len(values))
+		fromMaps = func(maps ...map[string]interface{}) error {
+			for _, m := range maps {
+				value := reflect.New(rel.FieldSchema.ModelType)
+				for key, v := range m {
+					field := rel.FieldSchema.LookUpField(key)
+					if field == nil || field.DBName == "" {
+						return fmt.Errorf("%w: %v for relation %v", ErrInvalidField, key, rel.Name)
+					}
+
+					if err := field.Set(value, v); err != nil {
+						return err
+					}
+				}
+				results = append(results, value.Interface())
+			}
+			return nil
+		}
+	)
+
+	for _, value := range values {
+		var err error
+		switch v := value.(type) {
+		case map[string]interface{}:
+			err = fromMaps(v)
+		case *map[string]interface{}:
+			err = fromMaps(*v)
+		case []map[string]interface{}:
+			err = fromMaps(v...)
+		case *[]map[string]interface{}:
+			err = fromMaps(*v...)
+		default:
+			results = append(results, value)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// validateValues make sure association values match the relationship before writing anything
+func (association *Association) validateValues(values ...interface{}) error {
+	var (
+		rel    = association.Relationship
+		single = rel.Type == schema.HasOne || rel.Type == schema.BelongsTo
+	)
+
+	if single && len(values) > 1 && association.DB.Statement.ReflectValue.Kind() == reflect.Struct {
+		return fmt.Errorf("invalid association values, %v relation %v accepts one value, but got %v", rel.Type, rel.Name, len(values))
+	}
+
+	for _, value := range values {
+		var (
+			rv        = reflect.Indirect(reflect.ValueOf(value))
+			valueType reflect.Type
+		)
+
+		switch rv.Kind() {
+		case reflect.Slice, reflect.Array:
+			if single && rv.Len() > 1 {
+				return fmt.Errorf("invalid association values, %v relation %v accepts one value, but got %v", rel.Type, rel.Name, rv.Len())
+			}
+
+			for valueType = rv.Type().Elem(); valueType.Kind() == reflect.Ptr; {
+				valueType = valueType.Elem()
+			}
+		case reflect.Struct:
+			valueType = rv.Type()
+		}
+
+		// values of types not parsed into the relation's schema can't be assigned to the owner's field
+		if valueType != rel.FieldSchema.ModelType {
+			return fmt.Errorf("%w: relation %v expects %v or %v, but got %v", ErrInvalidData, rel.Name, rel.FieldSchema.ModelType, reflect.PtrTo(rel.FieldSchema.ModelType), reflect.TypeOf(value))
+		}
+	}
+
+	return nil
+}
+
+// checkAddressableOwner make sure the owner's relation field could be updated with changed associations
+func (association *Association) checkAddressableOwner() error {
+	if reflectValue := association.DB.Statement.ReflectValue; reflectValue.Kind() == reflect.Struct && !reflectValue.CanAddr() {
+		return fmt.Errorf("%w: owner of %v should be a pointer, but got %v", ErrInvalidData, association.Relationship.Name, reflectValue.Type())
+	}
+	return nil
+}
+
+// checkWritable make sure associations of the relation could be written, has many through associations are linked by
+// the intermediate relation, so they could be queried only, the owner of nested associations is loaded to be written
+func (association *Association) checkWritable() error {
+	if association.Relationship.Type == schema.HasManyThrough {
+		return fmt.Errorf("%w: %v is a has many through relation", ErrUnsupportedRelation, association.Relationship.Name)
+	} else if association.hop != nil {
+		return association.hop.buildCondition().Take(association.DB.Statement.Model).Error
+	}
+	return nil
+}
+
+// ownerConditions returns conditions matching associations of the owner, owners of nested associations are selected
+// with a subquery of the previous hop, so they aren't loaded when querying associations
+func (association *Association) ownerConditions() []clause.Expression {
+	rel := association.Relationship
+	if association.hop == nil {
+		return rel.ToQueryConditions(association.DB.Statement.ReflectValue)
+	}
+
+	return rel.ToSubQueryConditions(func(fields []*schema.Field) interface{} {
+		columns := make([]clause.Column, len(fields))
+		for idx, field := range fields {
+			columns[idx] = clause.Column{Table: rel.Schema.Table, Name: field.DBName}
+		}
+		return association.hop.buildCondition().Clauses(clause.Select{Columns: columns})
+	})
+}
+
+// checkDeletedOwner make sure associations won't be saved for soft deleted owners,
+// set "gorm:association:allow_deleted_owner" to true to skip the check
+func (association *Association) checkDeletedOwner() error {
+	if allow, ok := association.DB.Get("gorm:association:allow_deleted_owner"); ok && allow == true {
+		return nil
+	}
+
+	var (
+		reflectValue = association.DB.Statement.ReflectValue
+		deletedAt    *schema.Field
+	)
+
+	for _, field := range association.DB.Statement.Schema.Fields {
+		if field.FieldType == reflect.TypeOf(DeletedAt{}) {
+			deletedAt = field
+			break
+		}
+	}
+
+	if deletedAt == nil {
+		return nil
+	}
+
+	checkDeleted := func(rv reflect.Value) error {
+		if _, zero := deletedAt.ValueOf(rv); !zero {
+			return fmt.Errorf("%w: can't save %v for %v", ErrDeletedOwner, association.Relationship.Name, association.DB.Statement.Schema.Name)
+		}
+		return nil
+	}
+
+	switch reflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < reflectValue.Len(); i++ {
+			if rv := reflect.Indirect(reflectValue.Index(i)); rv.Kind() == reflect.Struct {
+				if err := checkDeleted(rv); err != nil {
+					return err
 				}
+			}
+		}
+	case reflect.Struct:
+		return checkDeleted(reflectValue)
+	}
 
-				if elemType.Kind() == reflect.Struct {
-					assignBacks = append(assignBacks, assignBack{Source: source, Dest: ev, Index: fieldValue.Len()})
+	return nil
+}
+
+// checkSelfReferences make sure records won't be associated to themselves for self-referential relationships
+func (association *Association) checkSelfReferences(values ...interface{}) error {
+	var (
+		reflectValue  = association.DB.Statement.ReflectValue
+		primaryFields = association.DB.Statement.Schema.PrimaryFields
+	)
+
+	primaryKey := func(rv reflect.Value) (string, bool) {
+		primaryValues := make([]interface{}, len(primaryFields))
+		notZero := false
+		for idx, field := range primaryFields {
+			var zero bool
+			primaryValues[idx], zero = field.ValueOf(rv)
+			notZero = notZero || !zero
+		}
+		return utils.ToStringKey(primaryValues...), notZero
+	}
+
+	checkSelfReference := func(source reflect.Value, value interface{}) error {
+		sourceKey, sourceNotZero := primaryKey(source)
+
+		check := func(rv reflect.Value) error {
+			rv = reflect.Indirect(rv)
+			if rv.Kind() != reflect.Struct {
+				return nil
+			}
+
+			if rv.CanAddr() && source.CanAddr() && rv.Addr().Pointer() == source.Addr().Pointer() {
+				return fmt.Errorf("invalid association values, can't associate %v to itself for relation %v", association.DB.Statement.Schema.Name, association.Relationship.Name)
+			}
+
+			if key, notZero := primaryKey(rv); sourceNotZero && notZero && key == sourceKey {
+				return fmt.Errorf("invalid association values, can't associate %v with primary key %v to itself for relation %v", association.DB.Statement.Schema.Name, key, association.Relationship.Name)
+			}
+			return nil
+		}
+
+		rv := reflect.Indirect(reflect.ValueOf(value))
+		switch rv.Kind() {
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < rv.Len(); i++ {
+				if err := check(rv.Index(i)); err != nil {
+					return err
 				}
 			}
+		default:
+			return check(rv)
+		}
+		return nil
+	}
 
-			switch rv.Kind() {
-			case reflect.Slice, reflect.Array:
-				for i := 0; i < rv.Len(); i++ {
-					appendToFieldValues(reflect.Indirect(rv.Index(i)).Addr())
+	switch reflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		if len(values) == reflectValue.Len() {
+			for i := 0; i < reflectValue.Len(); i++ {
+				if err := checkSelfReference(reflect.Indirect(reflectValue.Index(i)), values[i]); err != nil {
+					return err
 				}
-			case reflect.Struct:
-				appendToFieldValues(rv.Addr())
 			}
+		}
+	case reflect.Struct:
+		for _, value := range values {
+			if err := checkSelfReference(reflectValue, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// saveJSONKeys appends, replaces or deletes primary keys of values in the owner's json array column of many2many json relations,
+// values without primary keys are created first, returns the number of deleted keys
+func (association *Association) saveJSONKeys(operation string, values ...interface{}) (rowsAffected int64, err error) {
+	var (
+		rel          = association.Relationship
+		ref          = rel.References[0]
+		reflectValue = association.DB.Statement.ReflectValue
+		keys         = []interface{}{}
+		keysMap      = map[string]bool{}
+		valuesMap    = map[string]bool{}
+	)
+
+	if reflectValue.Kind() != reflect.Struct {
+		return 0, fmt.Errorf("%w: %v %v for multiple owners", ErrUnsupportedRelation, operation, rel.Name)
+	}
+
+	if err = association.checkAddressableOwner(); err != nil {
+		return 0, err
+	}
+
+	if err = association.validateValues(values...); err != nil {
+		return 0, err
+	}
+
+	if operation != "replace" {
+		ownerKeys, err := decodeJSONKeys(ref.ForeignKey.ReflectValueOf(reflectValue).Interface())
+		if err != nil {
+			return 0, fmt.Errorf("%w: %v of %v, got error %v", ErrInvalidData, ref.ForeignKey.Name, rel.Name, err)
+		}
+
+		for _, key := range ownerKeys {
+			keysMap[fmt.Sprint(key)] = true
+			keys = append(keys, key)
+		}
+	}
+
+	elems := addressableValues(values...)
+	err = association.saveDB().Transaction(func(tx *DB) error {
+		for _, elem := range elems {
+			key, zero := ref.PrimaryKey.ValueOf(elem)
+			if zero && operation != "delete" {
+				if err := tx.Omit(clause.Associations).Model(nil).Create(elem.Addr().Interface()).Error; err != nil {
+					return err
+				}
+				key, _ = ref.PrimaryKey.ValueOf(elem)
+			}
+
+			if valuesMap[fmt.Sprint(key)] = true; operation != "delete" && !keysMap[fmt.Sprint(key)] {
+				keysMap[fmt.Sprint(key)] = true
+				keys = append(keys, key)
+			}
+		}
+
+		if operation == "delete" {
+			savedKeys := make([]interface{}, 0, len(keys))
+			for _, key := range keys {
+				if !valuesMap[fmt.Sprint(key)] {
+					savedKeys = append(savedKeys, key)
+				}
+			}
+			rowsAffected, keys = int64(len(keys)-len(savedKeys)), savedKeys
+		}
+
+		data, err := json.Marshal(keys)
+		if err != nil {
+			return err
+		}
+
+		var jsonValue interface{} = data
+		if ref.ForeignKey.IndirectFieldType.Kind() == reflect.String {
+			jsonValue = string(data)
+		}
+
+		if err = tx.Model(reflectValue.Addr().Interface()).UpdateColumn(ref.ForeignKey.DBName, jsonValue).Error; err == nil && !association.keepsFields() {
+			err = ref.ForeignKey.Set(reflectValue, jsonValue)
+		}
+		return err
+	})
+
+	if err != nil || association.keepsFields() {
+		return rowsAffected, err
+	}
+
+	if operation != "delete" {
+		return rowsAffected, association.setFieldValues(elems, operation == "replace")
+	}
+
+	// remove deleted values from the owner's relation field
+	var (
+		current    = reflect.Indirect(rel.Field.ReflectValueOf(reflectValue))
+		fieldValue = reflect.MakeSlice(rel.Field.IndirectFieldType, 0, current.Len())
+	)
+	for i := 0; i < current.Len(); i++ {
+		if key, _ := ref.PrimaryKey.ValueOf(reflect.Indirect(current.Index(i))); !valuesMap[fmt.Sprint(key)] {
+			fieldValue = reflect.Append(fieldValue, current.Index(i))
+		}
+	}
+	return rowsAffected, rel.Field.Set(reflectValue, fieldValue.Interface())
+}
+
+// updatePositions updates positions of owner's join table records linking targets to their indexes in targets,
+// it's used for many2many relations with tag `position`
+func (association *Association) updatePositions(owner reflect.Value, targets []reflect.Value) error {
+	var (
+		rel             = association.Relationship
+		positionField   = rel.PositionField()
+		joinAssociation = association.newSession().Model(owner.Addr().Interface()).JoinAssociation(rel.Name)
+	)
+
+	for idx, target := range targets {
+		if err := joinAssociation.Update(positionField.Name, idx, target.Addr().Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compactPositions renumbers positions of owner's join table records from 0 in their current order
+func (association *Association) compactPositions(owner reflect.Value) error {
+	var (
+		rel             = association.Relationship
+		positionField   = rel.PositionField()
+		joinAssociation = association.newSession().Model(owner.Addr().Interface()).JoinAssociation(rel.Name)
+		joins           = reflect.New(reflect.SliceOf(rel.JoinTable.ModelType))
+	)
+
+	tx, err := joinAssociation.buildCondition()
+	if err == nil {
+		err = tx.Order(clause.OrderByColumn{Column: clause.Column{Table: rel.JoinTable.Table, Name: positionField.DBName}}).Find(joins.Interface()).Error
+	}
+
+	for i := 0; i < joins.Elem().Len() && err == nil; i++ {
+		joinValue := joins.Elem().Index(i)
+		if position, _ := positionField.ValueOf(joinValue); utils.ToString(position) == strconv.Itoa(i) {
+			continue
+		}
+
+		target := reflect.New(rel.FieldSchema.ModelType).Elem()
+		for _, ref := range rel.References {
+			if !ref.OwnPrimaryKey && ref.PrimaryValue == "" {
+				fv, _ := ref.ForeignKey.ValueOf(joinValue)
+				if err = ref.PrimaryKey.Set(target, fv); err != nil {
+					return err
+				}
+			}
+		}
+		err = joinAssociation.Update(positionField.Name, i, target.Addr().Interface())
+	}
+	return err
+}
+
+// joinPositionSetter returns a function setting positions of new join table records when saving many2many relations
+// with tag `position`, positions start after the owner's last position, linked associations keep their positions
+func (association *Association) joinPositionSetter() func(*DB, reflect.Value) error {
+	var (
+		rel           = association.Relationship
+		positionField = rel.PositionField()
+		int64Type     = reflect.TypeOf(int64(0))
+		positions     = map[string]int64{}
+		linked        = map[string]bool{}
+	)
+
+	return func(db *DB, joinValue reflect.Value) error {
+		var (
+			conds                   []clause.Expression
+			ownerValues             []interface{}
+			ownerFields, joinFields []*schema.Field
+		)
+
+		for _, ref := range rel.References {
+			if ref.OwnPrimaryKey {
+				fv, _ := ref.ForeignKey.ValueOf(joinValue)
+				conds = append(conds, clause.Eq{Column: clause.Column{Table: rel.JoinTable.Table, Name: ref.ForeignKey.DBName}, Value: fv})
+				ownerValues = append(ownerValues, fv)
+				ownerFields = append(ownerFields, ref.ForeignKey)
+			} else if ref.PrimaryValue != "" {
+				conds = append(conds, clause.Eq{Column: clause.Column{Table: rel.JoinTable.Table, Name: ref.ForeignKey.DBName}, Value: ref.PrimaryValue})
+			} else {
+				joinFields = append(joinFields, ref.ForeignKey)
+			}
+		}
+
+		keyOf := func(v reflect.Value) string {
+			values := make([]interface{}, 0, len(ownerFields)+len(joinFields))
+			for _, field := range append(ownerFields, joinFields...) {
+				fv, _ := field.ValueOf(v)
+				values = append(values, fv)
+			}
+			return utils.ToStringKey(values...)
+		}
+
+		ownerKey := utils.ToStringKey(ownerValues...)
+		if _, ok := positions[ownerKey]; !ok {
+			positions[ownerKey] = 0
+			joins := reflect.New(reflect.SliceOf(rel.JoinTable.ModelType))
+			if err := db.Session(&Session{NewDB: true}).Table(rel.JoinTable.Table).Clauses(clause.Where{Exprs: conds}).Find(joins.Interface()).Error; err != nil {
+				return err
+			}
+
+			for i := 0; i < joins.Elem().Len(); i++ {
+				joinRecord := joins.Elem().Index(i)
+				linked[keyOf(joinRecord)] = true
+				position, _ := positionField.ValueOf(joinRecord)
+				if rv := reflect.Indirect(reflect.ValueOf(position)); rv.IsValid() && rv.Type().ConvertibleTo(int64Type) {
+					if next := rv.Convert(int64Type).Int() + 1; next > positions[ownerKey] {
+						positions[ownerKey] = next
+					}
+				}
+			}
+		}
+
+		if relKey := keyOf(joinValue); !linked[relKey] {
+			linked[relKey] = true
+			if err := positionField.Set(joinValue, positions[ownerKey]); err != nil {
+				return err
+			}
+			positions[ownerKey]++
+		}
+		return nil
+	}
+}
+
+// decodeJSONKeys decodes keys of many2many json relations from the owner's json array column, which could be a string,
+// bytes or a driver.Valuer returning them, numbers are decoded as json.Number
+func decodeJSONKeys(value interface{}) (keys []interface{}, err error) {
+	if valuer, ok := value.(driver.Valuer); ok {
+		if value, err = valuer.Value(); err != nil {
+			return nil, err
+		}
+	}
+
+	var data []byte
+	switch rv := reflect.Indirect(reflect.ValueOf(value)); {
+	case !rv.IsValid():
+	case rv.Kind() == reflect.String:
+		data = []byte(rv.String())
+	case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8:
+		data = rv.Bytes()
+	default:
+		return nil, fmt.Errorf("unsupported json data type %v", rv.Type())
+	}
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err = decoder.Decode(&keys)
+	return keys, err
+}
+
+// addressableValues flattens values into addressable structs, so saved associations's primary keys are filled back to them
+func addressableValues(values ...interface{}) (elems []reflect.Value) {
+	appendElem := func(rv reflect.Value) {
+		rv = reflect.Indirect(rv)
+		if !rv.CanAddr() {
+			elem := reflect.New(rv.Type()).Elem()
+			elem.Set(rv)
+			rv = elem
+		}
+		elems = append(elems, rv)
+	}
+
+	for _, value := range values {
+		rv := reflect.Indirect(reflect.ValueOf(value))
+		switch rv.Kind() {
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < rv.Len(); i++ {
+				appendElem(rv.Index(i))
+			}
+		case reflect.Struct:
+			appendElem(rv)
+		}
+	}
+	return
+}
+
+// setFieldValues assigns elems to the owner's relation field, appends them to current values unless clear
+func (association *Association) setFieldValues(elems []reflect.Value, clear bool) error {
+	var (
+		rel          = association.Relationship
+		reflectValue = association.DB.Statement.ReflectValue
+		fieldValue   = reflect.MakeSlice(rel.Field.IndirectFieldType, 0, len(elems))
+	)
+
+	if !clear {
+		if current := reflect.Indirect(rel.Field.ReflectValueOf(reflectValue)); current.IsValid() {
+			fieldValue = reflect.AppendSlice(fieldValue, current)
+		}
+	}
+
+	for _, elem := range elems {
+		if fieldValue.Type().Elem().Kind() == reflect.Ptr {
+			fieldValue = reflect.Append(fieldValue, elem.Addr())
+		} else {
+			fieldValue = reflect.Append(fieldValue, elem)
+		}
+	}
+	return rel.Field.Set(reflectValue, fieldValue.Interface())
+}
+
+// snapshotFields returns current values of the owner's relation field and its belongs to foreign keys
+func (association *Association) snapshotFields() (fields []*schema.Field, owners []reflect.Value, snapshots []reflect.Value) {
+	var (
+		rel          = association.Relationship
+		reflectValue = association.DB.Statement.ReflectValue
+	)
+
+	fields = append(fields, rel.Field)
+	if rel.Type == schema.BelongsTo {
+		for _, ref := range rel.References {
+			if !ref.OwnPrimaryKey && ref.PrimaryValue == "" {
+				fields = append(fields, ref.ForeignKey)
+			}
+		}
+	}
+
+	switch reflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < reflectValue.Len(); i++ {
+			if owner := reflect.Indirect(reflectValue.Index(i)); owner.Kind() == reflect.Struct && owner.CanAddr() {
+				owners = append(owners, owner)
+			}
+		}
+	case reflect.Struct:
+		if reflectValue.CanAddr() {
+			owners = append(owners, reflectValue)
+		}
+	}
 
-			if association.Error == nil {
-				association.Error = association.Relationship.Field.Set(source, fieldValue.Interface())
+	for _, owner := range owners {
+		for _, field := range fields {
+			fieldValue := field.ReflectValueOf(owner)
+			snapshot := reflect.New(fieldValue.Type()).Elem()
+			snapshot.Set(fieldValue)
+			// copy pointed value, which might be changed in place when setting the field
+			if fieldValue.Kind() == reflect.Ptr && !fieldValue.IsNil() {
+				snapshot = reflect.New(fieldValue.Type().Elem())
+				snapshot.Elem().Set(fieldValue.Elem())
 			}
+			snapshots = append(snapshots, snapshot)
 		}
 	}
+	return
+}
 
-	selectedSaveColumns := []string{association.Relationship.Name}
-	for _, ref := range association.Relationship.References {
-		if !ref.OwnPrimaryKey {
-			selectedSaveColumns = append(selectedSaveColumns, ref.ForeignKey.Name)
+// restoreFieldsOnError restores the owner's fields to the snapshots if there is any error or fields should be kept
+func (association *Association) restoreFieldsOnError(fields []*schema.Field, owners []reflect.Value, snapshots []reflect.Value) {
+	if association.Error != nil || association.keepsFields() {
+		for idx, owner := range owners {
+			for fieldIdx, field := range fields {
+				field.ReflectValueOf(owner).Set(snapshots[idx*len(fields)+fieldIdx])
+			}
 		}
 	}
+}
 
-	switch reflectValue.Kind() {
-	case reflect.Slice, reflect.Array:
-		if len(values) != reflectValue.Len() {
-			// clear old data
-			if clear && len(values) == 0 {
-				for i := 0; i < reflectValue.Len(); i++ {
-					if err := association.Relationship.Field.Set(reflectValue.Index(i), reflect.New(association.Relationship.Field.IndirectFieldType).Interface()); err != nil {
-						association.Error = err
-						break
-					}
+// keepsFields reports whether writes should leave the owner's fields untouched, in dry run mode or with SkipInMemoryAssociationUpdate
+func (association *Association) keepsFields() bool {
+	return association.DB.DryRun || association.DB.SkipInMemoryAssociationUpdate
+}
 
-					if association.Relationship.JoinTable == nil {
-						for _, ref := range association.Relationship.References {
-							if !ref.OwnPrimaryKey && ref.PrimaryValue == "" {
-								if err := ref.ForeignKey.Set(reflectValue.Index(i), reflect.Zero(ref.ForeignKey.FieldType).Interface()); err != nil {
-									association.Error = err
-									break
-								}
-							}
-						}
-					}
-				}
-				break
-			}
+// retryOnDeadlock calls fn again if it failed with a deadlock or serialization failure, up to the times of setting
+// "gorm:association:deadlock_retries", backing off 10ms, 20ms, 40ms... between retries, errors are classified by the
+// dialector with RetryableErrorDialectorInterface, nothing is retried without it or in a transaction as it's aborted
+func (association *Association) retryOnDeadlock(fn func() error) error {
+	retries, _ := association.DB.Get("gorm:association:deadlock_retries")
+	maxRetries, _ := retries.(int)
 
-			association.Error = errors.New("invalid association values, length doesn't match")
-			return
-		}
+	if _, inTransaction := association.DB.Statement.ConnPool.(TxCommitter); inTransaction || maxRetries <= 0 {
+		return fn()
+	}
 
-		for i := 0; i < reflectValue.Len(); i++ {
-			appendToRelations(reflectValue.Index(i), reflect.Indirect(reflect.ValueOf(values[i])), clear)
+	// operations change the association's statement, retry with a copy of the original one
+	db := association.DB.Session(&Session{}).getInstance()
+	err := fn()
 
-			// TODO support save slice data, sql with case?
-			association.Error = association.DB.Session(&Session{NewDB: true}).Select(selectedSaveColumns).Model(nil).Updates(reflectValue.Index(i).Addr().Interface()).Error
+	for retry := 0; retry < maxRetries && err != nil && association.isRetryableError(err); retry++ {
+		select {
+		case <-db.Statement.Context.Done():
+			return err
+		case <-time.After((10 * time.Millisecond) << retry):
 		}
-	case reflect.Struct:
-		// clear old data
-		if clear && len(values) == 0 {
-			association.Error = association.Relationship.Field.Set(reflectValue, reflect.New(association.Relationship.Field.IndirectFieldType).Interface())
 
-			if association.Relationship.JoinTable == nil && association.Error == nil {
-				for _, ref := range association.Relationship.References {
-					if !ref.OwnPrimaryKey && ref.PrimaryValue == "" {
-						association.Error = ref.ForeignKey.Set(reflectValue, reflect.Zero(ref.ForeignKey.FieldType).Interface())
-					}
-				}
+		association.DB, association.Error = db.Session(&Session{}).getInstance(), nil
+		err = fn()
+	}
+	return err
+}
+
+func (association *Association) isRetryableError(err error) bool {
+	if dialector, ok := association.DB.Dialector.(RetryableErrorDialectorInterface); ok {
+		for ; err != nil; err = errors.Unwrap(err) {
+			if dialector.IsRetryableError(err) {
+				return true
 			}
 		}
+	}
+	return false
+}
 
-		for idx, value := range values {
-			rv := reflect.Indirect(reflect.ValueOf(value))
-			appendToRelations(reflectValue, rv, clear && idx == 0)
-		}
+// clause names hinting read/write splitting plugins (e.g: dbresolver) which connection the statement should use
+const (
+	ResolverReadHint  = "gorm:db_resolver:read"
+	ResolverWriteHint = "gorm:db_resolver:write"
+)
+
+// resolverHint a clause only marking the statement with its name, builds nothing
+type resolverHint string
+
+func (hint resolverHint) Name() string {
+	return string(hint)
+}
+
+func (hint resolverHint) Build(clause.Builder) {}
+
+func (hint resolverHint) MergeClause(c *clause.Clause) {
+	c.Expression = hint
+}
+
+var associationReadOperations = map[string]bool{
+	"find": true, "first": true, "pluck": true, "count": true, "exists": true, "rows": true, "reload": true, "join find": true,
+}
+
+// tag tags queries of the operation with the relation name and the operation, e.g: "association Pets find",
+// loggers prefix traced SQL with it, custom loggers could read it from the context with logger.TagFromContext,
+// and hints resolver plugins whether the operation reads or writes, it's applied to sessions of the operation
+func (association *Association) tag(operation string) {
+	if association.Error == nil && association.Relationship != nil {
+		association.operation = operation
+	}
+}
 
-		if len(values) > 0 {
-			association.Error = association.DB.Session(&Session{NewDB: true}).Select(selectedSaveColumns).Model(nil).Updates(reflectValue.Addr().Interface()).Error
+// context returns the association's context tagged with the operation, which replaces the tag of a previous operation
+func (association *Association) context() context.Context {
+	ctx := association.DB.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if association.operation != "" {
+		ctx = logger.WithTag(ctx, fmt.Sprintf("association %v %v", association.Relationship.Name, association.operation))
+	}
+	return ctx
+}
+
+// wrapError wraps association's error with the relation name and the operation, keeps the first wrapped error,
+// and calls association callbacks with the operation
+func (association *Association) wrapError(operation string) error {
+	var associationErr *AssociationError
+	if association.Error != nil && !errors.As(association.Error, &associationErr) {
+		association.Error = &AssociationError{Relation: association.Relationship.Name, Operation: operation, Err: association.Error}
+	}
+
+	if fns := association.DB.callbacks.Association().fns; len(fns) > 0 && association.Relationship != nil {
+		tx := association.newSession().Set("gorm:association:operation", AssociationOperation{
+			Relation: association.Relationship.Name, Type: association.Relationship.Type, Operation: operation,
+		})
+		tx.Error = association.Error
+
+		for _, fn := range fns {
+			fn(tx)
 		}
 	}
+	return association.Error
+}
+
+// callLinkCallbacks calls association link callbacks before value is linked to owner, returns the error added by callbacks
+func (association *Association) callLinkCallbacks(owner, value reflect.Value) error {
+	fns := association.DB.callbacks.AssociationLink().fns
+	if len(fns) == 0 {
+		return nil
+	}
 
-	for _, assignBack := range assignBacks {
-		fieldValue := reflect.Indirect(association.Relationship.Field.ReflectValueOf(assignBack.Source))
-		if assignBack.Index > 0 {
-			reflect.Indirect(assignBack.Dest).Set(fieldValue.Index(assignBack.Index - 1))
-		} else {
-			reflect.Indirect(assignBack.Dest).Set(fieldValue)
+	tx := association.newSession().Set("gorm:association:link", AssociationLink{
+		Relation: association.Relationship.Name, Type: association.Relationship.Type, Owner: owner, Value: value,
+		References: association.Relationship.References,
+	})
+
+	for _, fn := range fns {
+		fn(tx)
+	}
+	return tx.Error
+}
+
+// session returns a new session of the association's DB to build an operation's statement, so clauses and the model of
+// the operation don't leak into the association's statement, e.g: calling Count after Find with the same association
+func (association *Association) session() *DB {
+	tx := association.DB.Session(&Session{Context: association.context()})
+	if association.operation != "" {
+		hint, other := resolverHint(ResolverWriteHint), ResolverReadHint
+		if associationReadOperations[association.operation] {
+			hint, other = resolverHint(ResolverReadHint), ResolverWriteHint
 		}
+		delete(tx.Statement.Clauses, other)
+		tx.Statement.AddClause(hint)
 	}
+	return tx
+}
+
+// newSession returns a new session with a new statement of the operation, which keeps the association's context and
+// connection only, it's used for statements of other models than the associations's, e.g: join tables, other owners
+func (association *Association) newSession() *DB {
+	return association.DB.Session(&Session{NewDB: true, Context: association.context()})
+}
+
+// newScopedSession returns a new session like newSession, which keeps the association's unscoped mode too
+func (association *Association) newScopedSession() *DB {
+	tx := association.newSession().getInstance()
+	tx.Statement.Unscoped = association.DB.Statement.Unscoped
+	return tx.Session(&Session{})
+}
+
+// finder returns a copy of the association to find associations in other operations, as finding changes the
+// association's error and operation
+func (association *Association) finder() *Association {
+	finder := association.clone()
+	finder.DB = association.DB.Session(&Session{}).getInstance()
+	return finder
+}
+
+// saveDB returns a new session used to save the owner with its associations, it shares the association's connection,
+// so prepared statements are reused when saving associations for each owner in PrepareStmt mode
+func (association *Association) saveDB() *DB {
+	tx := association.newSession()
+	if association.joinAttrs != nil {
+		tx = tx.Set("gorm:association:join_attrs", association.joinAttrs)
+	}
+	if association.createdJoins != nil {
+		tx = tx.Set("gorm:association:created_joins", association.createdJoins)
+	}
+	if onConflict, ok := association.DB.Statement.Clauses["ON CONFLICT"]; ok {
+		// on conflict clause of the owner's statement is used when creating join table records
+		tx = tx.Set("gorm:association:join_on_conflict", onConflict.Expression)
+	}
+	if association.Relationship.Type == schema.Many2Many && association.Relationship.PositionField() != nil {
+		tx = tx.Set("gorm:association:set_join_position", association.joinPositionSetter())
+	}
+	return tx
 }
 
 func (association *Association) buildCondition() *DB {
+	if association.Relationship.Type == schema.Many2ManyJSON {
+		return association.buildJSONCondition()
+	}
+
 	var (
-		queryConds = association.Relationship.ToQueryConditions(association.DB.Statement.ReflectValue)
+		queryConds = association.ownerConditions()
 		modelValue = reflect.New(association.Relationship.FieldSchema.ModelType).Interface()
-		tx         = association.DB.Model(modelValue)
+		tx         = association.session().Model(modelValue)
+		joinScoped = !tx.Statement.Unscoped
 	)
 
+	// soft deleted associations are included with WithDeleted, while the join table is still scoped
+	if association.withDeleted {
+		tx.Statement.Unscoped = true
+	}
+
 	if association.Relationship.JoinTable != nil {
-		if !tx.Statement.Unscoped && len(association.Relationship.JoinTable.QueryClauses) > 0 {
-			joinStmt := Statement{DB: tx, Schema: association.Relationship.JoinTable, Table: association.Relationship.JoinTable.Table, Clauses: map[string]clause.Clause{}}
+		var (
+			joinTable = clause.Table{Name: association.Relationship.JoinTable.Table, Alias: association.joinAlias}
+			joinConds = append(queryConds, association.joinConds...)
+			tableName = joinTable.Name
+		)
+
+		if joinTable.Alias != "" {
+			tableName = joinTable.Alias
+			joinConds = aliasTable(joinConds, joinTable.Name, joinTable.Alias)
+		}
+
+		if joinScoped && len(association.Relationship.JoinTable.QueryClauses) > 0 {
+			joinStmt := Statement{DB: tx, Schema: association.Relationship.JoinTable, Table: tableName, Clauses: map[string]clause.Clause{}}
 			for _, queryClause := range association.Relationship.JoinTable.QueryClauses {
 				joinStmt.AddClause(queryClause)
 			}
@@ -470,13 +3665,395 @@ func (association *Association) buildCondition() *DB {
 			tx.Clauses(clause.Expr{SQL: strings.Replace(joinStmt.SQL.String(), "WHERE ", "", 1), Vars: joinStmt.Vars})
 		}
 
-		tx.Clauses(clause.From{Joins: []clause.Join{{
-			Table: clause.Table{Name: association.Relationship.JoinTable.Table},
-			ON:    clause.Where{Exprs: queryConds},
-		}}})
+		join := clause.Join{Table: joinTable, ON: clause.Where{Exprs: joinConds}}
+		if association.useIndexHints(tx) {
+			join.Expression = indexHintJoin{Join: join, Hint: association.indexHints}
+		}
+		tx.Clauses(clause.From{Joins: []clause.Join{join}})
 	} else {
 		tx.Clauses(clause.Where{Exprs: queryConds})
+
+		if association.useIndexHints(tx) {
+			fromClause := tx.Statement.Clauses["FROM"]
+			fromClause.AfterExpression = indexHint(association.indexHints)
+			tx.Statement.Clauses["FROM"] = fromClause
+		}
+	}
+
+	return tx
+}
+
+func (association *Association) useIndexHints(tx *DB) bool {
+	return len(association.indexHints) > 0 && tx.Dialector.Name() == "mysql"
+}
+
+// indexHint builds mysql index hint, e.g: USE INDEX (`idx_pets_user_id`)
+type indexHint []string
+
+func (hint indexHint) Build(builder clause.Builder) {
+	builder.WriteString("USE INDEX (")
+	for idx, name := range hint {
+		if idx > 0 {
+			builder.WriteByte(',')
+		}
+		builder.WriteQuoted(name)
+	}
+	builder.WriteByte(')')
+}
+
+// indexHintJoin builds join clause with index hint of the joined table, e.g: JOIN `user_speaks` USE INDEX (`idx`) ON ...
+type indexHintJoin struct {
+	Join clause.Join
+	Hint indexHint
+}
+
+func (join indexHintJoin) Build(builder clause.Builder) {
+	if join.Join.Type != "" {
+		builder.WriteString(string(join.Join.Type))
+		builder.WriteByte(' ')
+	}
+
+	builder.WriteString("JOIN ")
+	builder.WriteQuoted(join.Join.Table)
+	builder.WriteByte(' ')
+	join.Hint.Build(builder)
+	builder.WriteString(" ON ")
+	join.Join.ON.Build(builder)
+}
+
+// buildJSONCondition builds condition of many2many json relations, which matches targets whose primary keys are contained
+// in the json array column of owners, it uses jsonb operators for postgres, other dialects query keys decoded from owners
+func (association *Association) buildJSONCondition() *DB {
+	var (
+		rel           = association.Relationship
+		ref           = rel.References[0]
+		reflectValue  = association.DB.Statement.ReflectValue
+		primaryColumn = clause.Column{Table: clause.CurrentTable, Name: ref.PrimaryKey.DBName}
+		tx            = association.session().Model(reflect.New(rel.FieldSchema.ModelType).Interface())
+	)
+
+	if tx.Dialector.Name() == "postgres" {
+		var (
+			ownerTable     = clause.Table{Name: rel.Schema.Table, Alias: "json_owners"}
+			_, ownerValues = schema.GetIdentityFieldValuesMap(reflectValue, rel.Schema.PrimaryFields)
+			column, values = schema.ToQueryValues(ownerTable.Alias, rel.Schema.PrimaryFieldDBNames, ownerValues)
+		)
+
+		tx.Clauses(clause.Where{Exprs: []clause.Expression{clause.Expr{
+			SQL:  "EXISTS (SELECT 1 FROM ? WHERE ? IN ? AND ? @> jsonb_build_array(?))",
+			Vars: []interface{}{ownerTable, column, values, clause.Column{Table: ownerTable.Alias, Name: ref.ForeignKey.DBName}, primaryColumn},
+		}}})
+		return tx
+	}
+
+	var (
+		keys       []interface{}
+		keysMap    = map[string]bool{}
+		decodeKeys = func(owner reflect.Value) {
+			ownerKeys, err := decodeJSONKeys(ref.ForeignKey.ReflectValueOf(owner).Interface())
+			if err != nil {
+				tx.AddError(fmt.Errorf("%w: %v of %v, got error %v", ErrInvalidData, ref.ForeignKey.Name, rel.Name, err))
+			}
+
+			for _, key := range ownerKeys {
+				if number, ok := key.(json.Number); ok {
+					if i, err := number.Int64(); err == nil {
+						key = i
+					}
+				}
+
+				if !keysMap[fmt.Sprint(key)] {
+					keysMap[fmt.Sprint(key)] = true
+					keys = append(keys, key)
+				}
+			}
+		}
+	)
+
+	switch reflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < reflectValue.Len(); i++ {
+			decodeKeys(reflect.Indirect(reflectValue.Index(i)))
+		}
+	case reflect.Struct:
+		decodeKeys(reflectValue)
+	}
+
+	tx.Clauses(clause.Where{Exprs: []clause.Expression{clause.IN{Column: primaryColumn, Values: keys}}})
+	return tx
+}
+
+// aliasTable replaces table of columns in exprs with alias, columns in raw SQL expressions are not replaced
+func aliasTable(exprs []clause.Expression, table, alias string) []clause.Expression {
+	return replaceColumns(exprs, func(value interface{}) interface{} {
+		switch v := value.(type) {
+		case clause.Column:
+			if v.Table == table {
+				v.Table = alias
+			}
+			return v
+		case []clause.Column:
+			columns := make([]clause.Column, len(v))
+			for idx, column := range v {
+				if columns[idx] = column; column.Table == table {
+					columns[idx].Table = alias
+				}
+			}
+			return columns
+		}
+		return value
+	})
+}
+
+// replaceColumns replaces columns of exprs with replace, values of comparisons are replaced only if they are columns,
+// columns in raw SQL expressions are not replaced
+func replaceColumns(exprs []clause.Expression, replace func(column interface{}) interface{}) []clause.Expression {
+	replaceEq := func(eq clause.Eq) clause.Eq {
+		eq.Column = replace(eq.Column)
+		switch eq.Value.(type) {
+		case clause.Column, []clause.Column:
+			eq.Value = replace(eq.Value)
+		}
+		return eq
+	}
+
+	results := make([]clause.Expression, len(exprs))
+	for idx, expr := range exprs {
+		switch v := expr.(type) {
+		case clause.IN:
+			v.Column = replace(v.Column)
+			results[idx] = v
+		case clause.Eq:
+			results[idx] = replaceEq(v)
+		case clause.Neq:
+			results[idx] = clause.Neq(replaceEq(clause.Eq(v)))
+		case clause.Gt:
+			results[idx] = clause.Gt(replaceEq(clause.Eq(v)))
+		case clause.Gte:
+			results[idx] = clause.Gte(replaceEq(clause.Eq(v)))
+		case clause.Lt:
+			results[idx] = clause.Lt(replaceEq(clause.Eq(v)))
+		case clause.Lte:
+			results[idx] = clause.Lte(replaceEq(clause.Eq(v)))
+		case clause.Like:
+			results[idx] = clause.Like(replaceEq(clause.Eq(v)))
+		case clause.AndConditions:
+			results[idx] = clause.AndConditions{Exprs: replaceColumns(v.Exprs, replace)}
+		case clause.OrConditions:
+			results[idx] = clause.OrConditions{Exprs: replaceColumns(v.Exprs, replace)}
+		case clause.NotConditions:
+			results[idx] = clause.NotConditions{Exprs: replaceColumns(v.Exprs, replace)}
+		default:
+			results[idx] = expr
+		}
+	}
+	return results
+}
+
+// qualifyConds qualifies columns of conds with the associations's table for relations with join tables, so they won't
+// be ambiguous with columns of the join table, columns in raw SQL conditions are not qualified
+func (association *Association) qualifyConds(tx *DB, conds []interface{}) []interface{} {
+	if association.Relationship.JoinTable == nil || len(conds) == 0 {
+		return conds
+	}
+
+	exprs := replaceColumns(tx.Statement.BuildCondition(conds[0], conds[1:]...), func(column interface{}) interface{} {
+		if name, ok := column.(string); ok && !strings.Contains(name, ".") {
+			if field := association.Relationship.FieldSchema.LookUpField(name); field != nil {
+				name = field.DBName
+			}
+			return clause.Column{Table: clause.CurrentTable, Name: name}
+		}
+		return column
+	})
+
+	if len(exprs) == 0 {
+		return nil
+	}
+	return []interface{}{clause.And(exprs...)}
+}
+
+// qualifyGroupBy qualify grouped columns and columns of having conditions which are fields of the associations with
+// the associations's table, so they aren't ambiguous with columns of the join table, other columns (e.g: aliases) are kept
+func (association *Association) qualifyGroupBy(tx *DB) *DB {
+	groupBy, ok := tx.Statement.Clauses["GROUP BY"].Expression.(clause.GroupBy)
+	if !ok || association.Relationship.JoinTable == nil {
+		return tx
+	}
+
+	qualify := func(column interface{}) interface{} {
+		switch c := column.(type) {
+		case string:
+			if field := association.Relationship.FieldSchema.LookUpField(c); field != nil && !strings.Contains(c, ".") {
+				return clause.Column{Table: association.Relationship.FieldSchema.Table, Name: field.DBName}
+			}
+		case clause.Column:
+			if field := association.Relationship.FieldSchema.LookUpField(c.Name); field != nil && c.Table == "" && !c.Raw {
+				return clause.Column{Table: association.Relationship.FieldSchema.Table, Name: field.DBName, Alias: c.Alias}
+			}
+		}
+		return column
+	}
+
+	columns := make([]clause.Column, len(groupBy.Columns))
+	for idx, column := range groupBy.Columns {
+		columns[idx] = qualify(column).(clause.Column)
+	}
+
+	groupByClause := tx.Statement.Clauses["GROUP BY"]
+	groupByClause.Expression = clause.GroupBy{Columns: columns, Having: replaceColumns(groupBy.Having, qualify)}
+	tx.Statement.Clauses["GROUP BY"] = groupByClause
+	return tx
+}
+
+// qualifySelects qualify selected columns with the associations's table to avoid ambiguous columns with the join table,
+// only the associations's table is selected if no columns are selected, so columns of the join table with the same names
+// won't overwrite them, all its columns are selected for distinct, so duplicated join records are ignored
+func (association *Association) qualifySelects(tx *DB) *DB {
+	if association.Relationship.JoinTable != nil {
+		selects := tx.Statement.Selects
+		if groupBy, ok := tx.Statement.Clauses["GROUP BY"].Expression.(clause.GroupBy); ok && len(selects) == 0 {
+			// only grouped columns could be selected without aggregations
+			tx.Statement.AddClause(clause.Select{Distinct: tx.Statement.Distinct, Columns: groupBy.Columns})
+			return tx
+		} else if len(selects) == 0 && !tx.Statement.Distinct {
+			tx.Statement.AddClause(clause.Select{Columns: []clause.Column{{Table: association.Relationship.FieldSchema.Table, Name: "*", Raw: true}}})
+			return tx
+		} else if len(selects) == 0 {
+			selects = association.Relationship.FieldSchema.DBNames
+		}
+
+		clauseSelect := clause.Select{Distinct: tx.Statement.Distinct, Columns: make([]clause.Column, len(selects))}
+		for idx, name := range selects {
+			if field := association.Relationship.FieldSchema.LookUpField(name); field != nil {
+				clauseSelect.Columns[idx] = clause.Column{Table: association.Relationship.FieldSchema.Table, Name: field.DBName}
+			} else {
+				clauseSelect.Columns[idx] = clause.Column{Name: name, Raw: true}
+			}
+		}
+		tx.Statement.Selects = nil
+		tx.Statement.AddClause(clauseSelect)
+	}
+	return tx
+}
+
+// JoinAssociation join table mode of many2many relations, which finds and updates the owner's join table records
+type JoinAssociation struct {
+	association *Association
+}
+
+// JoinAssociation returns join table mode of many2many relation column, e.g: update sort_order of the link between user and role
+// with db.Model(&user).JoinAssociation("Roles").Update("sort_order", 1, &role)
+func (db *DB) JoinAssociation(column string) *JoinAssociation {
+	association := db.Association(column)
+	if association.Error == nil && (association.Relationship.JoinTable == nil || association.Relationship.Type == schema.HasManyThrough) {
+		association.Error = &AssociationError{Relation: column, Err: fmt.Errorf("%w: %v has no join table", ErrUnsupportedRelation, column)}
+	}
+	return &JoinAssociation{association: association}
+}
+
+// Find find the owner's join table records linking targets, or all the owner's join table records without targets,
+// out could be a slice of the join table model set up with SetupJoinTable or []map[string]interface{}
+func (joinAssociation *JoinAssociation) Find(out interface{}, targets ...interface{}) error {
+	association := joinAssociation.association
+	association.tag("join find")
+	if association.Error == nil {
+		var tx *DB
+		if tx, association.Error = joinAssociation.buildCondition(targets...); association.Error == nil {
+			association.Error = tx.Clauses(resolverHint(ResolverReadHint)).Find(out).Error
+		}
+	}
+	return association.wrapError("join find")
+}
+
+// Update update column of the owner's join table records linking targets, or all the owner's join table records without targets
+func (joinAssociation *JoinAssociation) Update(column string, value interface{}, targets ...interface{}) error {
+	return joinAssociation.Updates(map[string]interface{}{column: value}, targets...)
+}
+
+// Updates update the owner's join table records linking targets with values, or all the owner's join table records without targets
+func (joinAssociation *JoinAssociation) Updates(values map[string]interface{}, targets ...interface{}) error {
+	association := joinAssociation.association
+	association.tag("join update")
+	if association.Error == nil {
+		var (
+			tx          *DB
+			joinTable   = association.Relationship.JoinTable
+			updateAttrs = make(map[string]interface{}, len(values))
+		)
+
+		for key, value := range values {
+			field := joinTable.LookUpField(key)
+			if field == nil {
+				association.Error = fmt.Errorf("%w: %v for join table %v", ErrInvalidField, key, joinTable.Table)
+				return association.wrapError("join update")
+			}
+			updateAttrs[field.DBName] = value
+		}
+
+		if tx, association.Error = joinAssociation.buildCondition(targets...); association.Error == nil {
+			association.Error = tx.Updates(updateAttrs).Error
+		}
+	}
+	return association.wrapError("join update")
+}
+
+// buildCondition builds conditions of the owner's join table records linking targets
+func (joinAssociation *JoinAssociation) buildCondition(targets ...interface{}) (*DB, error) {
+	var (
+		association                  = joinAssociation.association
+		rel                          = association.Relationship
+		primaryFields                []*schema.Field
+		relPrimaryFields             []*schema.Field
+		joinPrimaryKeys, joinRelKeys []string
+		conds                        []clause.Expression
+	)
+
+	for _, ref := range rel.References {
+		switch {
+		case ref.OwnPrimaryKey:
+			primaryFields = append(primaryFields, ref.PrimaryKey)
+			joinPrimaryKeys = append(joinPrimaryKeys, ref.ForeignKey.DBName)
+		case ref.PrimaryValue != "":
+			conds = append(conds, clause.Eq{Column: clause.Column{Table: rel.JoinTable.Table, Name: ref.ForeignKey.DBName}, Value: ref.PrimaryValue})
+		default:
+			relPrimaryFields = append(relPrimaryFields, ref.PrimaryKey)
+			joinRelKeys = append(joinRelKeys, ref.ForeignKey.DBName)
+		}
+	}
+
+	_, primaryValues := schema.GetIdentityFieldValuesMap(association.DB.Statement.ReflectValue, primaryFields)
+	column, values := schema.ToQueryValues(rel.JoinTable.Table, joinPrimaryKeys, primaryValues)
+	conds = append(conds, clause.IN{Column: column, Values: values})
+
+	if len(targets) > 0 {
+		if err := association.validateValues(targets...); err != nil {
+			return nil, err
+		}
+
+		_, relValues := schema.GetIdentityFieldValuesMapFromValues(targets, relPrimaryFields)
+		relColumn, relQueryValues := schema.ToQueryValues(rel.JoinTable.Table, joinRelKeys, relValues)
+		conds = append(conds, clause.IN{Column: relColumn, Values: relQueryValues})
 	}
 
+	return association.newSession().Table(rel.JoinTable.Table).Clauses(clause.Where{Exprs: conds}), nil
+}
+
+// withoutLimit removes limit and offset chained before Association from tx, which would skip counted associations
+func withoutLimit(tx *DB) *DB {
+	delete(tx.Statement.Clauses, "LIMIT")
 	return tx
 }
+
+// splitClauses add clauses in conds to the query, returns left query conditions
+func (db *DB) splitClauses(conds []interface{}) (tx *DB, queryConds []interface{}) {
+	tx = db
+	for _, cond := range conds {
+		if c, ok := cond.(clause.Interface); ok {
+			tx = tx.Clauses(c)
+		} else {
+			queryConds = append(queryConds, cond)
+		}
+	}
+	return
+}
@@ -21,6 +21,9 @@ func initializeCallbacks(db *DB) *callbacks {
 			"delete": {db: db},
 			"row":    {db: db},
 			"raw":    {db: db},
+
+			"association":      {db: db},
+			"association_link": {db: db},
 		},
 	}
 }
@@ -71,6 +74,20 @@ func (cs *callbacks) Raw() *processor {
 	return cs.processors["raw"]
 }
 
+// Association callbacks are called once an association mode operation finished, e.g: Find, Append, with the
+// AssociationOperation in setting "gorm:association:operation" and the operation's error as db.Error, use them
+// to find N+1 queries, e.g: increase a prometheus CounterVec labeled with the relation and the operation
+func (cs *callbacks) Association() *processor {
+	return cs.processors["association"]
+}
+
+// AssociationLink callbacks are called before association mode writes link a value to its owner, e.g: Append, Replace,
+// with the AssociationLink in setting "gorm:association:link", the value could be changed before it's saved, add an
+// error to the db to reject the link, e.g: reject values of other tenants, the operation fails with the error
+func (cs *callbacks) AssociationLink() *processor {
+	return cs.processors["association_link"]
+}
+
 func (p *processor) Execute(db *DB) {
 	curTime := time.Now()
 	stmt := db.Statement
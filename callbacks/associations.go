@@ -3,10 +3,12 @@ package callbacks
 import (
 	"reflect"
 	"strings"
+	"time"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 	"gorm.io/gorm/schema"
+	"gorm.io/gorm/utils"
 )
 
 func SaveBeforeAssociations(db *gorm.DB) {
@@ -239,6 +241,13 @@ func SaveAfterAssociations(db *gorm.DB) {
 			elems := reflect.MakeSlice(reflect.SliceOf(fieldType), 0, 10)
 			joins := reflect.MakeSlice(reflect.SliceOf(reflect.PtrTo(rel.JoinTable.ModelType)), 0, 10)
 			objs := []reflect.Value{}
+			joinAttrs, _ := db.Get("gorm:association:join_attrs")
+			joinExprs := map[string]clause.Expr{}
+			createdJoins, _ := db.Get("gorm:association:created_joins")
+			curTime := db.Statement.DB.NowFunc()
+
+			// positions of join records are assigned by association mode, e.g: Append of ordered many2many associations
+			setJoinPosition, _ := db.Get("gorm:association:set_join_position")
 
 			appendToJoins := func(obj reflect.Value, elem reflect.Value) {
 				joinValue := reflect.New(rel.JoinTable.ModelType)
@@ -253,6 +262,23 @@ func SaveAfterAssociations(db *gorm.DB) {
 						ref.ForeignKey.Set(joinValue, fv)
 					}
 				}
+
+				if setPosition, ok := setJoinPosition.(func(*gorm.DB, reflect.Value) error); ok {
+					db.AddError(setPosition(db, joinValue))
+				}
+
+				if attrs, ok := joinAttrs.(map[string]interface{}); ok {
+					for key, value := range attrs {
+						if field := rel.JoinTable.LookUpField(key); field != nil {
+							if expr, ok := value.(clause.Expr); ok {
+								joinExprs[field.DBName] = expr
+							} else {
+								db.AddError(field.Set(joinValue, value))
+							}
+						}
+					}
+				}
+				setJoinTimestamps(db, rel.JoinTable, joinValue, curTime)
 				joins = reflect.Append(joins, joinValue)
 			}
 
@@ -296,10 +322,116 @@ func SaveAfterAssociations(db *gorm.DB) {
 			}
 
 			if joins.Len() > 0 {
-				db.AddError(db.Session(&gorm.Session{NewDB: true}).Clauses(clause.OnConflict{DoNothing: true}).Create(joins.Interface()).Error)
+				// join records of linked associations aren't created as join records are created with ON CONFLICT
+				var linkedJoins map[string]bool
+				if _, ok := createdJoins.(*[]interface{}); ok {
+					linkedJoins = linkedJoinKeys(db, rel, joins)
+				}
+
+				onConflict := clause.OnConflict{DoNothing: true}
+				if joinOnConflict, ok := db.Get("gorm:association:join_on_conflict"); ok {
+					if c, ok := joinOnConflict.(clause.OnConflict); ok {
+						onConflict = c
+					}
+				}
+
+				if len(joinExprs) > 0 {
+					// expressions couldn't be assigned to join records, create join records from maps to emit them raw
+					db.AddError(db.Session(&gorm.Session{NewDB: true}).Model(reflect.New(rel.JoinTable.ModelType).Interface()).Clauses(onConflict).Create(joinMapsWithExprs(db, rel.JoinTable, joins, joinExprs)).Error)
+				} else {
+					db.AddError(db.Session(&gorm.Session{NewDB: true}).Clauses(onConflict).Create(joins.Interface()).Error)
+				}
+
+				if created, ok := createdJoins.(*[]interface{}); ok && db.Error == nil {
+					for i := 0; i < joins.Len(); i++ {
+						if !linkedJoins[joinKey(rel, joins.Index(i))] {
+							*created = append(*created, joins.Index(i).Interface())
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// setJoinTimestamps assigns curTime to join record's zero auto create/update time fields, so join records created
+// together share the timestamp from NowFunc, even if the fields have default values of the database's time zone
+func setJoinTimestamps(db *gorm.DB, joinTable *schema.Schema, joinValue reflect.Value, curTime time.Time) {
+	for _, field := range joinTable.Fields {
+		if field.AutoCreateTime > 0 || field.AutoUpdateTime > 0 {
+			if _, isZero := field.ValueOf(joinValue); isZero {
+				db.AddError(field.Set(joinValue, curTime))
+			}
+		}
+	}
+}
+
+// joinKey returns the key of join record, which is made of its foreign keys
+func joinKey(rel *schema.Relationship, joinValue reflect.Value) string {
+	values := make([]interface{}, 0, len(rel.References))
+	for _, ref := range rel.References {
+		if ref.PrimaryValue == "" {
+			fv, _ := ref.ForeignKey.ValueOf(joinValue)
+			values = append(values, fv)
+		}
+	}
+	return utils.ToStringKey(values...)
+}
+
+// linkedJoinKeys returns keys of existing join records of joins
+func linkedJoinKeys(db *gorm.DB, rel *schema.Relationship, joins reflect.Value) map[string]bool {
+	var (
+		conds       []clause.Expression
+		linked      = map[string]bool{}
+		joinRecords = reflect.New(reflect.SliceOf(rel.JoinTable.ModelType))
+	)
+
+	for _, ref := range rel.References {
+		if ref.PrimaryValue != "" {
+			conds = append(conds, clause.Eq{Column: clause.Column{Table: rel.JoinTable.Table, Name: ref.ForeignKey.DBName}, Value: ref.PrimaryValue})
+			continue
+		}
+
+		values := make([]interface{}, joins.Len())
+		for i := range values {
+			values[i], _ = ref.ForeignKey.ValueOf(joins.Index(i))
+		}
+		conds = append(conds, clause.IN{Column: clause.Column{Table: rel.JoinTable.Table, Name: ref.ForeignKey.DBName}, Values: values})
+	}
+
+	db.AddError(db.Session(&gorm.Session{NewDB: true}).Table(rel.JoinTable.Table).Clauses(clause.Where{Exprs: conds}).Find(joinRecords.Interface()).Error)
+	for i := 0; i < joinRecords.Elem().Len(); i++ {
+		linked[joinKey(rel, joinRecords.Elem().Index(i))] = true
+	}
+	return linked
+}
+
+// joinMapsWithExprs converts join records to maps, whose columns of exprs are assigned with the expressions
+func joinMapsWithExprs(db *gorm.DB, joinTable *schema.Schema, joins reflect.Value, exprs map[string]clause.Expr) []map[string]interface{} {
+	var (
+		curTime = db.Statement.DB.NowFunc()
+		values  = make([]map[string]interface{}, joins.Len())
+	)
+
+	for i := range values {
+		joinValue := joins.Index(i)
+		values[i] = make(map[string]interface{}, len(joinTable.DBNames))
+		for _, dbName := range joinTable.DBNames {
+			field := joinTable.FieldsByDBName[dbName]
+			if expr, ok := exprs[dbName]; ok {
+				values[i][dbName] = expr
+			} else if fv, isZero := field.ValueOf(joinValue); !isZero || !field.HasDefaultValue {
+				if isZero && (field.AutoCreateTime > 0 || field.AutoUpdateTime > 0) {
+					db.AddError(field.Set(joinValue, curTime))
+					fv, _ = field.ValueOf(joinValue)
+				}
+				values[i][dbName] = fv
+			} else if field.DefaultValueInterface != nil {
+				values[i][dbName] = field.DefaultValueInterface
 			}
 		}
 	}
+	return values
 }
 
 func onConflictOption(stmt *gorm.Statement, s *schema.Schema, selectColumns map[string]bool, restricted bool, defaultUpdatingColumns []string) clause.OnConflict {
@@ -155,7 +155,13 @@ func BuildQuerySQL(db *gorm.DB) {
 				}
 			}
 
-			db.Statement.AddClause(clause.From{Joins: joins})
+			// keep joins of the existing from clause, e.g: the join table of association mode
+			fromClause := clause.From{}
+			if v, ok := db.Statement.Clauses["FROM"].Expression.(clause.From); ok {
+				fromClause = v
+			}
+			fromClause.Joins = append(fromClause.Joins, joins...)
+			db.Statement.AddClause(fromClause)
 		} else {
 			db.Statement.AddClauseIfNotExists(clause.From{})
 		}
@@ -200,7 +206,8 @@ func Preload(db *gorm.DB) {
 			)
 
 			for idx, preloadField := range preloadFields {
-				if rel := curSchema.Relationships.Relations[preloadField]; rel != nil {
+				if rel := curSchema.Relationships.Relations[preloadField]; rel != nil && rel.Type != schema.Many2ManyJSON {
+					db.AddError(rel.ParseThrough())
 					rels[idx] = rel
 					curSchema = rel.FieldSchema
 				} else {
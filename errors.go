@@ -2,6 +2,8 @@ package gorm
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 )
 
 var (
@@ -31,4 +33,56 @@ var (
 	ErrEmptySlice = errors.New("empty slice found")
 	// ErrDryRunModeUnsupported dry run mode unsupported
 	ErrDryRunModeUnsupported = errors.New("dry run mode unsupported")
+	// ErrDeletedOwner owner has been soft deleted
+	ErrDeletedOwner = errors.New("owner has been soft deleted")
+	// ErrTooManyDetached replacing associations would detach more associations than allowed
+	ErrTooManyDetached = errors.New("too many associations to detach")
 )
+
+// AssociationError association error, carries the relation name and the operation that failed,
+// Operation is empty if the relation can't be found
+type AssociationError struct {
+	Relation  string
+	Operation string
+	Err       error
+}
+
+func (err *AssociationError) Error() string {
+	if err.Operation == "" {
+		return fmt.Sprintf("association %v: %v", err.Relation, err.Err)
+	}
+	return fmt.Sprintf("association %v %v: %v", err.Relation, err.Operation, err.Err)
+}
+
+func (err *AssociationError) Unwrap() error {
+	return err.Err
+}
+
+// BatchError error happened when saving the Index-th batch, PrimaryKeys are the batch values's primary keys if they are known
+type BatchError struct {
+	Index       int
+	PrimaryKeys []interface{}
+	Err         error
+}
+
+func (err *BatchError) Error() string {
+	if len(err.PrimaryKeys) == 0 {
+		return fmt.Sprintf("batch %v: %v", err.Index, err.Err)
+	}
+	return fmt.Sprintf("batch %v with primary keys %v: %v", err.Index, err.PrimaryKeys, err.Err)
+}
+
+func (err *BatchError) Unwrap() error {
+	return err.Err
+}
+
+// BatchErrors errors of all failed batches
+type BatchErrors []*BatchError
+
+func (errs BatchErrors) Error() string {
+	messages := make([]string, len(errs))
+	for idx, err := range errs {
+		messages[idx] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
@@ -22,6 +22,9 @@ type Config struct {
 	NamingStrategy schema.Namer
 	// FullSaveAssociations full save associations
 	FullSaveAssociations bool
+	// SkipInMemoryAssociationUpdate association mode writes only change the database, owners's relation fields and
+	// foreign keys are left untouched, which avoids aliasing surprises, but owners are stale until they're reloaded
+	SkipInMemoryAssociationUpdate bool
 	// Logger
 	Logger logger.Interface
 	// NowFunc the function to be used when creating a new timestamp
@@ -38,6 +41,8 @@ type Config struct {
 	AllowGlobalUpdate bool
 	// QueryFields executes the SQL query with all fields of the table
 	QueryFields bool
+	// CreateBatchSize default batch size to create has many, many2many associations with association mode
+	CreateBatchSize int
 
 	// ClauseBuilders clause builder
 	ClauseBuilders map[string]clause.ClauseBuilder
@@ -63,17 +68,19 @@ type DB struct {
 
 // Session session config when create session with Session() method
 type Session struct {
-	DryRun                 bool
-	PrepareStmt            bool
-	NewDB                  bool
-	SkipHooks              bool
-	SkipDefaultTransaction bool
-	AllowGlobalUpdate      bool
-	FullSaveAssociations   bool
-	QueryFields            bool
-	Context                context.Context
-	Logger                 logger.Interface
-	NowFunc                func() time.Time
+	DryRun                        bool
+	PrepareStmt                   bool
+	NewDB                         bool
+	SkipHooks                     bool
+	SkipDefaultTransaction        bool
+	AllowGlobalUpdate             bool
+	FullSaveAssociations          bool
+	QueryFields                   bool
+	SkipInMemoryAssociationUpdate bool
+	CreateBatchSize               int
+	Context                       context.Context
+	Logger                        logger.Interface
+	NowFunc                       func() time.Time
 }
 
 // Open initialize db session based on dialector
@@ -173,6 +180,10 @@ func (db *DB) Session(config *Session) *DB {
 		txConfig.FullSaveAssociations = true
 	}
 
+	if config.SkipInMemoryAssociationUpdate {
+		txConfig.SkipInMemoryAssociationUpdate = true
+	}
+
 	if config.Context != nil || config.PrepareStmt || config.SkipHooks {
 		tx.Statement = tx.Statement.clone()
 		tx.Statement.DB = tx
@@ -211,6 +222,10 @@ func (db *DB) Session(config *Session) *DB {
 		tx.Config.QueryFields = true
 	}
 
+	if config.CreateBatchSize > 0 {
+		tx.Config.CreateBatchSize = config.CreateBatchSize
+	}
+
 	if config.Logger != nil {
 		tx.Config.Logger = config.Logger
 	}
@@ -336,7 +351,7 @@ func (db *DB) SetupJoinTable(model interface{}, field string, joinTable interfac
 		return err
 	}
 
-	if relation, ok := modelSchema.Relationships.Relations[field]; ok && relation.JoinTable != nil {
+	if relation, ok := modelSchema.Relationships.Relations[field]; ok && relation.JoinTable != nil && relation.Type == schema.Many2Many {
 		for _, ref := range relation.References {
 			if f := joinSchema.LookUpField(ref.ForeignKey.DBName); f != nil {
 				f.DataType = ref.ForeignKey.DataType
@@ -40,6 +40,17 @@ type SavePointerDialectorInterface interface {
 	RollbackTo(tx *DB, name string) error
 }
 
+// RetryableErrorDialectorInterface reports errors could be retried, e.g: deadlocks, serialization failures
+type RetryableErrorDialectorInterface interface {
+	IsRetryableError(err error) bool
+}
+
+// PlaceholderLimitDialectorInterface reports the max number of placeholders of a statement, large association appends
+// are created in batches under the limit, sqlite is limited to 999 placeholders without it, other dialects aren't limited
+type PlaceholderLimitDialectorInterface interface {
+	MaxPlaceholders() int
+}
+
 type TxBeginner interface {
 	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
 }
@@ -67,6 +67,40 @@ var (
 	Recorder = traceRecorder{Interface: Default, BeginAt: time.Now()}
 )
 
+type tagKey struct{}
+
+// taggedContext a context carrying a tag, tagging it again replaces the tag instead of wrapping it
+type taggedContext struct {
+	context.Context
+	tag string
+}
+
+func (ctx taggedContext) Value(key interface{}) interface{} {
+	if key == (tagKey{}) {
+		return ctx.tag
+	}
+	return ctx.Context.Value(key)
+}
+
+// WithTag returns a copy of ctx carrying tag, the default logger prefixes traced SQL of the context with the tag,
+// e.g: [association Pets append] INSERT INTO `pets` ..., the tag replaces ctx's tag if ctx is tagged by WithTag
+func WithTag(ctx context.Context, tag string) context.Context {
+	if tagged, ok := ctx.(taggedContext); ok {
+		ctx = tagged.Context
+	}
+	return taggedContext{Context: ctx, tag: tag}
+}
+
+// TagFromContext returns the tag carried by ctx, custom loggers could surface it with traced SQL
+func TagFromContext(ctx context.Context) string {
+	if ctx != nil {
+		if tag, ok := ctx.Value(tagKey{}).(string); ok {
+			return tag
+		}
+	}
+	return ""
+}
+
 func New(writer Writer, config Config) Interface {
 	var (
 		infoStr      = "%s\n[info] "
@@ -136,6 +170,14 @@ func (l logger) Error(ctx context.Context, msg string, data ...interface{}) {
 // Trace print sql message
 func (l logger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
 	if l.LogLevel > 0 {
+		if tag := TagFromContext(ctx); tag != "" {
+			traceFc := fc
+			fc = func() (string, int64) {
+				sql, rows := traceFc()
+				return "[" + tag + "] " + sql, rows
+			}
+		}
+
 		elapsed := time.Since(begin)
 		switch {
 		case err != nil && l.LogLevel >= Error:
@@ -5,9 +5,12 @@ import (
 	"reflect"
 	"regexp"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/jinzhu/inflection"
 	"gorm.io/gorm/clause"
+	"gorm.io/gorm/utils"
 )
 
 // RelationshipType relationship type
@@ -18,14 +21,18 @@ const (
 	HasMany   RelationshipType = "has_many"     // HasManyRel has many relationship
 	BelongsTo RelationshipType = "belongs_to"   // BelongsToRel belongs to relationship
 	Many2Many RelationshipType = "many_to_many" // Many2ManyRel many to many relationship
+
+	Many2ManyJSON  RelationshipType = "many_to_many_json" // Many2ManyJSONRel many to many relationship stored in a json array column
+	HasManyThrough RelationshipType = "has_many_through"  // HasManyThroughRel has many relationship through an intermediate relation
 )
 
 type Relationships struct {
-	HasOne    []*Relationship
-	BelongsTo []*Relationship
-	HasMany   []*Relationship
-	Many2Many []*Relationship
-	Relations map[string]*Relationship
+	HasOne         []*Relationship
+	BelongsTo      []*Relationship
+	HasMany        []*Relationship
+	Many2Many      []*Relationship
+	HasManyThrough []*Relationship
+	Relations      map[string]*Relationship
 }
 
 type Relationship struct {
@@ -38,6 +45,25 @@ type Relationship struct {
 	FieldSchema              *Schema
 	JoinTable                *Schema
 	foreignKeys, primaryKeys []string
+	queryConditions          atomic.Value // *queryConditions
+	through                  *throughRelation
+}
+
+// throughRelation the through relation of has many through relations, references of the relation are resolved with
+// the intermediate schema's relation when it's used first, as the intermediate schema may be parsed after the relation
+type throughRelation struct {
+	relation *Relationship
+	once     sync.Once
+	err      error
+}
+
+// queryConditions static parts of the relationship's query conditions, built for its current join table
+type queryConditions struct {
+	joinTable     *Schema
+	table         string
+	conds         []clause.Expression
+	foreignFields []*Field
+	foreignKeys   []string
 }
 
 type Polymorphic struct {
@@ -54,6 +80,11 @@ type Reference struct {
 }
 
 func (schema *Schema) parseRelation(field *Field) {
+	// relations could be parsed in advance as the through relation of has many through relations
+	if rel, ok := schema.Relationships.Relations[field.Name]; ok && rel.Field == field {
+		return
+	}
+
 	var (
 		err        error
 		fieldValue = reflect.New(field.IndirectFieldType).Interface()
@@ -80,6 +111,10 @@ func (schema *Schema) parseRelation(field *Field) {
 		schema.buildPolymorphicRelation(relation, field, polymorphic)
 	} else if many2many := field.TagSettings["MANY2MANY"]; many2many != "" {
 		schema.buildMany2ManyRelation(relation, field, many2many)
+	} else if jsonColumn := field.TagSettings["MANY2MANY_JSON"]; jsonColumn != "" {
+		schema.buildMany2ManyJSONRelation(relation, field, jsonColumn)
+	} else if through := field.TagSettings["THROUGH"]; through != "" {
+		schema.buildHasManyThroughRelation(relation, field, through)
 	} else {
 		switch field.IndirectFieldType.Kind() {
 		case reflect.Struct:
@@ -104,6 +139,10 @@ func (schema *Schema) parseRelation(field *Field) {
 		}
 	}
 
+	if schema.err == nil {
+		schema.err = relation.Validate()
+	}
+
 	if schema.err == nil {
 		schema.Relationships.Relations[relation.Name] = relation
 		switch relation.Type {
@@ -115,6 +154,8 @@ func (schema *Schema) parseRelation(field *Field) {
 			schema.Relationships.BelongsTo = append(schema.Relationships.BelongsTo, relation)
 		case Many2Many:
 			schema.Relationships.Many2Many = append(schema.Relationships.Many2Many, relation)
+		case HasManyThrough:
+			schema.Relationships.HasManyThrough = append(schema.Relationships.HasManyThrough, relation)
 		}
 	}
 }
@@ -179,6 +220,116 @@ func (schema *Schema) buildPolymorphicRelation(relation *Relationship, field *Fi
 	relation.Type = "has"
 }
 
+// User has many Languages, primary keys of its languages are stored in a json array column `language_ids`
+//     type User struct {
+//       LanguageIDs string     `gorm:"type:jsonb"`
+//       Languages   []Language `gorm:"many2many_json:language_ids"`
+//     }
+func (schema *Schema) buildMany2ManyJSONRelation(relation *Relationship, field *Field, jsonColumn string) {
+	relation.Type = Many2ManyJSON
+
+	if field.IndirectFieldType.Kind() != reflect.Slice {
+		schema.err = fmt.Errorf("invalid many2many json relation for %v on field %v, it should be a slice", schema, field.Name)
+		return
+	}
+
+	jsonField := schema.LookUpField(jsonColumn)
+	if jsonField == nil {
+		schema.err = fmt.Errorf("invalid many2many json column %v for %v on field %v", jsonColumn, schema, field.Name)
+		return
+	}
+
+	primaryField := relation.FieldSchema.PrioritizedPrimaryField
+	if len(relation.primaryKeys) > 0 {
+		if primaryField = relation.FieldSchema.LookUpField(relation.primaryKeys[0]); primaryField == nil || len(relation.primaryKeys) > 1 {
+			schema.err = fmt.Errorf("invalid many2many json references %+v for %v on field %v", relation.primaryKeys, schema, field.Name)
+			return
+		}
+	}
+
+	if primaryField == nil {
+		schema.err = fmt.Errorf("invalid many2many json relation for %v on field %v, %v has no primary key", schema, field.Name, relation.FieldSchema)
+		return
+	}
+
+	relation.References = append(relation.References, &Reference{PrimaryKey: primaryField, ForeignKey: jsonField})
+}
+
+// User has many Comments through Posts, its comments are the comments of its posts, the intermediate relation's schema is
+// used as the join table, comments are queried by joining posts, the relation is read only
+//     type User struct {
+//       Posts    []Post
+//       Comments []Comment `gorm:"through:Posts"`
+//     }
+//     // Post has many Comments
+//     type Post struct {
+//       UserID   uint
+//       Comments []Comment
+//     }
+func (schema *Schema) buildHasManyThroughRelation(relation *Relationship, field *Field, through string) {
+	relation.Type = HasManyThrough
+
+	if field.IndirectFieldType.Kind() != reflect.Slice {
+		schema.err = fmt.Errorf("invalid has many through relation for %v on field %v, it should be a slice", schema, field.Name)
+		return
+	}
+
+	// the through relation is parsed in advance if it's declared after the relation
+	throughRel := schema.Relationships.Relations[through]
+	if f := schema.FieldsByName[through]; throughRel == nil && f != nil && f.DataType == "" {
+		if schema.parseRelation(f); schema.err != nil {
+			return
+		}
+		throughRel = schema.Relationships.Relations[through]
+	}
+
+	if throughRel == nil || (throughRel.Type != HasOne && throughRel.Type != HasMany) {
+		schema.err = fmt.Errorf("invalid through relation %v for %v on field %v, it should be a has one or has many relation", through, schema, field.Name)
+		return
+	}
+
+	relation.JoinTable = throughRel.FieldSchema
+	relation.through = &throughRelation{relation: throughRel}
+}
+
+// ParseThrough resolves references of has many through relations with the intermediate schema's relation, it's called
+// when the relation is used first, e.g: Statement.Parse, as the intermediate schema may be parsed after the relation
+func (rel *Relationship) ParseThrough() error {
+	if rel.through == nil {
+		return nil
+	}
+
+	rel.through.once.Do(func() {
+		var (
+			throughRel   = rel.through.relation
+			intermediate = throughRel.FieldSchema.Relationships.Relations[rel.Name]
+			references   []*Reference
+		)
+
+		if intermediate == nil || (intermediate.Type != HasOne && intermediate.Type != HasMany) || intermediate.Polymorphic != nil || intermediate.FieldSchema != rel.FieldSchema {
+			rel.through.err = fmt.Errorf("invalid has many through relation for %v on field %v, %v should have one or many %v by non polymorphic relation %v", rel.Schema, rel.Name, throughRel.FieldSchema, rel.FieldSchema, rel.Name)
+			return
+		}
+
+		// the through relation's references link the owner to the join table, then the join table's primary keys are
+		// referenced by the associations's foreign keys, which are swapped like references of many2many join tables
+		for _, ref := range throughRel.References {
+			references = append(references, &Reference{
+				PrimaryKey:    ref.PrimaryKey,
+				PrimaryValue:  ref.PrimaryValue,
+				ForeignKey:    ref.ForeignKey,
+				OwnPrimaryKey: ref.OwnPrimaryKey,
+			})
+		}
+
+		for _, ref := range intermediate.References {
+			references = append(references, &Reference{PrimaryKey: ref.ForeignKey, ForeignKey: ref.PrimaryKey})
+		}
+		rel.References = references
+	})
+	return rel.through.err
+}
+
 func (schema *Schema) buildMany2ManyRelation(relation *Relationship, field *Field, many2many string) {
 	relation.Type = Many2Many
 
@@ -200,7 +351,9 @@ func (schema *Schema) buildMany2ManyRelation(relation *Relationship, field *Fiel
 			if field := schema.LookUpField(foreignKey); field != nil {
 				ownForeignFields = append(ownForeignFields, field)
 			} else {
-				schema.err = fmt.Errorf("invalid foreign key: %v", foreignKey)
+				if schema.err = relation.Validate(); schema.err == nil {
+					schema.err = fmt.Errorf("invalid foreign key: %v", foreignKey)
+				}
 				return
 			}
 		}
@@ -212,7 +365,9 @@ func (schema *Schema) buildMany2ManyRelation(relation *Relationship, field *Fiel
 			if field := relation.FieldSchema.LookUpField(foreignKey); field != nil {
 				refForeignFields = append(refForeignFields, field)
 			} else {
-				schema.err = fmt.Errorf("invalid foreign key: %v", foreignKey)
+				if schema.err = relation.Validate(); schema.err == nil {
+					schema.err = fmt.Errorf("invalid foreign key: %v", foreignKey)
+				}
 				return
 			}
 		}
@@ -362,7 +517,9 @@ func (schema *Schema) guessRelation(relation *Relationship, field *Field, gl gue
 			schema.guessRelation(relation, field, guessEmbeddedHas)
 		// case guessEmbeddedHas:
 		default:
-			schema.err = fmt.Errorf("invalid field found for struct %v's field %v, need to define a foreign key for relations or it need to implement the Valuer/Scanner interface", schema, field.Name)
+			if schema.err = relation.Validate(); schema.err == nil {
+				schema.err = fmt.Errorf("invalid field found for struct %v's field %v, need to define a foreign key for relations or it need to implement the Valuer/Scanner interface", schema, field.Name)
+			}
 		}
 	}
 
@@ -473,7 +630,7 @@ type Constraint struct {
 
 func (rel *Relationship) ParseConstraint() *Constraint {
 	str := rel.Field.TagSettings["CONSTRAINT"]
-	if str == "-" {
+	if str == "-" || rel.Type == Many2ManyJSON {
 		return nil
 	}
 
@@ -518,24 +675,163 @@ func (rel *Relationship) ParseConstraint() *Constraint {
 	return &constraint
 }
 
+// Validate checks the relationship's foreign keys and references tags refer to fields of its schemas, and its references
+// are columns, errors include the relationship's field path, e.g: invalid foreign key UserRefer for relation User.Profile
+func (rel *Relationship) Validate() error {
+	var (
+		path         = rel.Schema.Name + "." + rel.Name
+		ownerSchemas = []*Schema{rel.Schema}
+	)
+
+	if rel.Field != nil && rel.Field.OwnerSchema != nil {
+		ownerSchemas = append(ownerSchemas, rel.Field.OwnerSchema)
+	}
+
+	// foreign keys of many2many relations are the owner's fields and references are the associations's fields,
+	// otherwise they could be fields of either side depending on the relationship is has one/has many or belongs to
+	foreignKeySchemas := append(append([]*Schema{}, ownerSchemas...), rel.FieldSchema)
+	referenceSchemas := foreignKeySchemas
+	if rel.Type == Many2Many {
+		foreignKeySchemas, referenceSchemas = ownerSchemas, []*Schema{rel.FieldSchema}
+	}
+
+	checkFields := func(kind string, names []string, schemas []*Schema) error {
+		for _, name := range names {
+			found := false
+			for _, s := range schemas {
+				if field := s.LookUpField(name); field != nil && field.DBName != "" {
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				schemaNames := make([]string, len(schemas))
+				for idx, s := range schemas {
+					schemaNames[idx] = s.Name
+				}
+				return fmt.Errorf("invalid %v %v for relation %v, it isn't a column of %v", kind, name, path, strings.Join(schemaNames, " or "))
+			}
+		}
+		return nil
+	}
+
+	if err := checkFields("foreign key", rel.foreignKeys, foreignKeySchemas); err != nil {
+		return err
+	} else if err := checkFields("references", rel.primaryKeys, referenceSchemas); err != nil {
+		return err
+	}
+
+	for _, ref := range rel.References {
+		if ref.ForeignKey == nil || ref.ForeignKey.DBName == "" {
+			return fmt.Errorf("invalid foreign key for relation %v, it isn't a column", path)
+		} else if ref.PrimaryKey != nil && ref.PrimaryKey.DBName == "" {
+			return fmt.Errorf("invalid references %v for relation %v, it isn't a column", ref.PrimaryKey.Name, path)
+		}
+	}
+	return nil
+}
+
+// PositionField returns the join table field storing positions of many2many associations, which is set with tag `position`,
+// e.g: Songs []Song `gorm:"many2many:playlist_songs;position:sort_order"`, the join table should be set up with the field
+func (rel *Relationship) PositionField() *Field {
+	if rel.JoinTable != nil && rel.Field != nil {
+		if position := rel.Field.TagSettings["POSITION"]; position != "" {
+			return rel.JoinTable.LookUpField(position)
+		}
+	}
+	return nil
+}
+
+// IsSelfReferential returns true if the relationship refers to its own schema
+func (rel *Relationship) IsSelfReferential() bool {
+	return rel.FieldSchema == rel.Schema
+}
+
 func (rel *Relationship) ToQueryConditions(reflectValue reflect.Value) (conds []clause.Expression) {
-	table := rel.FieldSchema.Table
-	foreignFields := []*Field{}
-	relForeignKeys := []string{}
+	_, foreignValues := GetIdentityFieldValuesMap(reflectValue, rel.loadQueryConditions().foreignFields)
+	return rel.toQueryConditions(foreignValues)
+}
+
+// ToQueryConditionsForValues builds query conditions matching associations of all owners in reflectValues, which could be
+// structs or slices of owners, the owners are combined in one IN condition, e.g: to load associations of several owners
+// in a standalone query
+func (rel *Relationship) ToQueryConditionsForValues(reflectValues ...reflect.Value) []clause.Expression {
+	var (
+		foreignFields = rel.loadQueryConditions().foreignFields
+		foreignValues [][]interface{}
+		loaded        = map[string]bool{}
+	)
+
+	for _, reflectValue := range reflectValues {
+		_, values := GetIdentityFieldValuesMap(reflect.Indirect(reflectValue), foreignFields)
+		for _, value := range values {
+			if key := utils.ToLengthPrefixedKey(value...); !loaded[key] {
+				loaded[key] = true
+				foreignValues = append(foreignValues, value)
+			}
+		}
+	}
+	return rel.toQueryConditions(foreignValues)
+}
+
+// ToQueryConditionsForKeys builds query conditions matching associations of owners identified by keys, a key is the value
+// of the owner's field referenced by the relation, e.g: the owner's primary key for has many relations, or the owner's
+// foreign key for belongs to relations, use []interface{} keys for composite references
+func (rel *Relationship) ToQueryConditionsForKeys(keys ...interface{}) []clause.Expression {
+	foreignValues := make([][]interface{}, 0, len(keys))
+	for _, key := range keys {
+		if values, ok := key.([]interface{}); ok {
+			foreignValues = append(foreignValues, values)
+		} else {
+			foreignValues = append(foreignValues, []interface{}{key})
+		}
+	}
+	return rel.toQueryConditions(foreignValues)
+}
+
+// ToSubQueryConditions builds query conditions matching associations of owners selected by a subquery, selectOwners
+// returns the subquery selecting owners's fields referenced by the relation, e.g: a *gorm.DB selecting owners's primary keys
+func (rel *Relationship) ToSubQueryConditions(selectOwners func(fields []*Field) interface{}) (conds []clause.Expression) {
+	queryConds := rel.loadQueryConditions()
+	column, _ := ToQueryValues(queryConds.table, queryConds.foreignKeys, nil)
+
+	conds = make([]clause.Expression, 0, len(queryConds.conds)+1)
+	conds = append(conds, queryConds.conds...)
+	conds = append(conds, clause.Expr{SQL: "? IN (?)", Vars: []interface{}{column, selectOwners(queryConds.foreignFields)}})
+	return
+}
+
+func (rel *Relationship) toQueryConditions(foreignValues [][]interface{}) (conds []clause.Expression) {
+	queryConds := rel.loadQueryConditions()
+	column, values := ToQueryValues(queryConds.table, queryConds.foreignKeys, foreignValues)
 
+	conds = make([]clause.Expression, 0, len(queryConds.conds)+1)
+	conds = append(conds, queryConds.conds...)
+	conds = append(conds, clause.IN{Column: column, Values: values})
+	return
+}
+
+// loadQueryConditions returns cached static parts of query conditions, rebuilds them if the join table changed
+func (rel *Relationship) loadQueryConditions() *queryConditions {
+	if cached, ok := rel.queryConditions.Load().(*queryConditions); ok && cached.joinTable == rel.JoinTable {
+		return cached
+	}
+
+	queryConds := &queryConditions{joinTable: rel.JoinTable, table: rel.FieldSchema.Table}
 	if rel.JoinTable != nil {
-		table = rel.JoinTable.Table
+		queryConds.table = rel.JoinTable.Table
 		for _, ref := range rel.References {
 			if ref.OwnPrimaryKey {
-				foreignFields = append(foreignFields, ref.PrimaryKey)
-				relForeignKeys = append(relForeignKeys, ref.ForeignKey.DBName)
+				queryConds.foreignFields = append(queryConds.foreignFields, ref.PrimaryKey)
+				queryConds.foreignKeys = append(queryConds.foreignKeys, ref.ForeignKey.DBName)
 			} else if ref.PrimaryValue != "" {
-				conds = append(conds, clause.Eq{
+				queryConds.conds = append(queryConds.conds, clause.Eq{
 					Column: clause.Column{Table: rel.JoinTable.Table, Name: ref.ForeignKey.DBName},
 					Value:  ref.PrimaryValue,
 				})
 			} else {
-				conds = append(conds, clause.Eq{
+				queryConds.conds = append(queryConds.conds, clause.Eq{
 					Column: clause.Column{Table: rel.JoinTable.Table, Name: ref.ForeignKey.DBName},
 					Value:  clause.Column{Table: rel.FieldSchema.Table, Name: ref.PrimaryKey.DBName},
 				})
@@ -544,23 +840,20 @@ func (rel *Relationship) ToQueryConditions(reflectValue reflect.Value) (conds []
 	} else {
 		for _, ref := range rel.References {
 			if ref.OwnPrimaryKey {
-				relForeignKeys = append(relForeignKeys, ref.ForeignKey.DBName)
-				foreignFields = append(foreignFields, ref.PrimaryKey)
+				queryConds.foreignKeys = append(queryConds.foreignKeys, ref.ForeignKey.DBName)
+				queryConds.foreignFields = append(queryConds.foreignFields, ref.PrimaryKey)
 			} else if ref.PrimaryValue != "" {
-				conds = append(conds, clause.Eq{
+				queryConds.conds = append(queryConds.conds, clause.Eq{
 					Column: clause.Column{Table: rel.FieldSchema.Table, Name: ref.ForeignKey.DBName},
 					Value:  ref.PrimaryValue,
 				})
 			} else {
-				relForeignKeys = append(relForeignKeys, ref.PrimaryKey.DBName)
-				foreignFields = append(foreignFields, ref.ForeignKey)
+				queryConds.foreignKeys = append(queryConds.foreignKeys, ref.PrimaryKey.DBName)
+				queryConds.foreignFields = append(queryConds.foreignFields, ref.ForeignKey)
 			}
 		}
 	}
 
-	_, foreignValues := GetIdentityFieldValuesMap(reflectValue, foreignFields)
-	column, values := ToQueryValues(table, relForeignKeys, foreignValues)
-
-	conds = append(conds, clause.IN{Column: column, Values: values})
-	return
+	rel.queryConditions.Store(queryConds)
+	return queryConds
 }
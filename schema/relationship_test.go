@@ -1,10 +1,13 @@
 package schema_test
 
 import (
+	"reflect"
+	"strings"
 	"sync"
 	"testing"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/schema"
 )
 
@@ -321,3 +324,253 @@ func TestMultipleMany2Many(t *testing.T) {
 		},
 	)
 }
+
+type ThroughComment struct {
+	ID               int
+	ThroughArticleID int
+}
+
+type ThroughArticle struct {
+	ID              int
+	ThroughEditorID int
+	ThroughEditor   *ThroughEditor
+	Comments        []ThroughComment
+}
+
+type ThroughEditor struct {
+	ID       int
+	Articles []ThroughArticle
+	Comments []ThroughComment `gorm:"through:Articles"`
+}
+
+func TestHasManyThrough(t *testing.T) {
+	type Comment struct {
+		ID     int
+		PostID int
+	}
+
+	type Post struct {
+		ID       int
+		AuthorID int
+		Comments []Comment
+	}
+
+	// the through relation is declared after the has many through relation
+	type Author struct {
+		ID       int
+		Comments []Comment `gorm:"through:Posts"`
+		Posts    []Post
+	}
+
+	s, err := schema.Parse(&Author{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatalf("failed to parse schema, got error %v", err)
+	}
+
+	// references are resolved when the relation is used first
+	if err := s.Relationships.Relations["Comments"].ParseThrough(); err != nil {
+		t.Fatalf("failed to parse through relation, got error %v", err)
+	}
+
+	checkSchemaRelation(t, s, Relation{
+		Name: "Comments", Type: schema.HasManyThrough, Schema: "Author", FieldSchema: "Comment",
+		JoinTable: JoinTable{Name: "Post", Table: "posts"},
+		References: []Reference{
+			{"ID", "Author", "AuthorID", "Post", "", true},
+			{"PostID", "Comment", "ID", "Post", "", false},
+		},
+	})
+	checkSchemaRelation(t, s, Relation{
+		Name: "Posts", Type: schema.HasMany, Schema: "Author", FieldSchema: "Post",
+		References: []Reference{{"ID", "Author", "AuthorID", "Post", "", true}},
+	})
+
+	// the intermediate schema is parsed first, its relations aren't parsed yet when parsing the through relation
+	article, err := schema.Parse(&ThroughArticle{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatalf("failed to parse schema, got error %v", err)
+	}
+
+	editor := article.Relationships.Relations["ThroughEditor"].FieldSchema
+	if err := editor.Relationships.Relations["Comments"].ParseThrough(); err != nil {
+		t.Fatalf("failed to parse through relation, got error %v", err)
+	}
+
+	checkSchemaRelation(t, editor, Relation{
+		Name: "Comments", Type: schema.HasManyThrough, Schema: "ThroughEditor", FieldSchema: "ThroughComment",
+		JoinTable: JoinTable{Name: "ThroughArticle", Table: "through_articles"},
+		References: []Reference{
+			{"ID", "ThroughEditor", "ThroughEditorID", "ThroughArticle", "", true},
+			{"ThroughArticleID", "ThroughComment", "ID", "ThroughArticle", "", false},
+		},
+	})
+
+	type InvalidAuthor struct {
+		ID       int
+		Comments []Comment `gorm:"through:Profile"`
+	}
+
+	if _, err := schema.Parse(&InvalidAuthor{}, &sync.Map{}, schema.NamingStrategy{}); err == nil || !strings.Contains(err.Error(), "invalid through relation Profile") {
+		t.Errorf("should return error for invalid through relation, got %v", err)
+	}
+}
+
+func TestRelationshipIsSelfReferential(t *testing.T) {
+	type Company struct {
+		ID   int
+		Name string
+	}
+
+	type Employee struct {
+		ID        int
+		Name      string
+		ManagerID *int
+		Reports   []Employee `gorm:"foreignKey:ManagerID"`
+		CompanyID int
+		Company   Company
+	}
+
+	s, err := schema.Parse(&Employee{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatalf("failed to parse schema, got error %v", err)
+	}
+
+	if rel := s.Relationships.Relations["Reports"]; !rel.IsSelfReferential() {
+		t.Errorf("Reports should be self referential")
+	}
+
+	if rel := s.Relationships.Relations["Company"]; rel.IsSelfReferential() {
+		t.Errorf("Company should not be self referential")
+	}
+}
+
+func TestRelationshipValidate(t *testing.T) {
+	type Profile struct {
+		ID        int
+		UserRefer int
+	}
+
+	type Language struct {
+		Code string `gorm:"primaryKey"`
+	}
+
+	type User struct {
+		ID      int
+		Profile Profile `gorm:"foreignKey:UserRef"`
+	}
+
+	type ValidUser struct {
+		ID      int
+		Profile Profile `gorm:"foreignKey:UserRefer"`
+	}
+
+	if _, err := schema.Parse(&User{}, &sync.Map{}, schema.NamingStrategy{}); err == nil {
+		t.Fatalf("should return error for invalid foreign key")
+	} else if !strings.Contains(err.Error(), "invalid foreign key UserRef for relation User.Profile") {
+		t.Errorf("error should contain the foreign key and relationship's field path, got %v", err)
+	}
+
+	type UserWithLanguages struct {
+		ID        int
+		Languages []Language `gorm:"many2many:user_languages;references:Name"`
+	}
+
+	if _, err := schema.Parse(&UserWithLanguages{}, &sync.Map{}, schema.NamingStrategy{}); err == nil {
+		t.Fatalf("should return error for invalid references")
+	} else if !strings.Contains(err.Error(), "invalid references Name for relation UserWithLanguages.Languages, it isn't a column of Language") {
+		t.Errorf("error should contain the references and relationship's field path, got %v", err)
+	}
+
+	s, err := schema.Parse(&ValidUser{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatalf("failed to parse schema, got error %v", err)
+	}
+
+	if err := s.Relationships.Relations["Profile"].Validate(); err != nil {
+		t.Errorf("relationship should be valid, got error %v", err)
+	}
+}
+
+func TestRelationshipToQueryConditions(t *testing.T) {
+	type Tag struct {
+		ID   int
+		Name string
+	}
+
+	type Post struct {
+		ID   int
+		Tags []Tag `gorm:"many2many:post_tags"`
+	}
+
+	s, err := schema.Parse(&Post{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatalf("failed to parse schema, got error %v", err)
+	}
+
+	rel := s.Relationships.Relations["Tags"]
+	conds := rel.ToQueryConditions(reflect.ValueOf(Post{ID: 1}))
+	if len(conds) != 2 {
+		t.Fatalf("should have join condition and foreign key condition, but got %+v", conds)
+	}
+
+	// appending to returned conditions should not change cached conditions
+	_ = append(conds[:1], clause.Eq{Column: "name", Value: "tag"})
+
+	conds = rel.ToQueryConditions(reflect.ValueOf(Post{ID: 2}))
+	if _, ok := conds[0].(clause.Eq); !ok || len(conds) != 2 {
+		t.Fatalf("cached conditions should not be changed, but got %+v", conds)
+	}
+
+	if in, ok := conds[1].(clause.IN); !ok || !reflect.DeepEqual(in.Values, []interface{}{2}) {
+		t.Errorf("conditions should be built with current values, but got %+v", conds[1])
+	}
+}
+
+func TestRelationshipToQueryConditionsForOwners(t *testing.T) {
+	type Tag struct {
+		ID   int
+		Name string
+	}
+
+	type Comment struct {
+		ID     int
+		PostID int
+	}
+
+	type Post struct {
+		ID       int
+		Tags     []Tag `gorm:"many2many:post_tags"`
+		Comments []Comment
+	}
+
+	s, err := schema.Parse(&Post{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatalf("failed to parse schema, got error %v", err)
+	}
+
+	rel := s.Relationships.Relations["Comments"]
+	conds := rel.ToQueryConditionsForValues(reflect.ValueOf(Post{ID: 1}), reflect.ValueOf([]Post{{ID: 2}, {ID: 1}}), reflect.ValueOf(&Post{ID: 3}))
+	if len(conds) != 1 {
+		t.Fatalf("should have foreign key condition only, but got %+v", conds)
+	}
+
+	expected := clause.IN{Column: clause.Column{Table: "comments", Name: "post_id"}, Values: []interface{}{1, 2, 3}}
+	if !reflect.DeepEqual(conds[0], expected) {
+		t.Errorf("owners should be combined in one IN condition, expects %+v, but got %+v", expected, conds[0])
+	}
+
+	if conds := rel.ToQueryConditionsForKeys(1, 2, 3); len(conds) != 1 || !reflect.DeepEqual(conds[0], expected) {
+		t.Errorf("keys should be combined in one IN condition, expects %+v, but got %+v", expected, conds)
+	}
+
+	rel = s.Relationships.Relations["Tags"]
+	conds = rel.ToQueryConditionsForKeys(1, 2, 3)
+	if len(conds) != 2 {
+		t.Fatalf("should have join condition and foreign key condition, but got %+v", conds)
+	}
+
+	expected = clause.IN{Column: clause.Column{Table: "post_tags", Name: "post_id"}, Values: []interface{}{1, 2, 3}}
+	if !reflect.DeepEqual(conds[1], expected) {
+		t.Errorf("keys should be combined in one IN condition of the join table, expects %+v, but got %+v", expected, conds[1])
+	}
+}
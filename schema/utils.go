@@ -154,6 +154,35 @@ func GetIdentityFieldValuesMap(reflectValue reflect.Value, fields []*Field) (map
 	return dataResults, results
 }
 
+// ForEachIdentityFieldValues calls fn with each element's field values without building an identity map,
+// elements whose field values are all zero are skipped, duplicated values are not merged
+func ForEachIdentityFieldValues(reflectValue reflect.Value, fields []*Field, fn func(elem reflect.Value, values []interface{})) {
+	callFn := func(elem reflect.Value) {
+		var (
+			fieldValues   = make([]interface{}, len(fields))
+			notZero, zero bool
+		)
+
+		for idx, field := range fields {
+			fieldValues[idx], zero = field.ValueOf(elem)
+			notZero = notZero || !zero
+		}
+
+		if notZero {
+			fn(elem, fieldValues)
+		}
+	}
+
+	switch reflectValue.Kind() {
+	case reflect.Struct:
+		callFn(reflectValue)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < reflectValue.Len(); i++ {
+			callFn(reflectValue.Index(i))
+		}
+	}
+}
+
 // GetIdentityFieldValuesMapFromValues get identity map from fields
 func GetIdentityFieldValuesMapFromValues(values []interface{}, fields []*Field) (map[string][]reflect.Value, [][]interface{}) {
 	resultsMap := map[string][]reflect.Value{}
@@ -2,6 +2,7 @@ package schema
 
 import (
 	"reflect"
+	"sync"
 	"testing"
 )
 
@@ -22,3 +23,72 @@ func TestRemoveSettingFromTag(t *testing.T) {
 		}
 	}
 }
+
+type identityOwner struct {
+	ID   uint
+	Name string
+}
+
+func TestForEachIdentityFieldValues(t *testing.T) {
+	s, err := Parse(&identityOwner{}, &sync.Map{}, NamingStrategy{})
+	if err != nil {
+		t.Fatalf("failed to parse identity owner, got error %v", err)
+	}
+
+	owners := []identityOwner{{ID: 1}, {Name: "no primary key"}, {ID: 2}, {ID: 1}}
+	_, expected := GetIdentityFieldValuesMap(reflect.ValueOf(owners), s.PrimaryFields)
+
+	var results [][]interface{}
+	ForEachIdentityFieldValues(reflect.ValueOf(owners), s.PrimaryFields, func(elem reflect.Value, values []interface{}) {
+		results = append(results, values)
+	})
+
+	if !reflect.DeepEqual(results, append(expected, []interface{}{uint(1)})) {
+		t.Errorf("expects identity values %v with duplicated values, but got %v", expected, results)
+	}
+
+	results = nil
+	ForEachIdentityFieldValues(reflect.ValueOf(owners[2]), s.PrimaryFields, func(elem reflect.Value, values []interface{}) {
+		results = append(results, values)
+	})
+
+	if !reflect.DeepEqual(results, [][]interface{}{{uint(2)}}) {
+		t.Errorf("expects identity values of struct, but got %v", results)
+	}
+}
+
+func benchmarkIdentityOwners(b *testing.B) (*Schema, reflect.Value) {
+	s, err := Parse(&identityOwner{}, &sync.Map{}, NamingStrategy{})
+	if err != nil {
+		b.Fatalf("failed to parse identity owner, got error %v", err)
+	}
+
+	owners := make([]identityOwner, 100000)
+	for idx := range owners {
+		owners[idx].ID = uint(idx + 1)
+	}
+	return s, reflect.ValueOf(owners)
+}
+
+func BenchmarkGetIdentityFieldValuesMap(b *testing.B) {
+	s, owners := benchmarkIdentityOwners(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		GetIdentityFieldValuesMap(owners, s.PrimaryFields)
+	}
+}
+
+func BenchmarkForEachIdentityFieldValues(b *testing.B) {
+	s, owners := benchmarkIdentityOwners(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var values [][]interface{}
+		ForEachIdentityFieldValues(owners, s.PrimaryFields, func(_ reflect.Value, fieldValues []interface{}) {
+			values = append(values, fieldValues)
+		})
+	}
+}
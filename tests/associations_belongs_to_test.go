@@ -217,3 +217,226 @@ func TestBelongsToAssociationForSlice(t *testing.T) {
 	AssertAssociationCount(t, users[0], "Company", 0, "After Delete")
 	AssertAssociationCount(t, users[1], "Company", 1, "After other user Delete")
 }
+
+func TestBelongsToAssociationFindWithPreload(t *testing.T) {
+	type PreloadDepartmentMember struct {
+		ID                  uint
+		Name                string
+		PreloadDepartmentID uint
+	}
+
+	type PreloadDepartment struct {
+		ID               uint
+		Name             string
+		PreloadCompanyID uint
+		Members          []PreloadDepartmentMember
+	}
+
+	type PreloadCompany struct {
+		ID          uint
+		Name        string
+		Departments []PreloadDepartment
+	}
+
+	type PreloadEmployee struct {
+		ID               uint
+		Name             string
+		PreloadCompanyID uint
+		PreloadCompany   PreloadCompany
+	}
+
+	DB.Migrator().DropTable(&PreloadDepartmentMember{}, &PreloadDepartment{}, &PreloadCompany{}, &PreloadEmployee{})
+	if err := DB.AutoMigrate(&PreloadCompany{}, &PreloadDepartment{}, &PreloadDepartmentMember{}, &PreloadEmployee{}); err != nil {
+		t.Fatalf("Failed to auto migrate, but got error %v", err)
+	}
+
+	employee := PreloadEmployee{Name: "employee", PreloadCompany: PreloadCompany{
+		Name: "company",
+		Departments: []PreloadDepartment{
+			{Name: "department-1", Members: []PreloadDepartmentMember{{Name: "member-1"}, {Name: "member-2"}}},
+			{Name: "department-2", Members: []PreloadDepartmentMember{{Name: "member-3"}}},
+		},
+	}}
+
+	if err := DB.Create(&employee).Error; err != nil {
+		t.Fatalf("errors happened when create: %v", err)
+	}
+
+	var company PreloadCompany
+	if err := DB.Model(&employee).Preload("Departments.Members").Association("PreloadCompany").Find(&company); err != nil {
+		t.Fatalf("errors happened when find company: %v", err)
+	}
+
+	if company.Name != "company" || len(company.Departments) != 2 {
+		t.Fatalf("company's departments should be preloaded, but got %+v", company)
+	}
+
+	if len(company.Departments[0].Members)+len(company.Departments[1].Members) != 3 {
+		t.Errorf("departments's members should be preloaded, but got %+v", company.Departments)
+	}
+}
+
+func TestBelongsToAssociationForEmbeddedStruct(t *testing.T) {
+	type EmbeddedAuthorBase struct {
+		CreatedByID *uint
+		CreatedBy   User
+	}
+
+	type EmbeddedAuthorPost struct {
+		ID    uint
+		Title string
+		EmbeddedAuthorBase
+	}
+
+	DB.Migrator().DropTable(&EmbeddedAuthorPost{})
+	if err := DB.AutoMigrate(&EmbeddedAuthorPost{}); err != nil {
+		t.Fatalf("Failed to migrate, got %v", err)
+	}
+
+	post := EmbeddedAuthorPost{Title: "embedded"}
+	DB.Create(&post)
+
+	author := *GetUser("embedded-author", Config{})
+	if err := DB.Model(&post).Association("CreatedBy").Append(&author); err != nil {
+		t.Fatalf("failed to append embedded association, got error %v", err)
+	}
+
+	if post.CreatedByID == nil || *post.CreatedByID != author.ID || post.CreatedBy.ID != author.ID {
+		t.Errorf("should assign author to embedded struct, but got %+v", post.EmbeddedAuthorBase)
+	}
+
+	var result User
+	if err := DB.Model(&post).Association("CreatedBy").Find(&result); err != nil || result.ID != author.ID {
+		t.Errorf("should find embedded association, got %+v, error: %v", result, err)
+	}
+
+	var loaded EmbeddedAuthorPost
+	DB.First(&loaded, post.ID)
+	if loaded.CreatedByID == nil || *loaded.CreatedByID != author.ID {
+		t.Errorf("should save foreign key of embedded struct, but got %+v", loaded.EmbeddedAuthorBase)
+	}
+
+	author2 := *GetUser("embedded-author-2", Config{})
+	if err := DB.Model(&post).Association("CreatedBy").Replace(&author2); err != nil || *post.CreatedByID != author2.ID {
+		t.Errorf("failed to replace embedded association, got %+v, error: %v", post.EmbeddedAuthorBase, err)
+	}
+
+	if err := DB.Model(&post).Association("CreatedBy").Clear(); err != nil || post.CreatedByID != nil {
+		t.Errorf("failed to clear embedded association, got %+v, error: %v", post.EmbeddedAuthorBase, err)
+	}
+
+	if count := DB.Model(&post).Association("CreatedBy").Count(); count != 0 {
+		t.Errorf("should clear embedded association, but got %v", count)
+	}
+}
+
+func TestBelongsToAssociationClear(t *testing.T) {
+	user := *GetUser("belongs-to-clear", Config{Company: true, Manager: true})
+	DB.Create(&user)
+
+	if err := DB.Model(&user).Association("Company").Clear(); err != nil {
+		t.Fatalf("failed to clear company, got error %v", err)
+	}
+
+	if err := DB.Model(&user).Association("Manager").Clear(); err != nil {
+		t.Fatalf("failed to clear manager, got error %v", err)
+	}
+
+	if user.CompanyID != nil || user.Company.Name != "" || user.ManagerID != nil || user.Manager != nil {
+		t.Errorf("owner's field and foreign key should be zeroed, got %+v, %v, %+v, %v", user.Company, user.CompanyID, user.Manager, user.ManagerID)
+	}
+
+	var result User
+	DB.First(&result, user.ID)
+	if result.CompanyID != nil || result.ManagerID != nil {
+		t.Errorf("owner's foreign keys should be null, got %v, %v", result.CompanyID, result.ManagerID)
+	}
+
+	unsaved := *GetUser("belongs-to-clear-unsaved", Config{Company: true})
+	if writes := countWrites(func() {
+		if err := DB.Model(&unsaved).Association("Company").Clear(); err != nil {
+			t.Errorf("failed to clear company of unsaved user, got error %v", err)
+		}
+	}); writes != 0 {
+		t.Errorf("clearing unsaved owner shouldn't touch the database, got %v writes", writes)
+	}
+
+	if unsaved.Company.Name != "" {
+		t.Errorf("owner's field should be zeroed, got %+v", unsaved.Company)
+	}
+}
+
+func TestBelongsToAssociationDeleteAll(t *testing.T) {
+	users := []User{*GetUser("belongs-to-delete-all-1", Config{Company: true}), *GetUser("belongs-to-delete-all-2", Config{Company: true})}
+	DB.Create(&users)
+
+	rowsAffected, err := DB.Model(&users).Association("Company").DeleteAll()
+	if err != nil {
+		t.Fatalf("failed to delete all companies, got error %v", err)
+	}
+
+	if rowsAffected != 2 {
+		t.Errorf("rows affected should be %v, got %v", 2, rowsAffected)
+	}
+
+	for _, user := range users {
+		if user.CompanyID == nil || user.Company.Name == "" {
+			t.Errorf("owner shouldn't be changed in memory, got %+v, %v", user.Company, user.CompanyID)
+		}
+
+		var result User
+		DB.First(&result, user.ID)
+		if result.CompanyID != nil {
+			t.Errorf("owner's foreign key should be null, got %v", *result.CompanyID)
+		}
+
+		var count int64
+		DB.Model(&Company{}).Where("id = ?", user.Company.ID).Count(&count)
+		if count != 1 {
+			t.Errorf("company should be kept, got %v", count)
+		}
+	}
+}
+
+func TestBelongsToAssociationDeleteResetsForeignKey(t *testing.T) {
+	user := *GetUser("belongs-to-delete-fk", Config{Company: true, Manager: true})
+	DB.Create(&user)
+
+	company, manager := user.Company, *user.Manager
+
+	if err := DB.Model(&user).Association("Company").Delete(&company); err != nil {
+		t.Fatalf("failed to delete company, got error %v", err)
+	}
+
+	if user.CompanyID != nil || user.Company.ID != 0 {
+		t.Errorf("owner's foreign key and company should be zeroed, got %v, %+v", user.CompanyID, user.Company)
+	}
+
+	if err := DB.Model(&user).Association("Manager").Delete(&manager); err != nil {
+		t.Fatalf("failed to delete manager, got error %v", err)
+	}
+
+	if user.ManagerID != nil || user.Manager != nil {
+		t.Errorf("owner's foreign key and manager should be zeroed, got %v, %+v", user.ManagerID, user.Manager)
+	}
+
+	// the relation isn't loaded, only the foreign key
+	user2 := *GetUser("belongs-to-delete-fk-unloaded", Config{Company: true})
+	DB.Create(&user2)
+
+	var loaded User
+	DB.First(&loaded, user2.ID)
+	if err := DB.Model(&loaded).Association("Company").Delete(&user2.Company); err != nil {
+		t.Fatalf("failed to delete company, got error %v", err)
+	}
+
+	if loaded.CompanyID != nil {
+		t.Errorf("owner's foreign key should be zeroed even if the company isn't loaded, got %v", *loaded.CompanyID)
+	}
+
+	var result User
+	DB.First(&result, user2.ID)
+	if result.CompanyID != nil {
+		t.Errorf("owner's foreign key should be null in database, got %v", *result.CompanyID)
+	}
+}
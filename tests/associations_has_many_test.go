@@ -1,8 +1,16 @@
 package tests_test
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	. "gorm.io/gorm/utils/tests"
 )
 
@@ -471,3 +479,1491 @@ func TestPolymorphicHasManyAssociationForSlice(t *testing.T) {
 	DB.Model(&users).Association("Toys").Clear()
 	AssertAssociationCount(t, users, "Toys", 0, "After Clear")
 }
+
+func TestHasManyAssociationAppendInBatches(t *testing.T) {
+	var user = *GetUser("hasmany-append-in-batches", Config{})
+
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatalf("errors happened when create: %v", err)
+	}
+
+	var inserted int
+	DB.Callback().Create().After("gorm:create").Register("TestHasManyAssociationAppendInBatches", func(db *gorm.DB) {
+		if db.Statement.Schema != nil && db.Statement.Schema.Table == "pets" {
+			inserted++
+		}
+	})
+	defer DB.Callback().Create().Remove("TestHasManyAssociationAppendInBatches")
+
+	var pets = []Pet{{Name: "pet-batch-1"}, {Name: "pet-batch-2"}, {Name: "pet-batch-3"}, {Name: "pet-batch-4"}, {Name: "pet-batch-5"}}
+	if err := DB.Session(&gorm.Session{CreateBatchSize: 2}).Model(&user).Association("Pets").Append(&pets); err != nil {
+		t.Fatalf("Error happened when append pets, got %v", err)
+	}
+
+	if inserted != 3 {
+		t.Errorf("pets should be inserted in 3 batches, but got %v", inserted)
+	}
+
+	for _, pet := range pets {
+		if pet.ID == 0 || pet.UserID == nil || *pet.UserID != user.ID {
+			t.Fatalf("Pet's ID and UserID should be filled, but got %+v", pet)
+		}
+	}
+
+	AssertAssociationCount(t, user, "Pets", 5, "AfterAppendInBatches")
+}
+
+func TestHasManyAssociationDeleteWithResult(t *testing.T) {
+	var user = *GetUser("hasmany-delete-with-result", Config{Pets: 3})
+
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatalf("errors happened when create: %v", err)
+	}
+
+	if rowsAffected, err := DB.Model(&user).Association("Pets").DeleteWithResult(&Pet{}); err != nil || rowsAffected != 0 {
+		t.Fatalf("should detach no pet when deleting non-existing data, got %v, error: %v", rowsAffected, err)
+	}
+
+	if rowsAffected, err := DB.Model(&user).Association("Pets").DeleteWithResult(user.Pets[0], user.Pets[1]); err != nil || rowsAffected != 2 {
+		t.Fatalf("should detach two pets, got %v, error: %v", rowsAffected, err)
+	}
+
+	if len(user.Pets) != 1 {
+		t.Errorf("deleted pets should be removed from user's pets, but got %v", len(user.Pets))
+	}
+
+	AssertAssociationCount(t, user, "Pets", 1, "after delete with result")
+}
+
+func TestHasManyAssociationFindWithOrder(t *testing.T) {
+	var user = *GetUser("hasmany-find-with-order", Config{Pets: 3})
+
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatalf("errors happened when create: %v", err)
+	}
+
+	var pets []Pet
+	if err := DB.Model(&user).Association("Pets").Find(&pets, clause.OrderBy{
+		Columns: []clause.OrderByColumn{{Column: clause.Column{Name: "name"}, Desc: true}},
+	}); err != nil || len(pets) != 3 {
+		t.Fatalf("should find three pets, got %v, error: %v", len(pets), err)
+	}
+
+	if pets[0].Name != user.Pets[2].Name || pets[2].Name != user.Pets[0].Name {
+		t.Errorf("pets should be ordered by name desc, but got %v, %v, %v", pets[0].Name, pets[1].Name, pets[2].Name)
+	}
+
+	var pets2 []Pet
+	if err := DB.Model(&user).Order("name desc").Association("Pets").Find(&pets2, "name <> ?", user.Pets[2].Name); err != nil || len(pets2) != 2 {
+		t.Fatalf("should find two pets, got %v, error: %v", len(pets2), err)
+	}
+
+	if pets2[0].Name != user.Pets[1].Name {
+		t.Errorf("pets should be ordered by name desc, but got %v", pets2[0].Name)
+	}
+}
+
+func TestPolymorphicHasManyAssociationClear(t *testing.T) {
+	type Comment struct {
+		ID        uint
+		Content   string
+		OwnerID   *uint
+		OwnerType *string
+	}
+
+	type Article struct {
+		ID       uint
+		Title    string
+		Comments []Comment `gorm:"polymorphic:Owner;"`
+	}
+
+	type Reader struct {
+		ID       uint
+		Name     string
+		Comments []Comment `gorm:"polymorphic:Owner;"`
+	}
+
+	DB.Migrator().DropTable(&Comment{}, &Article{}, &Reader{})
+	if err := DB.AutoMigrate(&Comment{}, &Article{}, &Reader{}); err != nil {
+		t.Fatalf("Failed to migrate, got %v", err)
+	}
+
+	article := Article{Title: "article", Comments: []Comment{{Content: "article-comment-1"}, {Content: "article-comment-2"}}}
+	reader := Reader{Name: "reader", Comments: []Comment{{Content: "reader-comment-1"}}}
+	DB.Create(&article)
+	reader.ID = article.ID
+	DB.Create(&reader)
+
+	if err := DB.Model(&article).Association("Comments").Clear(); err != nil {
+		t.Fatalf("Error happened when clear comments, got %v", err)
+	}
+
+	var count int64
+	if DB.Model(&Comment{}).Where("owner_type = ? AND owner_id IS NULL", "articles").Count(&count); count != 0 {
+		t.Errorf("cleared comments should not keep polymorphic type, but got %v orphaned comments", count)
+	}
+
+	if DB.Model(&Comment{}).Where("owner_type IS NULL AND owner_id IS NULL").Count(&count); count != 2 {
+		t.Errorf("cleared comments should be detached, but got %v", count)
+	}
+
+	if count := DB.Model(&reader).Association("Comments").Count(); count != 1 {
+		t.Errorf("reader's comments should not be changed, but got %v", count)
+	}
+}
+
+func TestHasManyAssociationAppendFillPrimaryKeys(t *testing.T) {
+	var user = *GetUser("hasmany-append-fill-primary-keys", Config{})
+
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatalf("errors happened when create: %v", err)
+	}
+
+	var toys = []Toy{{Name: "toy-append-fill-primary-keys-1"}, {Name: "toy-append-fill-primary-keys-2"}}
+	if err := DB.Model(&user).Association("Toys").Append(&toys); err != nil {
+		t.Fatalf("Error happened when append toys, got %v", err)
+	}
+
+	for _, toy := range toys {
+		if toy.ID == 0 || toy.CreatedAt.IsZero() || toy.OwnerType != "users" {
+			t.Errorf("toy's primary key and default values should be filled, but got %+v", toy)
+		}
+	}
+
+	var pet = &Pet{Name: "pet-append-fill-primary-keys"}
+	if err := DB.Model(&user).Association("Pets").Append(pet); err != nil {
+		t.Fatalf("Error happened when append pet, got %v", err)
+	}
+
+	if pet.ID == 0 || pet.UserID == nil || *pet.UserID != user.ID {
+		t.Errorf("pet's primary key and foreign key should be filled, but got %+v", pet)
+	}
+}
+
+func TestHasManyAssociationFindWithSelect(t *testing.T) {
+	var user = *GetUser("hasmany-find-with-select", Config{Pets: 2})
+
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatalf("errors happened when create: %v", err)
+	}
+
+	var pets []Pet
+	if err := DB.Model(&user).Select("id").Association("Pets").Find(&pets); err != nil || len(pets) != 2 {
+		t.Fatalf("should find two pets, got %v, error: %v", len(pets), err)
+	}
+
+	for _, pet := range pets {
+		if pet.ID == 0 || pet.Name != "" || pet.UserID != nil {
+			t.Errorf("only selected columns should be loaded, but got %+v", pet)
+		}
+	}
+}
+
+func TestHasManyAssociationAppendInBatchesWithErrors(t *testing.T) {
+	type BatchTicket struct {
+		ID           uint
+		Code         string `gorm:"unique"`
+		BatchOwnerID uint
+	}
+
+	type BatchOwner struct {
+		ID      uint
+		Name    string
+		Tickets []BatchTicket
+	}
+
+	DB.Migrator().DropTable(&BatchTicket{}, &BatchOwner{})
+	if err := DB.AutoMigrate(&BatchOwner{}, &BatchTicket{}); err != nil {
+		t.Fatalf("Failed to auto migrate, but got error %v", err)
+	}
+
+	owner := BatchOwner{Name: "append-in-batches-with-errors", Tickets: []BatchTicket{{Code: "ticket-existing"}}}
+	if err := DB.Create(&owner).Error; err != nil {
+		t.Fatalf("errors happened when create: %v", err)
+	}
+
+	tickets := []BatchTicket{{Code: "ticket-1"}, {Code: "ticket-2"}, {Code: "ticket-3"}, {Code: "ticket-existing"}, {Code: "ticket-5"}}
+	err := DB.Model(&owner).Association("Tickets").AppendInBatches(2, &tickets)
+
+	var batchErr *gorm.BatchError
+	if !errors.As(err, &batchErr) || batchErr.Index != 1 {
+		t.Fatalf("should return error of the second batch, but got %v", err)
+	}
+
+	if tickets[0].ID == 0 || tickets[1].ID == 0 || tickets[4].ID != 0 {
+		t.Errorf("only tickets of the first batch should be saved, but got %+v", tickets)
+	}
+
+	if len(owner.Tickets) != 3 {
+		t.Errorf("saved tickets should be appended to owner's tickets, but got %v", len(owner.Tickets))
+	}
+
+	if count := DB.Model(&owner).Association("Tickets").Count(); count != 3 {
+		t.Errorf("invalid tickets count, expects: %v got %v", 3, count)
+	}
+
+	// continue on error
+	tickets = []BatchTicket{{Code: "ticket-3"}, {Code: "ticket-existing"}, {Code: "ticket-4"}, {Code: "ticket-5"}}
+	err = DB.Set("gorm:association:continue_on_error", true).Model(&owner).Association("Tickets").AppendInBatches(1, &tickets)
+
+	var batchErrs gorm.BatchErrors
+	if !errors.As(err, &batchErrs) || len(batchErrs) != 1 || batchErrs[0].Index != 1 {
+		t.Fatalf("should return errors of the second batch, but got %v", err)
+	}
+
+	if tickets[0].ID == 0 || tickets[1].ID != 0 || tickets[2].ID == 0 || tickets[3].ID == 0 {
+		t.Errorf("tickets of other batches should be saved, but got %+v", tickets)
+	}
+
+	if count := DB.Model(&owner).Association("Tickets").Count(); count != 6 {
+		t.Errorf("invalid tickets count, expects: %v got %v", 6, count)
+	}
+}
+
+func TestHasManyAssociationAppendWithSelectAndOmit(t *testing.T) {
+	var user = *GetUser("hasmany-append-with-select-omit", Config{})
+
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatalf("errors happened when create: %v", err)
+	}
+
+	user.Name = "hasmany-append-with-select-omit-new"
+	user.Age = 100
+	pet := Pet{Name: "hasmany-append-with-select-omit-pet"}
+	if err := DB.Model(&user).Select("Name", "Age").Omit("Age", "Pets").Association("Pets").Append(&pet); err != nil {
+		t.Fatalf("Error happened when append pet, got %v", err)
+	}
+
+	if pet.ID == 0 {
+		t.Errorf("pet should be appended even if the relation is omitted")
+	}
+
+	var result User
+	DB.First(&result, user.ID)
+	if result.Name != user.Name {
+		t.Errorf("selected owner column should be updated, expects: %v, got %v", user.Name, result.Name)
+	}
+
+	if result.Age == user.Age {
+		t.Errorf("omitted owner column should be untouched, but got %v", result.Age)
+	}
+
+	AssertAssociationCount(t, user, "Pets", 1, "AfterAppendWithSelectAndOmit")
+}
+
+func TestHasManyAssociationSave(t *testing.T) {
+	type SaveOrder struct {
+		ID             uint
+		SaveCustomerID uint
+		Total          float64
+	}
+
+	type SaveCustomer struct {
+		ID     uint
+		Name   string
+		Orders []SaveOrder
+	}
+
+	DB.Migrator().DropTable(&SaveCustomer{}, &SaveOrder{})
+	if err := DB.AutoMigrate(&SaveCustomer{}, &SaveOrder{}); err != nil {
+		t.Fatalf("Failed to migrate, got %v", err)
+	}
+
+	customer := SaveCustomer{Name: "customer", Orders: []SaveOrder{{Total: 10}, {Total: 20}}}
+	other := SaveCustomer{Name: "other", Orders: []SaveOrder{{Total: 30}}}
+	DB.Create(&customer)
+	DB.Create(&other)
+
+	var orders []SaveOrder
+	if err := DB.Model(&customer).Association("Orders").Find(&orders); err != nil || len(orders) != 2 {
+		t.Fatalf("failed to find orders, got %v, error: %v", len(orders), err)
+	}
+
+	orders[0].Total = 15
+	orders[1].Total = 0
+	if err := DB.Model(&customer).Association("Orders").Save(&orders); err != nil {
+		t.Fatalf("failed to save orders, got error %v", err)
+	}
+
+	var saved []SaveOrder
+	DB.Order("id").Find(&saved, "save_customer_id = ?", customer.ID)
+	if len(saved) != 2 || saved[0].Total != 15 || saved[1].Total != 0 {
+		t.Errorf("should save orders' totals, but got %+v", saved)
+	}
+
+	detached := SaveOrder{ID: orders[0].ID, Total: 25}
+	if err := DB.Model(&customer).Association("Orders").Save(&detached); err != nil || detached.SaveCustomerID != customer.ID {
+		t.Errorf("should set blank foreign key to the owner, got %+v, error: %v", detached, err)
+	}
+
+	reparented := orders[0]
+	reparented.SaveCustomerID = other.ID
+	if err := DB.Model(&customer).Association("Orders").Save(&reparented); !errors.Is(err, gorm.ErrInvalidData) {
+		t.Errorf("should refuse to reparent order, but got %v", err)
+	}
+
+	if err := DB.Model(&other).Association("Orders").Save(&orders[0]); !errors.Is(err, gorm.ErrInvalidData) {
+		t.Errorf("should refuse to save order of another customer, but got %v", err)
+	}
+
+	var order SaveOrder
+	DB.First(&order, orders[0].ID)
+	if order.SaveCustomerID != customer.ID || order.Total != 25 {
+		t.Errorf("order shouldn't be reparented, but got %+v", order)
+	}
+
+	if err := DB.Model(&customer).Association("Orders").Save(&SaveOrder{Total: 40}); !errors.Is(err, gorm.ErrPrimaryKeyRequired) {
+		t.Errorf("should require primary key to save order, but got %v", err)
+	}
+}
+
+func TestHasManyAssociationCountEach(t *testing.T) {
+	var users = []User{
+		*GetUser("count-each-1", Config{Pets: 1}),
+		*GetUser("count-each-2", Config{Pets: 3}),
+		*GetUser("count-each-3", Config{}),
+	}
+
+	DB.Create(&users)
+
+	var queries int
+	DB.Callback().Row().After("gorm:row").Register("count_each_queries", func(db *gorm.DB) {
+		queries++
+	})
+	defer DB.Callback().Row().Remove("count_each_queries")
+
+	counts, err := DB.Model(&users).Association("Pets").CountEach()
+	if err != nil {
+		t.Fatalf("failed to count each, got error %v", err)
+	}
+
+	if queries != 1 {
+		t.Errorf("should count with one query, but got %v", queries)
+	}
+
+	if len(counts) != 3 || counts[users[0].ID] != 1 || counts[users[1].ID] != 3 || counts[users[2].ID] != 0 {
+		t.Errorf("invalid counts, got %v", counts)
+	}
+
+	if counts, err := DB.Model(&users).Association("Languages").CountEach(); err != nil || len(counts) != 3 || counts[users[1].ID] != 0 {
+		t.Errorf("invalid many2many counts, got %v, error: %v", counts, err)
+	}
+
+	DB.Model(&users[2]).Association("Languages").Append(&Language{Code: "count-each", Name: "count-each"})
+	if counts, err := DB.Model(&users).Association("Languages").CountEach(); err != nil || counts[users[2].ID] != 1 {
+		t.Errorf("should count many2many associations, got %v, error: %v", counts, err)
+	}
+
+	if counts, err := DB.Model(&users[1]).Association("Pets").CountEach(); err != nil || len(counts) != 1 || counts[users[1].ID] != 3 {
+		t.Errorf("should count associations of single owner, got %v, error: %v", counts, err)
+	}
+}
+
+func TestHasManyAssociationCountWithConditions(t *testing.T) {
+	type CountOrder struct {
+		ID          uint
+		CountUserID uint
+		Status      string
+	}
+
+	type CountUser struct {
+		ID     uint
+		Name   string
+		Orders []CountOrder
+	}
+
+	DB.Migrator().DropTable(&CountOrder{}, &CountUser{})
+	if err := DB.AutoMigrate(&CountUser{}, &CountOrder{}); err != nil {
+		t.Fatalf("failed to migrate, got error %v", err)
+	}
+
+	user := CountUser{Name: "count-conds", Orders: []CountOrder{{Status: "paid"}, {Status: "pending"}, {Status: "paid"}}}
+	DB.Create(&user)
+	DB.Create(&CountOrder{Status: "paid"})
+
+	if count := DB.Model(&user).Association("Orders").Count("status = ?", "paid"); count != 2 {
+		t.Errorf("should count paid orders, expects %v, got %v", 2, count)
+	}
+
+	if count, err := DB.Model(&user).Association("Orders").CountI64(map[string]interface{}{"status": "pending"}); err != nil || count != 1 {
+		t.Errorf("should count pending orders, expects %v, got %v, error: %v", 1, count, err)
+	}
+
+	if count := DB.Model(&user).Association("Orders").Count(); count != 3 {
+		t.Errorf("should count all orders without conditions, expects %v, got %v", 3, count)
+	}
+}
+
+func TestHasManyAssociationFindChan(t *testing.T) {
+	type StreamOrder struct {
+		ID               uint
+		StreamCustomerID uint
+		Total            int
+	}
+
+	type StreamCustomer struct {
+		ID     uint
+		Name   string
+		Orders []StreamOrder
+	}
+
+	DB.Migrator().DropTable(&StreamOrder{}, &StreamCustomer{})
+	if err := DB.AutoMigrate(&StreamCustomer{}, &StreamOrder{}); err != nil {
+		t.Fatalf("failed to migrate, got error %v", err)
+	}
+
+	customer := StreamCustomer{Name: "stream"}
+	orders := make([]StreamOrder, 1000)
+	for i := range orders {
+		orders[i].Total = i
+	}
+	DB.Create(&customer)
+	if err := DB.Session(&gorm.Session{CreateBatchSize: 100}).Model(&customer).Association("Orders").Append(&orders); err != nil {
+		t.Fatalf("failed to append orders, got error %v", err)
+	}
+	DB.Create(&StreamCustomer{Name: "other", Orders: []StreamOrder{{Total: 1000}}})
+
+	values, errs := DB.Model(&customer).Association("Orders").FindChan(context.Background(), 10)
+
+	var total int
+	seen := map[uint]bool{}
+	for value := range values {
+		order, ok := value.(*StreamOrder)
+		if !ok {
+			t.Fatalf("should receive *StreamOrder, got %T", value)
+		}
+
+		if order.StreamCustomerID != customer.ID || seen[order.ID] {
+			t.Errorf("should receive each order of the customer once, got %+v", order)
+		}
+		seen[order.ID] = true
+		total += order.Total
+	}
+
+	if err := <-errs; err != nil {
+		t.Fatalf("failed to stream orders, got error %v", err)
+	}
+
+	if len(seen) != 1000 || total != 999*1000/2 {
+		t.Errorf("should receive %v orders, got %v with total %v", 1000, len(seen), total)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	values, errs = DB.Model(&customer).Association("Orders").FindChan(ctx, 0)
+	<-values
+	cancel()
+
+	var received int
+	for range values {
+		received++
+	}
+
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Errorf("should return context canceled error, got %v", err)
+	}
+
+	if received > 1 {
+		t.Errorf("streaming should stop after canceled, got %v more orders", received)
+	}
+}
+
+func TestHasManyAssociationCountWithLimit(t *testing.T) {
+	var user = *GetUser("hasmany-count-with-limit", Config{Pets: 8})
+	DB.Create(&user)
+
+	if count := DB.Model(&user).Limit(5).Association("Pets").Count(); count != 8 {
+		t.Errorf("limit shouldn't be applied when counting, expects: %v, got %v", 8, count)
+	}
+
+	if count := DB.Model(&user).Limit(5).Offset(10).Association("Pets").Count(); count != 8 {
+		t.Errorf("offset shouldn't be applied when counting, expects: %v, got %v", 8, count)
+	}
+
+	if count, err := DB.Model(&user).Limit(5).Offset(6).Association("Pets").CountUpTo(7); err != nil || count != 7 {
+		t.Errorf("offset shouldn't be applied when counting up to limit, expects: %v, got %v, error %v", 7, count, err)
+	}
+
+	if counts, err := DB.Model(&[]User{user}).Offset(1).Association("Pets").CountEach(); err != nil || counts[user.ID] != 8 {
+		t.Errorf("offset shouldn't be applied when counting each owner, expects: %v, got %v, error %v", 8, counts, err)
+	}
+
+	var pets []Pet
+	if err := DB.Model(&user).Limit(5).Association("Pets").Find(&pets); err != nil || len(pets) != 5 {
+		t.Errorf("limit should be applied when finding, expects: %v, got %v, error %v", 5, len(pets), err)
+	}
+}
+
+func TestHasManyAssociationCountSoftDeleted(t *testing.T) {
+	type SoftDeleteOrder struct {
+		ID                     uint
+		SoftDeleteOrderOwnerID uint
+		Status                 string
+		DeletedAt              gorm.DeletedAt
+	}
+
+	type SoftDeleteOrderOwner struct {
+		ID     uint
+		Name   string
+		Orders []SoftDeleteOrder
+	}
+
+	DB.Migrator().DropTable(&SoftDeleteOrder{}, &SoftDeleteOrderOwner{})
+	if err := DB.AutoMigrate(&SoftDeleteOrderOwner{}, &SoftDeleteOrder{}); err != nil {
+		t.Fatalf("failed to migrate, got error %v", err)
+	}
+
+	owner := SoftDeleteOrderOwner{Name: "soft-deleted-orders", Orders: []SoftDeleteOrder{{Status: "paid"}, {Status: "paid"}, {Status: "pending"}}}
+	DB.Create(&owner)
+	DB.Delete(&owner.Orders[0])
+
+	if count := DB.Model(&owner).Association("Orders").Count(); count != 2 {
+		t.Errorf("soft deleted orders should be excluded from count, expects %v, got %v", 2, count)
+	}
+
+	if count := DB.Model(&owner).Association("Orders").Count("status = ?", "paid"); count != 1 {
+		t.Errorf("soft deleted orders should be excluded from count with conditions, expects %v, got %v", 1, count)
+	}
+
+	if counts, err := DB.Model(&owner).Association("Orders").CountEach(); err != nil || counts[owner.ID] != 2 {
+		t.Errorf("soft deleted orders should be excluded from count each, got %v, error %v", counts, err)
+	}
+
+	var orders []SoftDeleteOrder
+	if DB.Model(&owner).Association("Orders").Find(&orders); len(orders) != 2 {
+		t.Errorf("soft deleted orders should be excluded from find, got %v", len(orders))
+	}
+
+	if count := DB.Model(&owner).Association("Orders").Unscoped().Count(); count != 3 {
+		t.Errorf("soft deleted orders should be counted with unscoped, expects %v, got %v", 3, count)
+	}
+
+	if count := DB.Unscoped().Model(&owner).Association("Orders").Count(); count != 3 {
+		t.Errorf("soft deleted orders should be counted with unscoped db, expects %v, got %v", 3, count)
+	}
+}
+
+func TestHasManyAssociationAppendUnique(t *testing.T) {
+	type UniqueTag struct {
+		ID           uint
+		UniquePostID uint
+		Name         string
+	}
+
+	type UniquePost struct {
+		ID   uint
+		Tags []UniqueTag
+	}
+
+	DB.Migrator().DropTable(&UniqueTag{}, &UniquePost{})
+	if err := DB.AutoMigrate(&UniquePost{}, &UniqueTag{}); err != nil {
+		t.Fatalf("failed to migrate, got error %v", err)
+	}
+
+	post := UniquePost{Tags: []UniqueTag{{Name: "go"}, {Name: "orm"}}}
+	otherPost := UniquePost{Tags: []UniqueTag{{Name: "sql"}}}
+	DB.Create(&post)
+	DB.Create(&otherPost)
+
+	var queries int
+	DB.Callback().Query().After("gorm:query").Register("test:append_unique_queries", func(db *gorm.DB) {
+		queries++
+	})
+
+	err := DB.Model(&post).Association("Tags").AppendUnique([]string{"Name"}, &UniqueTag{Name: "go"}, []UniqueTag{{Name: "sql"}, {Name: "db"}, {Name: "sql"}})
+	DB.Callback().Query().Remove("test:append_unique_queries")
+	if err != nil {
+		t.Fatalf("failed to append unique tags, got error %v", err)
+	}
+
+	if queries != 1 {
+		t.Errorf("should look up existing tags with one query, but got %v", queries)
+	}
+
+	var names []string
+	DB.Model(&UniqueTag{}).Where("unique_post_id = ?", post.ID).Order("id").Pluck("name", &names)
+	if strings.Join(names, ",") != "go,orm,sql,db" {
+		t.Errorf("should append tags without duplicates, got %v", names)
+	}
+
+	if len(post.Tags) != 4 {
+		t.Errorf("appended tags should be assigned to the post, got %+v", post.Tags)
+	}
+
+	if err := DB.Model(&post).Association("Tags").AppendUnique([]string{"name"}, &UniqueTag{Name: "orm"}); err != nil || len(post.Tags) != 4 {
+		t.Errorf("existing tags should be skipped, got %+v, error %v", post.Tags, err)
+	}
+
+	if err := DB.Model(&post).Association("Tags").AppendUnique([]string{"invalid"}, &UniqueTag{Name: "orm"}); !errors.Is(err, gorm.ErrInvalidField) {
+		t.Errorf("should return error for invalid columns, got %v", err)
+	}
+
+	if err := DB.Model(&User{}).Association("Languages").AppendUnique([]string{"name"}, &Language{}); !errors.Is(err, gorm.ErrUnsupportedRelation) {
+		t.Errorf("should return error for many2many relations, got %v", err)
+	}
+}
+
+func TestHasManyAssociationFindInBatches(t *testing.T) {
+	type BatchItem struct {
+		ID          uint
+		BatchListID uint
+		Position    int
+	}
+
+	type BatchList struct {
+		ID    uint
+		Items []BatchItem
+	}
+
+	DB.Migrator().DropTable(&BatchItem{}, &BatchList{})
+	if err := DB.AutoMigrate(&BatchList{}, &BatchItem{}); err != nil {
+		t.Fatalf("failed to migrate, got error %v", err)
+	}
+
+	list, otherList := BatchList{}, BatchList{}
+	DB.Create(&list)
+	DB.Create(&otherList)
+
+	items := make([]BatchItem, 10000)
+	for idx := range items {
+		items[idx] = BatchItem{BatchListID: list.ID, Position: idx}
+		if idx%10 == 0 {
+			items[idx].BatchListID = otherList.ID
+		}
+	}
+	if err := DB.CreateInBatches(&items, 400).Error; err != nil {
+		t.Fatalf("failed to create items, got error %v", err)
+	}
+
+	var (
+		results   []BatchItem
+		batches   int
+		processed int
+		lastID    uint
+	)
+
+	err := DB.Model(&list).Association("Items").FindInBatches(&results, 500, func(tx *gorm.DB, batch int) error {
+		batches = batch
+		for _, item := range results {
+			if item.BatchListID != list.ID || item.ID <= lastID {
+				t.Fatalf("invalid item %+v in batch %v, last id %v", item, batch, lastID)
+			}
+			lastID = item.ID
+		}
+		processed += len(results)
+		return nil
+	})
+
+	if err != nil || processed != 9000 || batches != 18 {
+		t.Errorf("should process 9000 items in 18 batches, got %v items in %v batches, error: %v", processed, batches, err)
+	}
+
+	errStop := errors.New("stop")
+	batches = 0
+	err = DB.Model(&list).Association("Items").FindInBatches(&results, 500, func(tx *gorm.DB, batch int) error {
+		batches++
+		return errStop
+	})
+
+	if !errors.Is(err, errStop) || batches != 1 {
+		t.Errorf("should stop with error of callback, got %v after %v batches", err, batches)
+	}
+}
+
+func TestHasManyAssociationForEmbeddedPointerStruct(t *testing.T) {
+	type EmbeddedComment struct {
+		ID                uint
+		EmbeddedArticleID uint
+		Body              string
+	}
+
+	type EmbeddedArticleBase struct {
+		Comments []EmbeddedComment
+	}
+
+	type EmbeddedArticle struct {
+		ID    uint
+		Title string
+		*EmbeddedArticleBase
+	}
+
+	DB.Migrator().DropTable(&EmbeddedArticle{}, &EmbeddedComment{})
+	if err := DB.AutoMigrate(&EmbeddedArticle{}, &EmbeddedComment{}); err != nil {
+		t.Fatalf("Failed to migrate, got %v", err)
+	}
+
+	article := EmbeddedArticle{Title: "embedded"}
+	other := EmbeddedArticle{Title: "other"}
+	DB.Create(&article)
+	DB.Create(&other)
+
+	if err := DB.Model(&article).Association("Comments").Append(&EmbeddedComment{Body: "first"}, &EmbeddedComment{Body: "second"}); err != nil {
+		t.Fatalf("failed to append embedded association, got error %v", err)
+	}
+
+	if article.EmbeddedArticleBase == nil || len(article.Comments) != 2 {
+		t.Errorf("should assign comments to embedded struct, but got %+v", article.EmbeddedArticleBase)
+	}
+
+	var comments []EmbeddedComment
+	if err := DB.Model(&article).Association("Comments").Find(&comments); err != nil || len(comments) != 2 {
+		t.Errorf("should find embedded associations, got %v, error: %v", len(comments), err)
+	}
+
+	if count := DB.Model(&other).Association("Comments").Count(); count != 0 {
+		t.Errorf("should not find associations of other article, but got %v", count)
+	}
+
+	if err := DB.Model(&article).Association("Comments").Replace(&EmbeddedComment{Body: "third"}); err != nil || len(article.Comments) != 1 {
+		t.Errorf("failed to replace embedded associations, got %+v, error: %v", article.Comments, err)
+	}
+
+	if count := DB.Model(&article).Association("Comments").Count(); count != 1 {
+		t.Errorf("should replace embedded associations, but got %v", count)
+	}
+}
+
+func TestHasManyAssociationFirst(t *testing.T) {
+	type FirstOrder struct {
+		ID           uint
+		FirstBuyerID uint
+		Total        float64
+		CreatedAt    time.Time
+	}
+
+	type FirstBuyer struct {
+		ID     uint
+		Name   string
+		Orders []FirstOrder
+	}
+
+	DB.Migrator().DropTable(&FirstBuyer{}, &FirstOrder{})
+	if err := DB.AutoMigrate(&FirstBuyer{}, &FirstOrder{}); err != nil {
+		t.Fatalf("Failed to migrate, got %v", err)
+	}
+
+	now := time.Now()
+	buyer := FirstBuyer{Name: "buyer", Orders: []FirstOrder{
+		{Total: 10, CreatedAt: now.Add(-2 * time.Hour)},
+		{Total: 30, CreatedAt: now},
+		{Total: 20, CreatedAt: now.Add(-time.Hour)},
+	}}
+	DB.Create(&buyer)
+
+	var newest FirstOrder
+	if err := DB.Model(&buyer).Order("created_at desc").Association("Orders").First(&newest); err != nil {
+		t.Fatalf("failed to find newest order, got error %v", err)
+	}
+
+	if newest.Total != 30 {
+		t.Errorf("should find newest order, but got %+v", newest)
+	}
+
+	var first FirstOrder
+	if err := DB.Model(&buyer).Association("Orders").First(&first, "total > ?", 10); err != nil || first.Total != 30 {
+		t.Errorf("should find first order by primary key with conditions, got %+v, error: %v", first, err)
+	}
+
+	var missing FirstOrder
+	if err := DB.Model(&buyer).Association("Orders").First(&missing, "total > ?", 100); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("should return record not found, but got %v", err)
+	}
+
+	var language Language
+	var user = *GetUser("first-language", Config{Languages: 2})
+	DB.Create(&user)
+	expected := user.Languages[0]
+	if user.Languages[1].Name > expected.Name {
+		expected = user.Languages[1]
+	}
+
+	if err := DB.Model(&user).Order("name desc").Association("Languages").First(&language); err != nil || language.Code != expected.Code {
+		t.Errorf("should find first many2many association %v, got %+v, error: %v", expected.Code, language, err)
+	}
+}
+
+func TestHasManyAssociationDeleteWhere(t *testing.T) {
+	type DeleteWhereOrder struct {
+		ID                 uint
+		DeleteWhereBuyerID *uint
+		Status             string
+	}
+
+	type DeleteWhereBuyer struct {
+		ID     uint
+		Name   string
+		Orders []DeleteWhereOrder
+	}
+
+	DB.Migrator().DropTable(&DeleteWhereBuyer{}, &DeleteWhereOrder{})
+	if err := DB.AutoMigrate(&DeleteWhereBuyer{}, &DeleteWhereOrder{}); err != nil {
+		t.Fatalf("Failed to migrate, got %v", err)
+	}
+
+	buyer := DeleteWhereBuyer{Name: "buyer", Orders: []DeleteWhereOrder{{Status: "cancelled"}, {Status: "paid"}, {Status: "cancelled"}}}
+	other := DeleteWhereBuyer{Name: "other", Orders: []DeleteWhereOrder{{Status: "cancelled"}}}
+	DB.Create(&buyer)
+	DB.Create(&other)
+
+	rowsAffected, err := DB.Model(&buyer).Association("Orders").DeleteWhere("status = ?", "cancelled")
+	if err != nil || rowsAffected != 2 {
+		t.Fatalf("should detach cancelled orders, got %v, error: %v", rowsAffected, err)
+	}
+
+	if len(buyer.Orders) != 1 || buyer.Orders[0].Status != "paid" {
+		t.Errorf("should remove detached orders from owner, but got %+v", buyer.Orders)
+	}
+
+	if count := DB.Model(&buyer).Association("Orders").Count(); count != 1 {
+		t.Errorf("should keep paid order, but got %v", count)
+	}
+
+	var detached int64
+	DB.Model(&DeleteWhereOrder{}).Where("delete_where_buyer_id IS NULL AND status = ?", "cancelled").Count(&detached)
+	if detached != 2 {
+		t.Errorf("should null foreign keys of detached orders, but got %v", detached)
+	}
+
+	if count := DB.Model(&other).Association("Orders").Count(); count != 1 {
+		t.Errorf("should not detach orders of other buyer, but got %v", count)
+	}
+
+	if rowsAffected, err := DB.Model(&buyer).Association("Orders").DeleteWhere("status = ?", "refunded"); err != nil || rowsAffected != 0 {
+		t.Errorf("should detach nothing without matched orders, got %v, error: %v", rowsAffected, err)
+	}
+
+	var user = *GetUser("delete-where-languages", Config{Languages: 3})
+	DB.Create(&user)
+	if rowsAffected, err := DB.Model(&user).Association("Languages").DeleteWhere("name <> ?", user.Languages[0].Name); err != nil || rowsAffected != 2 {
+		t.Errorf("should delete join records of matched languages, got %v, error: %v", rowsAffected, err)
+	}
+	AssertAssociationCount(t, user, "Languages", 1, "after delete where")
+}
+
+func TestHasManyAssociationAppendPointerAndValueElements(t *testing.T) {
+	tests := []struct {
+		name   string
+		column string
+		values func(name string) interface{}
+	}{
+		{"pointers to pointer field", "Pets", func(name string) interface{} { return []*Pet{{Name: name}, {Name: name}} }},
+		{"values to pointer field", "Pets", func(name string) interface{} { return []Pet{{Name: name}, {Name: name}} }},
+		{"values to value field", "Toys", func(name string) interface{} { return []Toy{{Name: name}, {Name: name}} }},
+		{"pointers to value field", "Toys", func(name string) interface{} { return []*Toy{{Name: name}, {Name: name}} }},
+		{"value struct to pointer field", "Pets", func(name string) interface{} { return Pet{Name: name} }},
+		{"value struct to value field", "Toys", func(name string) interface{} { return Toy{Name: name} }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var user = *GetUser("append-elements-"+tt.column, Config{})
+			DB.Create(&user)
+
+			values := tt.values(tt.name)
+			if err := DB.Model(&user).Association(tt.column).Append(values); err != nil {
+				t.Fatalf("failed to append %T, got error %v", values, err)
+			}
+
+			expected := 1
+			if rv := reflect.ValueOf(values); rv.Kind() == reflect.Slice {
+				expected = rv.Len()
+				for i := 0; i < rv.Len(); i++ {
+					if id := reflect.Indirect(rv.Index(i)).FieldByName("ID").Uint(); id == 0 {
+						t.Errorf("should assign primary key back to appended value %v", i)
+					}
+				}
+			}
+
+			if length := len(user.Pets) + len(user.Toys); length != expected {
+				t.Errorf("should assign appended values to owner's field, expects %v, but got %v", expected, length)
+			}
+
+			AssertAssociationCount(t, user, tt.column, int64(expected), "after append")
+		})
+	}
+}
+
+func TestHasManyAssociationCascadeDelete(t *testing.T) {
+	type CascadeOrderItem struct {
+		ID             uint
+		CascadeOrderID uint
+		Product        string
+	}
+
+	type CascadeTag struct {
+		ID   uint
+		Name string
+	}
+
+	type CascadeOrder struct {
+		ID            uint
+		CascadeUserID uint
+		Items         []CascadeOrderItem
+		Tags          []CascadeTag `gorm:"many2many:cascade_order_tags"`
+		ParentID      *uint
+		Refunds       []CascadeOrder `gorm:"foreignKey:ParentID"`
+	}
+
+	type CascadeUser struct {
+		ID     uint
+		Name   string
+		Orders []CascadeOrder
+	}
+
+	// refunds are made cyclic below, which couldn't be deleted with foreign key constraints
+	DB.Config.DisableForeignKeyConstraintWhenMigrating = true
+	defer func() { DB.Config.DisableForeignKeyConstraintWhenMigrating = false }()
+
+	DB.Migrator().DropTable(&CascadeOrderItem{}, &CascadeTag{}, "cascade_order_tags", &CascadeOrder{}, &CascadeUser{})
+	if err := DB.AutoMigrate(&CascadeUser{}, &CascadeOrder{}, &CascadeOrderItem{}, &CascadeTag{}); err != nil {
+		t.Fatalf("failed to migrate, got error %v", err)
+	}
+
+	user := CascadeUser{Name: "cascade", Orders: []CascadeOrder{
+		{Items: []CascadeOrderItem{{Product: "a"}, {Product: "b"}}, Tags: []CascadeTag{{Name: "gift"}}, Refunds: []CascadeOrder{{Items: []CascadeOrderItem{{Product: "c"}}}}},
+		{Items: []CascadeOrderItem{{Product: "d"}}},
+	}}
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatalf("failed to create user, got error %v", err)
+	}
+
+	// refunds refer to their orders, make the first order a refund of its own refund to check cycles are broken
+	refund := user.Orders[0].Refunds[0]
+	DB.Model(&CascadeOrder{}).Where("id = ?", user.Orders[0].ID).Update("parent_id", refund.ID)
+
+	if _, err := DB.Model(&user.Orders[0]).Association("Tags").Cascade().DeleteWithResult(); !errors.Is(err, gorm.ErrUnsupportedRelation) {
+		t.Errorf("should return unsupported relation error for many2many cascade, got %v", err)
+	}
+
+	rowsAffected, err := DB.Model(&user).Association("Orders").Cascade().DeleteWithResult(&user.Orders[0])
+	if err != nil {
+		t.Fatalf("failed to cascade delete orders, got error %v", err)
+	}
+
+	if rowsAffected != 1 {
+		t.Errorf("rows affected should be 1, got %v", rowsAffected)
+	}
+
+	var orders, items, orderTags, tags int64
+	DB.Model(&CascadeOrder{}).Count(&orders)
+	DB.Model(&CascadeOrderItem{}).Count(&items)
+	DB.Table("cascade_order_tags").Count(&orderTags)
+	DB.Model(&CascadeTag{}).Count(&tags)
+	if orders != 1 || items != 1 || orderTags != 0 || tags != 1 {
+		t.Errorf("nested records should be deleted, got %v orders, %v items, %v order tags, %v tags", orders, items, orderTags, tags)
+	}
+
+	if len(user.Orders) != 1 || user.Orders[0].Items[0].Product != "d" {
+		t.Errorf("deleted orders should be removed from the owner, got %+v", user.Orders)
+	}
+
+	if _, err := DB.Model(&user).Association("Orders").DeleteWithResult(&user.Orders[0]); err != nil {
+		t.Fatalf("failed to delete orders, got error %v", err)
+	}
+
+	DB.Model(&CascadeOrderItem{}).Count(&items)
+	if items != 1 {
+		t.Errorf("nested records should be kept without cascade, got %v items", items)
+	}
+}
+
+func TestHasManyAssociationReload(t *testing.T) {
+	if DB.Dialector.Name() != "sqlite" {
+		t.Skip("triggers are created with sqlite syntax")
+	}
+
+	type ReloadPet struct {
+		ID           uint
+		ReloadUserID uint
+		Name         string
+		Status       string
+	}
+
+	type ReloadProfile struct {
+		ID           uint
+		ReloadUserID uint
+		Bio          string
+	}
+
+	type ReloadUser struct {
+		ID      uint
+		Name    string
+		Pets    []*ReloadPet
+		Profile *ReloadProfile
+	}
+
+	DB.Migrator().DropTable(&ReloadPet{}, &ReloadProfile{}, &ReloadUser{})
+	if err := DB.AutoMigrate(&ReloadUser{}, &ReloadPet{}, &ReloadProfile{}); err != nil {
+		t.Fatalf("failed to migrate, got error %v", err)
+	}
+
+	if err := DB.Exec("CREATE TRIGGER reload_pets_status AFTER INSERT ON reload_pets BEGIN UPDATE reload_pets SET status = 'registered' WHERE id = NEW.id; END").Error; err != nil {
+		t.Fatalf("failed to create trigger, got error %v", err)
+	}
+
+	users := []ReloadUser{{Name: "reload-1", Profile: &ReloadProfile{Bio: "bio"}}, {Name: "reload-2"}}
+	DB.Create(&users)
+
+	if err := DB.Model(&users[0]).Association("Pets").Append(&ReloadPet{Name: "pet-1"}, &ReloadPet{Name: "pet-2"}); err != nil {
+		t.Fatalf("failed to append pets, got error %v", err)
+	}
+	DB.Create(&ReloadPet{ReloadUserID: users[1].ID, Name: "pet-3"})
+
+	if users[0].Pets[0].Status != "" {
+		t.Fatalf("status assigned by trigger shouldn't be loaded before reloading, got %v", users[0].Pets[0].Status)
+	}
+
+	if err := DB.Model(&users[0]).Association("Pets").Reload(); err != nil {
+		t.Fatalf("failed to reload pets, got error %v", err)
+	}
+
+	if len(users[0].Pets) != 2 || users[0].Pets[0].Status != "registered" || users[0].Pets[1].Status != "registered" {
+		t.Errorf("pets should be reloaded with status assigned by trigger, got %+v", users[0].Pets)
+	}
+
+	if err := DB.Model(&users).Association("Pets").Reload(); err != nil {
+		t.Fatalf("failed to reload pets of users, got error %v", err)
+	}
+
+	if len(users[0].Pets) != 2 || len(users[1].Pets) != 1 || users[1].Pets[0].Name != "pet-3" {
+		t.Errorf("pets of each user should be reloaded, got %+v, %+v", users[0].Pets, users[1].Pets)
+	}
+
+	DB.Model(&ReloadProfile{}).Where("id = ?", users[0].Profile.ID).Update("bio", "updated")
+	if err := DB.Model(&users[0]).Association("Profile").Reload(); err != nil {
+		t.Fatalf("failed to reload profile, got error %v", err)
+	}
+
+	if users[0].Profile == nil || users[0].Profile.Bio != "updated" {
+		t.Errorf("profile should be reloaded, got %+v", users[0].Profile)
+	}
+
+	DB.Delete(users[0].Profile)
+	if err := DB.Model(&users[0]).Association("Profile").Reload(); err != nil {
+		t.Fatalf("failed to reload deleted profile, got error %v", err)
+	}
+
+	if users[0].Profile != nil {
+		t.Errorf("deleted profile should be cleared, got %+v", users[0].Profile)
+	}
+
+	if err := DB.Model(users[0]).Association("Pets").Reload(); !errors.Is(err, gorm.ErrInvalidData) {
+		t.Errorf("should return error for non pointer owner, got %v", err)
+	}
+}
+
+func TestHasManyAssociationAppendByID(t *testing.T) {
+	var user = *GetUser("hasmany-append-by-id", Config{})
+	DB.Create(&user)
+
+	pets := []Pet{{Name: "append-by-id-1"}, {Name: "append-by-id-2"}, {Name: "append-by-id-3"}}
+	DB.Create(&pets)
+
+	if err := DB.Model(&user).Association("Pets").AppendByID(pets[0].ID, []uint{pets[1].ID}); err != nil {
+		t.Fatalf("failed to append pets by id, got error %v", err)
+	}
+
+	AssertAssociationCount(t, user, "Pets", 2, "after append by id")
+
+	var pet Pet
+	DB.First(&pet, pets[1].ID)
+	if pet.UserID == nil || *pet.UserID != user.ID {
+		t.Errorf("pet should be attached to the user, got %+v", pet)
+	}
+
+	tx := DB.Set("gorm:association:validate_ids", true)
+	if err := tx.Model(&user).Association("Pets").AppendByID(pets[2].ID, uint(999999)); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("should return ErrRecordNotFound with missing ids, got %v", err)
+	}
+
+	if err := tx.Session(&gorm.Session{}).Model(&user).Association("Pets").AppendByID(pets[2].ID); err != nil {
+		t.Fatalf("failed to append existing pets by id, got error %v", err)
+	}
+
+	AssertAssociationCount(t, user, "Pets", 3, "after append existing ids")
+
+	// polymorphic type is assigned with the foreign key
+	toy := Toy{Name: "append-by-id-toy"}
+	DB.Create(&toy)
+	if err := DB.Model(&user).Association("Toys").AppendByID(toy.ID); err != nil {
+		t.Fatalf("failed to append toys by id, got error %v", err)
+	}
+
+	AssertAssociationCount(t, user, "Toys", 1, "after append by id")
+}
+
+func TestHasManyAssociationAppendMaps(t *testing.T) {
+	user := *GetUser("has-many-append-maps", Config{Pets: 1})
+	DB.Create(&user)
+
+	if err := DB.Model(&user).Association("Pets").Append(
+		map[string]interface{}{"Name": "has-many-append-maps-pet-1"},
+		[]map[string]interface{}{{"name": "has-many-append-maps-pet-2"}, {"Name": "has-many-append-maps-pet-3"}},
+		&Pet{Name: "has-many-append-maps-pet-4"},
+	); err != nil {
+		t.Fatalf("failed to append maps, got error %v", err)
+	}
+
+	if len(user.Pets) != 5 || user.Pets[1].Name != "has-many-append-maps-pet-1" || user.Pets[1].ID == 0 {
+		t.Errorf("pets created from maps should be appended to the owner, got %+v", user.Pets)
+	}
+
+	AssertAssociationCount(t, user, "Pets", 5, "after appending maps")
+
+	var pets []Pet
+	DB.Where("name LIKE ?", "has-many-append-maps-pet-%").Order("name").Find(&pets)
+	if len(pets) != 4 {
+		t.Fatalf("should create %v pets, got %v", 4, len(pets))
+	}
+
+	for _, pet := range pets {
+		if pet.UserID == nil || *pet.UserID != user.ID {
+			t.Errorf("pet's foreign key should be set to the owner, got %+v", pet)
+		}
+	}
+
+	if err := DB.Model(&user).Association("Pets").Append(map[string]interface{}{"Nickname": "unknown"}); !errors.Is(err, gorm.ErrInvalidField) {
+		t.Errorf("appending map with unknown column should return ErrInvalidField, got %v", err)
+	}
+
+	AssertAssociationCount(t, user, "Pets", 5, "after appending invalid map")
+}
+
+func TestHasManyAssociationReplaceMaxDetach(t *testing.T) {
+	user := *GetUser("has-many-replace-max-detach", Config{Pets: 3})
+	DB.Create(&user)
+	pets := user.Pets
+
+	if err := DB.Model(&user).Association("Pets").MaxDetach(0).Replace(); !errors.Is(err, gorm.ErrTooManyDetached) {
+		t.Fatalf("replacing with an empty set should return ErrTooManyDetached, got %v", err)
+	}
+
+	if len(user.Pets) != 3 {
+		t.Errorf("owner's field shouldn't be changed, got %v", user.Pets)
+	}
+
+	AssertAssociationCount(t, user, "Pets", 3, "after replacing with too many detached")
+
+	if err := DB.Model(&user).Association("Pets").MaxDetach(1).Replace(pets[0], pets[1], &Pet{Name: "has-many-replace-max-detach-new"}); err != nil {
+		t.Fatalf("detaching one pet should be allowed, got error %v", err)
+	}
+
+	AssertAssociationCount(t, user, "Pets", 3, "after replacing with one detached")
+
+	if err := DB.Model(&user).Association("Pets").MaxDetach(1).Replace(pets[0]); !errors.Is(err, gorm.ErrTooManyDetached) || !strings.Contains(err.Error(), "detach 2") {
+		t.Fatalf("detaching two pets should return ErrTooManyDetached, got %v", err)
+	}
+
+	if err := DB.Model(&user).Association("Pets").MaxDetach(2).Replace(pets[0]); err != nil {
+		t.Fatalf("detaching two pets should be allowed, got error %v", err)
+	}
+
+	AssertAssociationCount(t, user, "Pets", 1, "after replacing with two detached")
+}
+
+type UnregisteredPet struct {
+	ID     uint
+	UserID *uint
+	Name   string
+}
+
+func TestHasManyAssociationAppendWrongType(t *testing.T) {
+	user := *GetUser("has-many-append-wrong-type", Config{Pets: 1})
+	DB.Create(&user)
+
+	for _, value := range []interface{}{&UnregisteredPet{Name: "unregistered"}, []UnregisteredPet{{Name: "unregistered"}}, &Toy{Name: "toy"}} {
+		var err error
+		if writes := countWrites(func() {
+			err = DB.Model(&user).Association("Pets").Append(value)
+		}); writes != 0 {
+			t.Errorf("appending %T shouldn't touch the database, got %v writes", value, writes)
+		}
+
+		if !errors.Is(err, gorm.ErrInvalidData) {
+			t.Fatalf("appending %T should return ErrInvalidData, got %v", value, err)
+		}
+
+		if expects, got := reflect.TypeOf(Pet{}).String(), reflect.TypeOf(value).String(); !strings.Contains(err.Error(), expects) || !strings.Contains(err.Error(), got) {
+			t.Errorf("error should list expected type %v and got type %v, got %v", expects, got, err)
+		}
+	}
+
+	if len(user.Pets) != 1 {
+		t.Errorf("owner's field shouldn't be changed, got %v", user.Pets)
+	}
+
+	AssertAssociationCount(t, user, "Pets", 1, "after appending wrong types")
+}
+
+func TestHasManyAssociationDeleteAll(t *testing.T) {
+	users := []User{*GetUser("has-many-delete-all-1", Config{Pets: 2, Toys: 1}), *GetUser("has-many-delete-all-2", Config{Pets: 1})}
+	DB.Create(&users)
+
+	rowsAffected, err := DB.Model(&users).Association("Pets").DeleteAll()
+	if err != nil {
+		t.Fatalf("failed to delete all pets, got error %v", err)
+	}
+
+	if rowsAffected != 3 {
+		t.Errorf("rows affected should be %v, got %v", 3, rowsAffected)
+	}
+
+	if len(users[0].Pets) != 2 || len(users[1].Pets) != 1 {
+		t.Errorf("owners shouldn't be changed in memory, got %v, %v", users[0].Pets, users[1].Pets)
+	}
+
+	AssertAssociationCount(t, users, "Pets", 0, "after delete all")
+
+	var count int64
+	DB.Model(&Pet{}).Where("name LIKE ? AND user_id IS NULL", "has-many-delete-all-%").Count(&count)
+	if count != 3 {
+		t.Errorf("pets should be kept with null foreign keys, expects %v, got %v", 3, count)
+	}
+
+	if rowsAffected, err = DB.Model(&users[0]).Association("Toys").DeleteAll(); err != nil || rowsAffected != 1 {
+		t.Fatalf("failed to delete all toys, expects %v rows, got %v, %v", 1, rowsAffected, err)
+	}
+
+	DB.Model(&Toy{}).Where("id = ? AND owner_id IS NULL AND owner_type IS NULL", users[0].Toys[0].ID).Count(&count)
+	if count != 1 {
+		t.Errorf("toy should be kept with null polymorphic foreign key and type, got %v", count)
+	}
+
+	unsaved := *GetUser("has-many-delete-all-unsaved", Config{Pets: 2})
+	if rowsAffected, err := DB.Model(&unsaved).Association("Pets").DeleteAll(); err != nil || rowsAffected != 0 {
+		t.Errorf("unsaved owner shouldn't affect any rows, got %v, %v", rowsAffected, err)
+	}
+}
+
+func TestHasManyAssociationClear(t *testing.T) {
+	users := []User{*GetUser("has-many-clear-1", Config{Pets: 2}), *GetUser("has-many-clear-2", Config{Pets: 1})}
+	DB.Create(&users)
+
+	if err := DB.Model(&users).Association("Pets").Clear(); err != nil {
+		t.Fatalf("failed to clear pets, got error %v", err)
+	}
+
+	for _, user := range users {
+		if user.Pets != nil {
+			t.Errorf("owner's field should be zeroed, got %v", user.Pets)
+		}
+	}
+
+	AssertAssociationCount(t, users, "Pets", 0, "after clear")
+
+	var count int64
+	DB.Model(&Pet{}).Where("name LIKE ? AND user_id IS NULL", "has-many-clear-%").Count(&count)
+	if count != 3 {
+		t.Errorf("pets should be kept with null foreign keys, expects %v, got %v", 3, count)
+	}
+
+	unsaved := *GetUser("has-many-clear-unsaved", Config{Pets: 2})
+	if writes := countWrites(func() {
+		if err := DB.Model(&unsaved).Association("Pets").Clear(); err != nil {
+			t.Errorf("failed to clear pets of unsaved user, got error %v", err)
+		}
+	}); writes != 0 {
+		t.Errorf("clearing unsaved owner shouldn't touch the database, got %v writes", writes)
+	}
+
+	if unsaved.Pets != nil {
+		t.Errorf("owner's field should be zeroed, got %v", unsaved.Pets)
+	}
+}
+
+type placeholderLimitDialector struct {
+	gorm.Dialector
+	limit int
+}
+
+func (dialector placeholderLimitDialector) MaxPlaceholders() int {
+	return dialector.limit
+}
+
+func (dialector placeholderLimitDialector) SavePoint(tx *gorm.DB, name string) error {
+	return dialector.Dialector.(gorm.SavePointerDialectorInterface).SavePoint(tx, name)
+}
+
+func (dialector placeholderLimitDialector) RollbackTo(tx *gorm.DB, name string) error {
+	return dialector.Dialector.(gorm.SavePointerDialectorInterface).RollbackTo(tx, name)
+}
+
+func TestHasManyAssociationAppendUnderPlaceholderLimit(t *testing.T) {
+	user := *GetUser("placeholder-limit", Config{})
+	DB.Create(&user)
+
+	var inserts int
+	DB.Callback().Create().After("gorm:create").Register("test:count_pet_inserts", func(db *gorm.DB) {
+		if db.Statement.Table == "pets" {
+			inserts++
+		}
+	})
+	defer DB.Callback().Create().Remove("test:count_pet_inserts")
+
+	// pets have 6 columns, 3 pets are created in a statement with 20 placeholders
+	tx := DB.Session(&gorm.Session{})
+	tx.Dialector = placeholderLimitDialector{Dialector: DB.Dialector, limit: 20}
+
+	var pets []Pet
+	for i := 0; i < 10; i++ {
+		pets = append(pets, Pet{Name: fmt.Sprintf("placeholder-limit-%v", i)})
+	}
+
+	if err := tx.Model(&user).Association("Pets").Append(&pets); err != nil {
+		t.Fatalf("failed to append pets, got error %v", err)
+	}
+
+	if inserts != 4 {
+		t.Errorf("pets should be inserted in 4 statements, got %v", inserts)
+	}
+
+	for _, pet := range pets {
+		if pet.ID == 0 {
+			t.Errorf("pets created in batches should be assigned back, got %+v", pet)
+		}
+	}
+	AssertAssociationCount(t, user, "Pets", 10, "after append under placeholder limit")
+
+	inserts = 0
+	if err := DB.Model(&user).Association("Pets").Append(&Pet{Name: "placeholder-limit-single"}); err != nil || inserts != 1 {
+		t.Errorf("pets under the limit should be inserted in a statement, got %v, error %v", inserts, err)
+	}
+
+	// dialects with unknown placeholder limits aren't limited
+	inserts = 0
+	tx = DB.Session(&gorm.Session{})
+	tx.Dialector = namedDialector{Dialector: DB.Dialector, name: "unknown"}
+
+	pets = nil
+	for i := 0; i < 200; i++ {
+		pets = append(pets, Pet{Name: fmt.Sprintf("placeholder-unknown-%v", i)})
+	}
+
+	if err := tx.Model(&user).Association("Pets").Append(&pets); err != nil || inserts != 1 {
+		t.Errorf("pets should be inserted in a statement without placeholder limit, got %v, error %v", inserts, err)
+	}
+}
+
+type namedDialector struct {
+	gorm.Dialector
+	name string
+}
+
+func (dialector namedDialector) Name() string {
+	return dialector.name
+}
+
+func (dialector namedDialector) SavePoint(tx *gorm.DB, name string) error {
+	return dialector.Dialector.(gorm.SavePointerDialectorInterface).SavePoint(tx, name)
+}
+
+func (dialector namedDialector) RollbackTo(tx *gorm.DB, name string) error {
+	return dialector.Dialector.(gorm.SavePointerDialectorInterface).RollbackTo(tx, name)
+}
+
+func TestHasManyAssociationReplaceDelta(t *testing.T) {
+	if DB.Dialector.Name() != "sqlite" {
+		t.Skip("triggers are created with sqlite syntax")
+	}
+
+	type DeltaPet struct {
+		ID          uint
+		DeltaUserID *uint
+		Name        string
+	}
+
+	type DeltaPetUpdate struct {
+		ID    uint
+		PetID uint
+	}
+
+	type DeltaUser struct {
+		ID   uint
+		Name string
+		Pets []DeltaPet
+	}
+
+	DB.Migrator().DropTable(&DeltaPet{}, &DeltaPetUpdate{}, &DeltaUser{})
+	if err := DB.AutoMigrate(&DeltaUser{}, &DeltaPet{}, &DeltaPetUpdate{}); err != nil {
+		t.Fatalf("failed to migrate, got error %v", err)
+	}
+
+	if err := DB.Exec("CREATE TRIGGER delta_pets_updated AFTER UPDATE ON delta_pets BEGIN INSERT INTO delta_pet_updates (pet_id) VALUES (NEW.id); END").Error; err != nil {
+		t.Fatalf("failed to create trigger, got error %v", err)
+	}
+
+	user := DeltaUser{Name: "delta", Pets: []DeltaPet{{Name: "removed"}, {Name: "kept-1"}, {Name: "kept-2"}}}
+	DB.Create(&user)
+
+	unowned := DeltaPet{Name: "linked"}
+	DB.Create(&unowned)
+	DB.Where("1 = 1").Delete(&DeltaPetUpdate{})
+
+	removed, kept1, kept2 := user.Pets[0], user.Pets[1], user.Pets[2]
+	created := DeltaPet{Name: "created"}
+	if err := DB.Model(&user).Association("Pets").Replace(&kept1, &created, &unowned, &kept2); err != nil {
+		t.Fatalf("failed to replace pets, got error %v", err)
+	}
+
+	var updates []DeltaPetUpdate
+	DB.Order("pet_id").Find(&updates)
+	if len(updates) != 2 || updates[0].PetID != removed.ID || updates[1].PetID != unowned.ID {
+		t.Errorf("only the removed and linked pets should be updated, got %+v", updates)
+	}
+
+	if len(user.Pets) != 4 || user.Pets[0].ID != kept1.ID || user.Pets[1].ID != created.ID || user.Pets[1].ID == 0 ||
+		user.Pets[2].ID != unowned.ID || user.Pets[3].ID != kept2.ID {
+		t.Errorf("owner's pets should be replaced in the order of values, got %+v", user.Pets)
+	}
+
+	for _, pet := range user.Pets {
+		if pet.DeltaUserID == nil || *pet.DeltaUserID != user.ID {
+			t.Errorf("pets' foreign keys should be assigned, got %+v", pet)
+		}
+	}
+
+	var ownedNames []string
+	DB.Model(&DeltaPet{}).Where("delta_user_id = ?", user.ID).Order("name").Pluck("name", &ownedNames)
+	if strings.Join(ownedNames, ",") != "created,kept-1,kept-2,linked" {
+		t.Errorf("pets should be replaced in database, got %v", ownedNames)
+	}
+}
+
+func TestHasManyAssociationWithDeleted(t *testing.T) {
+	user := *GetUser("with-deleted", Config{Pets: 3})
+	DB.Create(&user)
+
+	if err := DB.Delete(user.Pets[0]).Error; err != nil {
+		t.Fatalf("failed to soft delete pet, got error %v", err)
+	}
+
+	var pets []Pet
+	if err := DB.Model(&user).Association("Pets").Find(&pets); err != nil || len(pets) != 2 {
+		t.Errorf("soft deleted pets shouldn't be found, got %v, error %v", len(pets), err)
+	}
+
+	association := DB.Model(&user).Association("Pets").WithDeleted()
+	pets = nil
+	if err := association.Find(&pets); err != nil || len(pets) != 3 {
+		t.Fatalf("soft deleted pets should be found with deleted, got %v, error %v", len(pets), err)
+	}
+
+	var deleted int
+	for _, pet := range pets {
+		if pet.DeletedAt.Valid {
+			deleted++
+			if pet.ID != user.Pets[0].ID {
+				t.Errorf("unexpected soft deleted pet %+v", pet)
+			}
+		}
+	}
+
+	if deleted != 1 {
+		t.Errorf("both active and soft deleted pets should be found, got %v soft deleted", deleted)
+	}
+
+	if count := association.Count(); count != 3 {
+		t.Errorf("soft deleted pets should be counted with deleted, got %v", count)
+	}
+
+	AssertAssociationCount(t, user, "Pets", 2, "soft deleted pets are excluded without WithDeleted")
+}
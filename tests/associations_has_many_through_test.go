@@ -0,0 +1,130 @@
+package tests_test
+
+import (
+	"errors"
+	"sort"
+	"testing"
+
+	"gorm.io/gorm"
+	. "gorm.io/gorm/utils/tests"
+)
+
+type ThroughComment struct {
+	ID            uint
+	ThroughPostID uint
+	Content       string
+}
+
+type ThroughPost struct {
+	gorm.Model
+	ThroughAuthorID uint
+	Title           string
+	Comments        []ThroughComment
+}
+
+type ThroughAuthor struct {
+	ID       uint
+	Name     string
+	Posts    []ThroughPost
+	Comments []ThroughComment `gorm:"through:Posts"`
+}
+
+func prepareThroughAuthors(t *testing.T) []ThroughAuthor {
+	DB.Migrator().DropTable(&ThroughComment{}, &ThroughPost{}, &ThroughAuthor{})
+	if err := DB.AutoMigrate(&ThroughAuthor{}, &ThroughPost{}, &ThroughComment{}); err != nil {
+		t.Fatalf("failed to migrate, got error %v", err)
+	}
+
+	authors := []ThroughAuthor{{
+		Name: "author-1",
+		Posts: []ThroughPost{
+			{Title: "post-1", Comments: []ThroughComment{{Content: "comment-1"}, {Content: "comment-2"}}},
+			{Title: "post-2", Comments: []ThroughComment{{Content: "comment-3"}}},
+		},
+	}, {
+		Name:  "author-2",
+		Posts: []ThroughPost{{Title: "post-3", Comments: []ThroughComment{{Content: "comment-4"}}}},
+	}}
+
+	if err := DB.Create(&authors).Error; err != nil {
+		t.Fatalf("failed to create authors, got error %v", err)
+	}
+	return authors
+}
+
+func throughCommentContents(comments []ThroughComment) []string {
+	contents := make([]string, len(comments))
+	for idx, comment := range comments {
+		contents[idx] = comment.Content
+	}
+	sort.Strings(contents)
+	return contents
+}
+
+func TestHasManyThroughAssociation(t *testing.T) {
+	authors := prepareThroughAuthors(t)
+
+	var comments []ThroughComment
+	if err := DB.Model(&authors[0]).Association("Comments").Find(&comments); err != nil {
+		t.Fatalf("failed to find comments, got error %v", err)
+	}
+	AssertEqual(t, throughCommentContents(comments), []string{"comment-1", "comment-2", "comment-3"})
+
+	if count := DB.Model(&authors[0]).Association("Comments").Count(); count != 3 {
+		t.Errorf("invalid comments count, expects %v, got %v", 3, count)
+	}
+
+	comments = nil
+	if err := DB.Model(&authors[0]).Association("Comments").Find(&comments, "content <> ?", "comment-1"); err != nil {
+		t.Fatalf("failed to find comments with conditions, got error %v", err)
+	}
+	AssertEqual(t, throughCommentContents(comments), []string{"comment-2", "comment-3"})
+
+	comments = nil
+	if err := DB.Model(&authors).Association("Comments").Find(&comments); err != nil {
+		t.Fatalf("failed to find comments of authors, got error %v", err)
+	}
+	AssertEqual(t, throughCommentContents(comments), []string{"comment-1", "comment-2", "comment-3", "comment-4"})
+
+	// comments of soft deleted posts are excluded
+	DB.Delete(&authors[0].Posts[1])
+	if count := DB.Model(&authors[0]).Association("Comments").Count(); count != 2 {
+		t.Errorf("comments of soft deleted posts should be excluded, expects %v, got %v", 2, count)
+	}
+
+	var author ThroughAuthor
+	if err := DB.Preload("Comments").First(&author, authors[1].ID).Error; err != nil {
+		t.Fatalf("failed to preload comments, got error %v", err)
+	}
+	AssertEqual(t, throughCommentContents(author.Comments), []string{"comment-4"})
+}
+
+func TestHasManyThroughAssociationReadOnly(t *testing.T) {
+	authors := prepareThroughAuthors(t)
+
+	if err := DB.Model(&authors[1]).Association("Comments").Append(&ThroughComment{Content: "comment-5"}); !errors.Is(err, gorm.ErrUnsupportedRelation) {
+		t.Errorf("appending has many through associations should return ErrUnsupportedRelation, got %v", err)
+	}
+
+	if err := DB.Model(&authors[1]).Association("Comments").Replace(&ThroughComment{Content: "comment-5"}); !errors.Is(err, gorm.ErrUnsupportedRelation) {
+		t.Errorf("replacing has many through associations should return ErrUnsupportedRelation, got %v", err)
+	}
+
+	if err := DB.Model(&authors[0]).Association("Comments").Delete(&authors[0].Posts[0].Comments[0]); !errors.Is(err, gorm.ErrUnsupportedRelation) {
+		t.Errorf("deleting has many through associations should return ErrUnsupportedRelation, got %v", err)
+	}
+
+	if err := DB.Model(&authors[1]).Association("Comments").Clear(); !errors.Is(err, gorm.ErrUnsupportedRelation) {
+		t.Errorf("clearing has many through associations should return ErrUnsupportedRelation, got %v", err)
+	}
+
+	if err := DB.Model(&authors[1]).JoinAssociation("Comments").Update("content", "comment-5"); !errors.Is(err, gorm.ErrUnsupportedRelation) {
+		t.Errorf("updating join records of has many through associations should return ErrUnsupportedRelation, got %v", err)
+	}
+
+	var count int64
+	DB.Model(&ThroughComment{}).Count(&count)
+	if count != 4 {
+		t.Errorf("comments shouldn't be changed, expects %v, got %v", 4, count)
+	}
+}
@@ -1,8 +1,10 @@
 package tests_test
 
 import (
+	"errors"
 	"testing"
 
+	"gorm.io/gorm"
 	. "gorm.io/gorm/utils/tests"
 )
 
@@ -255,3 +257,188 @@ func TestPolymorphicHasOneAssociationForSlice(t *testing.T) {
 	DB.Model(&pets).Association("Toy").Clear()
 	AssertAssociationCount(t, pets, "Toy", 0, "After Clear")
 }
+
+func TestHasOneAssociationInvalidValues(t *testing.T) {
+	var user = *GetUser("hasone-invalid-values", Config{Account: true})
+
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatalf("errors happened when create: %v", err)
+	}
+
+	accounts := []Account{{Number: "account-invalid-values-1"}, {Number: "account-invalid-values-2"}}
+	if err := DB.Model(&user).Association("Account").Append(&accounts); err == nil {
+		t.Errorf("should return error when appending multiple accounts to has one association")
+	}
+
+	if err := DB.Model(&user).Association("Account").Append(&accounts[0], &accounts[1]); err == nil {
+		t.Errorf("should return error when appending multiple accounts to has one association")
+	}
+
+	pets := []Pet{{Name: "pet-invalid-values"}}
+	if err := DB.Model(&user).Association("Account").Append(&pets); err == nil {
+		t.Errorf("should return error when appending pets to has one association")
+	}
+
+	if accounts[0].ID != 0 || accounts[1].ID != 0 || pets[0].ID != 0 {
+		t.Errorf("invalid values should not be saved")
+	}
+
+	var result User
+	DB.Preload("Account").First(&result, user.ID)
+	if result.Account.ID != user.Account.ID || user.Account.Number != "hasone-invalid-values_account" {
+		t.Errorf("user's account should not be changed")
+	}
+}
+
+func TestHasOneAssociationReplaceNullsPreviousForeignKey(t *testing.T) {
+	type ReplacedProfile struct {
+		ID     uint
+		UserID *uint
+		Bio    string
+	}
+
+	type ReplacedProfileUser struct {
+		ID      uint
+		Name    string
+		Profile ReplacedProfile `gorm:"foreignKey:UserID"`
+	}
+
+	DB.Migrator().DropTable(&ReplacedProfileUser{}, &ReplacedProfile{})
+	if err := DB.AutoMigrate(&ReplacedProfileUser{}, &ReplacedProfile{}); err != nil {
+		t.Fatalf("Failed to migrate, got %v", err)
+	}
+
+	user := ReplacedProfileUser{Name: "replaced-profile", Profile: ReplacedProfile{Bio: "old"}}
+	DB.Create(&user)
+	oldProfile := user.Profile
+
+	if err := DB.Model(&user).Association("Profile").Append(&ReplacedProfile{Bio: "appended"}); err != nil {
+		t.Fatalf("failed to append profile, got error %v", err)
+	}
+
+	var profile ReplacedProfile
+	if DB.First(&profile, oldProfile.ID); profile.UserID != nil {
+		t.Errorf("old profile's user_id should be NULL after appending, but got %v", *profile.UserID)
+	}
+
+	appendedProfile := user.Profile
+	if err := DB.Model(&user).Association("Profile").Replace(&ReplacedProfile{Bio: "replaced"}); err != nil {
+		t.Fatalf("failed to replace profile, got error %v", err)
+	}
+
+	if DB.First(&profile, appendedProfile.ID); profile.UserID != nil {
+		t.Errorf("appended profile's user_id should be NULL after replacing, but got %v", *profile.UserID)
+	}
+
+	var profiles []ReplacedProfile
+	if DB.Find(&profiles, "user_id = ?", user.ID); len(profiles) != 1 || profiles[0].Bio != "replaced" {
+		t.Errorf("user should only have the replaced profile, but got %+v", profiles)
+	}
+
+	// replace with the current profile keeps it
+	if err := DB.Model(&user).Association("Profile").Replace(&user.Profile); err != nil {
+		t.Fatalf("failed to replace profile with itself, got error %v", err)
+	}
+	AssertAssociationCount(t, user, "Profile", 1, "after replacing with itself")
+}
+
+func TestHasOneAssociationAppendStructValue(t *testing.T) {
+	var user = *GetUser("hasone-struct-value", Config{})
+	DB.Create(&user)
+
+	if err := DB.Model(&user).Association("Account").Append(Account{Number: "struct-value"}); err != nil {
+		t.Fatalf("failed to append struct value, got error %v", err)
+	}
+
+	if user.Account.ID == 0 || user.Account.Number != "struct-value" {
+		t.Errorf("should assign appended account to owner, but got %+v", user.Account)
+	}
+	AssertAssociationCount(t, user, "Account", 1, "after append struct value")
+}
+
+func TestHasOneAssociationFindRecordNotFound(t *testing.T) {
+	var user = *GetUser("hasone-record-not-found", Config{})
+	DB.Create(&user)
+
+	var account Account
+	if err := DB.Model(&user).Association("Account").Find(&account); err != nil {
+		t.Errorf("should not return error by default, got %v", err)
+	}
+
+	tx := DB.Set("gorm:association:record_not_found", true).Session(&gorm.Session{})
+	if err := tx.Model(&user).Association("Account").Find(&account); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("should return ErrRecordNotFound, got %v", err)
+	}
+
+	DB.Model(&user).Association("Account").Append(&Account{Number: "record-not-found"})
+	if err := tx.Model(&user).Association("Account").Find(&account); err != nil || account.Number != "record-not-found" {
+		t.Errorf("should find account, got %+v, error %v", account, err)
+	}
+
+	var pets []Pet
+	if err := tx.Model(&user).Association("Pets").Find(&pets); err != nil || len(pets) != 0 {
+		t.Errorf("should not return error for empty collections, got %v, error %v", pets, err)
+	}
+}
+
+func TestHasOneAssociationDeleteAll(t *testing.T) {
+	user := *GetUser("has-one-delete-all", Config{Account: true})
+	DB.Create(&user)
+
+	rowsAffected, err := DB.Model(&user).Association("Account").DeleteAll()
+	if err != nil {
+		t.Fatalf("failed to delete all accounts, got error %v", err)
+	}
+
+	if rowsAffected != 1 {
+		t.Errorf("rows affected should be %v, got %v", 1, rowsAffected)
+	}
+
+	if user.Account.ID == 0 || !user.Account.UserID.Valid {
+		t.Errorf("owner shouldn't be changed in memory, got %+v", user.Account)
+	}
+
+	var result Account
+	if err := DB.First(&result, user.Account.ID).Error; err != nil {
+		t.Fatalf("account should be kept, got error %v", err)
+	}
+
+	if result.UserID.Valid {
+		t.Errorf("account's foreign key should be null, got %v", result.UserID)
+	}
+
+	AssertAssociationCount(t, user, "Account", 0, "after delete all")
+}
+
+func TestHasOneAssociationClear(t *testing.T) {
+	user := *GetUser("has-one-clear", Config{Account: true})
+	DB.Create(&user)
+	account := user.Account
+
+	if err := DB.Model(&user).Association("Account").Clear(); err != nil {
+		t.Fatalf("failed to clear account, got error %v", err)
+	}
+
+	if user.Account.ID != 0 || user.Account.Number != "" {
+		t.Errorf("owner's field should be zeroed, got %+v", user.Account)
+	}
+
+	var result Account
+	DB.First(&result, account.ID)
+	if result.UserID.Valid {
+		t.Errorf("account's foreign key should be null, got %v", result.UserID)
+	}
+
+	unsaved := *GetUser("has-one-clear-unsaved", Config{Account: true})
+	if writes := countWrites(func() {
+		if err := DB.Model(&unsaved).Association("Account").Clear(); err != nil {
+			t.Errorf("failed to clear account of unsaved user, got error %v", err)
+		}
+	}); writes != 0 {
+		t.Errorf("clearing unsaved owner shouldn't touch the database, got %v writes", writes)
+	}
+
+	if unsaved.Account.Number != "" {
+		t.Errorf("owner's field should be zeroed, got %+v", unsaved.Account)
+	}
+}
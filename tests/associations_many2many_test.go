@@ -1,8 +1,17 @@
 package tests_test
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	. "gorm.io/gorm/utils/tests"
 )
 
@@ -319,3 +328,985 @@ func TestSingleTableMany2ManyAssociationForSlice(t *testing.T) {
 	DB.Model(&users).Association("Team").Clear()
 	AssertAssociationCount(t, users, "Team", 0, "After Clear")
 }
+
+func TestMany2ManyAssociationCountI64(t *testing.T) {
+	var user = *GetUser("many2many-count-i64", Config{Languages: 3})
+
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatalf("errors happened when create: %v", err)
+	}
+
+	if count, err := DB.Model(&user).Association("Languages").CountI64(); err != nil || count != 3 {
+		t.Fatalf("invalid languages count, expects: %v got %v, error: %v", 3, count, err)
+	}
+
+	if count := DB.Model(&user).Association("Languages").Count(); count != 3 {
+		t.Fatalf("invalid languages count, expects: %v got %v", 3, count)
+	}
+
+	if _, err := DB.Model(&user).Association("Invalid").CountI64(); err == nil {
+		t.Fatalf("should return error for invalid association")
+	}
+
+	if count := DB.Model(&user).Association("Languages").Count(map[string]interface{}{"code": user.Languages[0].Code}); count != 1 {
+		t.Fatalf("invalid languages count with conditions, expects: %v got %v", 1, count)
+	}
+
+	if count := DB.Model(&user).Association("Languages").Count("name <> ?", user.Languages[0].Name); count != 2 {
+		t.Fatalf("invalid languages count with conditions, expects: %v got %v", 2, count)
+	}
+}
+
+func TestMany2ManyAssociationCountUpTo(t *testing.T) {
+	var user = *GetUser("many2many-count-up-to", Config{})
+	DB.Create(&user)
+
+	languages := make([]Language, 1000)
+	for idx := range languages {
+		languages[idx] = Language{Code: fmt.Sprintf("count-up-to-%04d", idx), Name: "count-up-to"}
+	}
+	if err := DB.CreateInBatches(&languages, 200).Error; err != nil {
+		t.Fatalf("failed to create languages, got error %v", err)
+	}
+	if err := DB.Model(&user).Association("Languages").Append(&languages); err != nil {
+		t.Fatalf("failed to append languages, got error %v", err)
+	}
+
+	if count, err := DB.Model(&user).Association("Languages").CountUpTo(100); err != nil || count != 100 {
+		t.Fatalf("invalid languages count up to 100, expects: %v got %v, error: %v", 100, count, err)
+	}
+
+	if count, err := DB.Model(&user).Association("Languages").CountUpTo(2000); err != nil || count != 1000 {
+		t.Fatalf("invalid languages count up to 2000, expects: %v got %v, error: %v", 1000, count, err)
+	}
+
+	if count, err := DB.Model(&user).Association("Languages").CountUpTo(0); err != nil || count != 1000 {
+		t.Fatalf("invalid languages count without limit, expects: %v got %v, error: %v", 1000, count, err)
+	}
+
+	var sql string
+	DB.Callback().Query().After("gorm:query").Register("count_up_to_sql", func(tx *gorm.DB) {
+		if strings.Contains(tx.Statement.SQL.String(), "bounded_associations") {
+			sql = tx.Statement.SQL.String()
+		}
+	})
+	defer DB.Callback().Query().Remove("count_up_to_sql")
+
+	DB.Model(&user).Association("Languages").CountUpTo(100)
+	if !regexp.MustCompile(`(?i)SELECT count\(1\) FROM \(SELECT 1 FROM .+ LIMIT 100\) AS bounded_associations`).MatchString(sql) {
+		t.Errorf("count up to should count a bounded subquery, got %v", sql)
+	}
+}
+
+func TestMany2ManyAssociationFindIntoDTO(t *testing.T) {
+	type ProjectedRole struct {
+		ID          uint
+		Name        string
+		Description string
+		Level       int
+	}
+
+	type ProjectedUser struct {
+		ID    uint
+		Name  string
+		Roles []ProjectedRole `gorm:"many2many:projected_user_roles"`
+	}
+
+	type RoleDTO struct {
+		Name  string
+		Level int
+	}
+
+	DB.Migrator().DropTable(&ProjectedRole{}, "projected_user_roles", &ProjectedUser{})
+	if err := DB.AutoMigrate(&ProjectedUser{}, &ProjectedRole{}); err != nil {
+		t.Fatalf("failed to migrate, got error %v", err)
+	}
+
+	user := ProjectedUser{Name: "projected", Roles: []ProjectedRole{{Name: "admin", Description: "all", Level: 2}, {Name: "viewer", Description: "read", Level: 1}}}
+	DB.Create(&user)
+
+	var roles []RoleDTO
+	if err := DB.Model(&user).Association("Roles").Find(&roles); err != nil {
+		t.Fatalf("failed to find roles into dto, got error %v", err)
+	}
+
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	if len(roles) != 2 || roles[0] != (RoleDTO{Name: "admin", Level: 2}) || roles[1] != (RoleDTO{Name: "viewer", Level: 1}) {
+		t.Errorf("roles should be projected into dto, got %+v", roles)
+	}
+
+	var names []struct{ Name string }
+	if err := DB.Model(&user).Association("Roles").Find(&names, "level > ?", 1); err != nil {
+		t.Fatalf("failed to find roles into anonymous struct, got error %v", err)
+	}
+
+	if len(names) != 1 || names[0].Name != "admin" {
+		t.Errorf("roles should be projected into anonymous struct, got %+v", names)
+	}
+
+	var role RoleDTO
+	if err := DB.Model(&user).Association("Roles").First(&role, "name = ?", "viewer"); err != nil {
+		t.Fatalf("failed to find first role into dto, got error %v", err)
+	}
+
+	if role != (RoleDTO{Name: "viewer", Level: 1}) {
+		t.Errorf("role should be projected into dto, got %+v", role)
+	}
+}
+
+func TestMany2ManyAssociationAppendByID(t *testing.T) {
+	var user = *GetUser("many2many-append-by-id", Config{Languages: 1})
+	DB.Create(&user)
+
+	languages := []Language{{Code: "append-by-id-1", Name: "append-by-id"}, {Code: "append-by-id-2", Name: "append-by-id"}}
+	DB.Create(&languages)
+
+	if err := DB.Model(&user).Association("Languages").AppendByID([]string{languages[0].Code, languages[1].Code}, user.Languages[0].Code); err != nil {
+		t.Fatalf("failed to append languages by id, got error %v", err)
+	}
+
+	if len(user.Languages) != 1 {
+		t.Errorf("languages of the owner shouldn't be changed, got %+v", user.Languages)
+	}
+
+	AssertAssociationCount(t, user, "Languages", 3, "after append by id")
+
+	tx := DB.Set("gorm:association:validate_ids", true)
+	err := tx.Model(&user).Association("Languages").AppendByID("append-by-id-missing", languages[0].Code)
+	if !errors.Is(err, gorm.ErrRecordNotFound) || !strings.Contains(err.Error(), "[append-by-id-missing]") {
+		t.Errorf("should return ErrRecordNotFound with missing ids, got %v", err)
+	}
+
+	AssertAssociationCount(t, user, "Languages", 3, "after append missing ids")
+
+	if err := DB.Model(&[]User{user}).Association("Languages").AppendByID(languages[0].Code); !errors.Is(err, gorm.ErrUnsupportedRelation) {
+		t.Errorf("should return ErrUnsupportedRelation for slice owners, got %v", err)
+	}
+
+	if err := DB.Model(&user).Association("Company").AppendByID(1); !errors.Is(err, gorm.ErrUnsupportedRelation) {
+		t.Errorf("should return ErrUnsupportedRelation for belongs to, got %v", err)
+	}
+}
+
+func TestMany2ManyAssociationFindInBatches(t *testing.T) {
+	var user = *GetUser("many2many-find-in-batches", Config{})
+	DB.Create(&user)
+
+	languages := make([]Language, 120)
+	for idx := range languages {
+		languages[idx] = Language{Code: fmt.Sprintf("find-in-batches-%03d", idx), Name: "find-in-batches"}
+	}
+	if err := DB.Model(&user).Association("Languages").Append(&languages); err != nil {
+		t.Fatalf("failed to append languages, got error %v", err)
+	}
+
+	var (
+		results []Language
+		codes   []string
+	)
+	err := DB.Model(&user).Association("Languages").FindInBatches(&results, 50, func(tx *gorm.DB, batch int) error {
+		for _, language := range results {
+			codes = append(codes, language.Code)
+		}
+		return nil
+	})
+
+	if err != nil || len(codes) != 120 || codes[0] != "find-in-batches-000" || codes[119] != "find-in-batches-119" {
+		t.Fatalf("should find languages in batches ordered by primary key, got %v, error: %v", codes, err)
+	}
+
+	for idx := 1; idx < len(codes); idx++ {
+		if codes[idx] <= codes[idx-1] {
+			t.Fatalf("languages should be found in order without duplicates, got %v after %v", codes[idx], codes[idx-1])
+		}
+	}
+}
+
+func TestMany2ManyAssociationDeleteWithResult(t *testing.T) {
+	var user = *GetUser("many2many-delete-with-result", Config{Languages: 3})
+
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatalf("errors happened when create: %v", err)
+	}
+
+	if rowsAffected, err := DB.Model(&user).Association("Languages").DeleteWithResult(user.Languages[0], user.Languages[2]); err != nil || rowsAffected != 2 {
+		t.Fatalf("should delete two join records, got %v, error: %v", rowsAffected, err)
+	}
+
+	if len(user.Languages) != 1 {
+		t.Errorf("deleted languages should be removed from user's languages, but got %v", len(user.Languages))
+	}
+
+	AssertAssociationCount(t, user, "Languages", 1, "after delete with result")
+}
+
+func TestMany2ManyAssociationFindWithOrder(t *testing.T) {
+	var user = *GetUser("many2many-find-with-order", Config{Languages: 3})
+
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatalf("errors happened when create: %v", err)
+	}
+
+	var languages []Language
+	if err := DB.Model(&user).Association("Languages").Find(&languages, clause.OrderBy{
+		Columns: []clause.OrderByColumn{{Column: clause.Column{Table: "languages", Name: "code"}, Desc: true}},
+	}); err != nil || len(languages) != 3 {
+		t.Fatalf("should find three languages, got %v, error: %v", len(languages), err)
+	}
+
+	if languages[0].Code != user.Languages[2].Code || languages[2].Code != user.Languages[0].Code {
+		t.Errorf("languages should be ordered by code desc, but got %v, %v, %v", languages[0].Code, languages[1].Code, languages[2].Code)
+	}
+}
+
+func TestMany2ManyAssociationFindWithSelect(t *testing.T) {
+	var user = *GetUser("many2many-find-with-select", Config{Languages: 2})
+
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatalf("errors happened when create: %v", err)
+	}
+
+	var languages []Language
+	if err := DB.Model(&user).Select("code").Association("Languages").Find(&languages); err != nil || len(languages) != 2 {
+		t.Fatalf("should find two languages, got %v, error: %v", len(languages), err)
+	}
+
+	for _, language := range languages {
+		if language.Code == "" || language.Name != "" {
+			t.Errorf("only selected columns should be loaded, but got %+v", language)
+		}
+	}
+
+	var friends []User
+	var friend = GetUser("many2many-find-with-select-friend", Config{})
+	DB.Model(&user).Association("Friends").Append(friend)
+	if err := DB.Model(&user).Select("id", "name").Association("Friends").Find(&friends); err != nil || len(friends) != 1 {
+		t.Fatalf("should find one friend, got %v, error: %v", len(friends), err)
+	}
+
+	if friends[0].ID != friend.ID || friends[0].Name != friend.Name || friends[0].Age != 0 {
+		t.Errorf("only selected columns should be loaded, but got %+v", friends[0])
+	}
+}
+
+func TestMany2ManyAssociationReplaceInBatches(t *testing.T) {
+	var user = *GetUser("many2many-replace-in-batches", Config{Friends: 5})
+
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatalf("errors happened when create: %v", err)
+	}
+
+	var (
+		friend1 = GetUser("many2many-replace-in-batches-friend1", Config{})
+		friend2 = GetUser("many2many-replace-in-batches-friend2", Config{})
+		friends = []*User{user.Friends[1], friend1, user.Friends[3], friend2}
+	)
+
+	if err := DB.Model(&user).Association("Friends").ReplaceInBatches(2, friends); err != nil {
+		t.Fatalf("errors happened when replace in batches: %v", err)
+	}
+
+	if friend1.ID == 0 || friend2.ID == 0 {
+		t.Errorf("created friends's primary keys should be filled, but got %v, %v", friend1.ID, friend2.ID)
+	}
+
+	if len(user.Friends) != 4 || user.Friends[1].ID != friend1.ID {
+		t.Errorf("user's friends should be replaced, but got %v", len(user.Friends))
+	}
+
+	var user2 User
+	DB.Find(&user2, "id = ?", user.ID)
+	DB.Model(&user2).Association("Friends").Find(&user2.Friends, clause.OrderBy{
+		Columns: []clause.OrderByColumn{{Column: clause.Column{Table: "users", Name: "id"}}},
+	})
+
+	if len(user2.Friends) != 4 {
+		t.Fatalf("should have four friends after replace in batches, but got %v", len(user2.Friends))
+	}
+
+	for idx, friend := range []*User{friends[0], friends[2], friend1, friend2} {
+		if user2.Friends[idx].ID != friend.ID {
+			t.Errorf("invalid friend #%v, expects: %v, got %v", idx, friend.ID, user2.Friends[idx].ID)
+		}
+	}
+
+	// replace in one transaction with "gorm:association:replace_in_transaction"
+	DB.Callback().Delete().Before("gorm:delete").Register("test:fail_replace_in_batches", func(db *gorm.DB) {
+		if db.Statement.Table == "user_friends" {
+			db.AddError(errors.New("failed to delete join records"))
+		}
+	})
+
+	friend3 := GetUser("many2many-replace-in-batches-friend3", Config{})
+	txDB := DB.Set("gorm:association:replace_in_transaction", true)
+	if err := txDB.Model(&user).Association("Friends").ReplaceInBatches(2, friend3); err == nil {
+		t.Errorf("should return error when failed to delete join records")
+	}
+	AssertAssociationCount(t, user, "Friends", 4, "after failed replace in one transaction")
+
+	if err := DB.Model(&user).Association("Friends").ReplaceInBatches(2, friend3); err == nil {
+		t.Errorf("should return error when failed to delete join records")
+	}
+	DB.Callback().Delete().Remove("test:fail_replace_in_batches")
+	AssertAssociationCount(t, user, "Friends", 5, "after failed replace in batches")
+
+	if err := DB.Model(&user).Association("Friends").Delete(friend3); err != nil {
+		t.Fatalf("errors happened when delete: %v", err)
+	}
+
+	// rollback the whole replacement when wrapped in a transaction
+	tx := DB.Begin()
+	if err := tx.Model(&user).Association("Friends").ReplaceInBatches(1, user.Friends[0]); err != nil {
+		t.Fatalf("errors happened when replace in batches: %v", err)
+	}
+	if count := tx.Model(&user).Association("Friends").Count(); count != 1 {
+		t.Errorf("invalid friends count in transaction, expects: %v got %v", 1, count)
+	}
+	tx.Rollback()
+
+	AssertAssociationCount(t, user, "Friends", 4, "after rollback")
+}
+
+func TestMany2ManyAssociationReplaceRestoreOnError(t *testing.T) {
+	var user = *GetUser("many2many-replace-restore-on-error", Config{Languages: 2})
+
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatalf("errors happened when create: %v", err)
+	}
+
+	DB.Callback().Delete().Before("gorm:delete").Register("TestMany2ManyAssociationReplaceRestoreOnError", func(db *gorm.DB) {
+		if db.Statement.Table == "user_speaks" {
+			db.AddError(errors.New("failed to delete join records"))
+		}
+	})
+	defer DB.Callback().Delete().Remove("TestMany2ManyAssociationReplaceRestoreOnError")
+
+	languages := user.Languages
+	if err := DB.Model(&user).Association("Languages").Replace(&Language{Code: "many2many-replace-restore-on-error", Name: "language"}); err == nil {
+		t.Fatalf("should return error when failed to delete join records")
+	}
+
+	if len(user.Languages) != 2 || user.Languages[0].Code != languages[0].Code || user.Languages[1].Code != languages[1].Code {
+		t.Errorf("user's languages should be restored after failed replace, but got %+v", user.Languages)
+	}
+}
+
+func TestMany2ManyAssociationRows(t *testing.T) {
+	var user = *GetUser("many2many-rows", Config{Languages: 3})
+
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatalf("errors happened when create: %v", err)
+	}
+
+	rows, err := DB.Model(&user).Association("Languages").Rows()
+	if err != nil {
+		t.Fatalf("errors happened when get rows: %v", err)
+	}
+	defer rows.Close()
+
+	// only columns of the associations's table are selected, without columns of the join table
+	if columns, _ := rows.Columns(); len(columns) != 2 {
+		t.Errorf("rows should only contain columns of languages, but got %v", columns)
+	}
+
+	codes := map[string]bool{}
+	for rows.Next() {
+		var language Language
+		if err := DB.ScanRows(rows, &language); err != nil {
+			t.Fatalf("errors happened when scan rows: %v", err)
+		}
+		codes[language.Code] = true
+	}
+
+	if len(codes) != 3 {
+		t.Fatalf("should iterate three languages, but got %v", len(codes))
+	}
+
+	for _, language := range user.Languages {
+		if !codes[language.Code] {
+			t.Errorf("language %v should be iterated", language.Code)
+		}
+	}
+}
+
+func TestMany2ManyAssociationSave(t *testing.T) {
+	var user = *GetUser("many2many-save", Config{Languages: 2})
+
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatalf("errors happened when create: %v", err)
+	}
+
+	var languages []Language
+	DB.Model(&user).Association("Languages").Find(&languages)
+	for idx := range languages {
+		languages[idx].Name = "saved-" + languages[idx].Name
+	}
+
+	var joinCount int64
+	DB.Table("user_speaks").Where("user_id = ?", user.ID).Count(&joinCount)
+
+	if err := DB.Model(&user).Association("Languages").Save(languages); err != nil {
+		t.Fatalf("failed to save languages, got error %v", err)
+	}
+
+	var saved []Language
+	DB.Model(&user).Association("Languages").Find(&saved)
+	for _, language := range saved {
+		if !strings.HasPrefix(language.Name, "saved-") {
+			t.Errorf("should save language's name, but got %v", language.Name)
+		}
+	}
+
+	var newJoinCount int64
+	DB.Table("user_speaks").Where("user_id = ?", user.ID).Count(&newJoinCount)
+	if joinCount != 2 || newJoinCount != joinCount {
+		t.Errorf("join records shouldn't be changed, expects %v, but got %v", joinCount, newJoinCount)
+	}
+}
+
+func TestMany2ManyAssociationReplaceWithContextTimeout(t *testing.T) {
+	var user = *GetUser("many2many-replace-timeout", Config{Languages: 2})
+
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatalf("errors happened when create: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+
+	if err := DB.Model(&user).WithContext(ctx).Association("Languages").Clear(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("should abort deleting join records with context's error, but got %v", err)
+	}
+
+	if err := DB.Model(&user).Association("Languages").WithContext(ctx).Replace(&user.Languages[0]); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("should abort replacing associations with context's error, but got %v", err)
+	}
+
+	AssertAssociationCount(t, user, "Languages", 2, "after replacing with expired context")
+}
+
+func TestMany2ManyAssociationReplaceRestoreOnCancel(t *testing.T) {
+	var user = *GetUser("many2many-replace-cancel", Config{Languages: 2})
+
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatalf("errors happened when create: %v", err)
+	}
+
+	// cancel the context once join records of new associations are created, before old ones are deleted
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	DB.Callback().Create().After("gorm:create").Register("cancel_replace", func(tx *gorm.DB) {
+		if tx.Statement.Table == "user_speaks" {
+			cancel()
+		}
+	})
+	defer DB.Callback().Create().Remove("cancel_replace")
+
+	// cancelling the context in a transaction discards its connection, enable foreign keys of the new connection like setup
+	if DB.Dialector.Name() == "sqlite" {
+		defer DB.Exec("PRAGMA foreign_keys = ON")
+	}
+
+	languages := user.Languages
+	newLanguage := Language{Code: "many2many-replace-cancel", Name: "many2many-replace-cancel"}
+	if err := DB.Model(&user).WithContext(ctx).Association("Languages").Replace(&newLanguage); !errors.Is(err, context.Canceled) {
+		t.Fatalf("should abort replacing associations with context's error, but got %v", err)
+	}
+
+	if len(user.Languages) != 2 || user.Languages[0].Code != languages[0].Code || user.Languages[1].Code != languages[1].Code {
+		t.Errorf("languages should be restored after cancelled replacing, but got %+v", user.Languages)
+	}
+
+	var codes []string
+	DB.Table("user_speaks").Where("user_id = ?", user.ID).Pluck("language_code", &codes)
+	for _, language := range languages {
+		if !strings.Contains(strings.Join(codes, ","), language.Code) {
+			t.Errorf("old associations shouldn't be detached after cancelled replacing, but got %v", codes)
+		}
+	}
+}
+
+func TestMany2ManyAssociationPluck(t *testing.T) {
+	var user = *GetUser("many2many-pluck", Config{Languages: 2})
+
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatalf("errors happened when create: %v", err)
+	}
+
+	var names []string
+	if err := DB.Model(&user).Association("Languages").Pluck("name", &names); err != nil {
+		t.Fatalf("failed to pluck names, got error %v", err)
+	}
+
+	if len(names) != 2 || !((names[0] == user.Languages[0].Name && names[1] == user.Languages[1].Name) || (names[0] == user.Languages[1].Name && names[1] == user.Languages[0].Name)) {
+		t.Errorf("invalid plucked names, got %v", names)
+	}
+
+	var codes []string
+	if err := DB.Model(&user).Association("Languages").Pluck("Code", &codes); err != nil || len(codes) != 2 {
+		t.Errorf("should pluck codes with field name, got %v, error: %v", codes, err)
+	}
+
+	var name string
+	if err := DB.Model(&user).Association("Languages").Pluck("name", &name); !errors.Is(err, gorm.ErrInvalidData) {
+		t.Errorf("should return error when pluck into non-slice, but got %v", err)
+	}
+}
+
+func TestMany2ManyAssociationBroadcast(t *testing.T) {
+	type BroadcastTag struct {
+		ID   uint
+		Name string
+	}
+
+	type BroadcastPost struct {
+		ID    uint
+		Title string
+		Tags  []BroadcastTag `gorm:"many2many:broadcast_post_tags;"`
+	}
+
+	DB.Migrator().DropTable(&BroadcastPost{}, &BroadcastTag{}, "broadcast_post_tags")
+	if err := DB.AutoMigrate(&BroadcastPost{}, &BroadcastTag{}); err != nil {
+		t.Fatalf("Failed to migrate, got %v", err)
+	}
+
+	posts := []BroadcastPost{{Title: "post-1"}, {Title: "post-2"}, {Title: "post-3"}, {Title: "post-4"}, {Title: "post-5"}}
+	DB.Create(&posts)
+
+	tag := BroadcastTag{Name: "featured"}
+	if err := DB.Model(&posts).Association("Tags").Append(&tag); err == nil {
+		t.Errorf("should return error for length mismatch without broadcast")
+	}
+
+	if err := DB.Model(&posts).Association("Tags").Broadcast().Append(&tag); err != nil {
+		t.Fatalf("failed to broadcast tag, got error %v", err)
+	}
+
+	var tags []BroadcastTag
+	if DB.Find(&tags); len(tags) != 1 || tag.ID == 0 {
+		t.Errorf("should create broadcast tag once, but got %+v", tags)
+	}
+
+	for _, post := range posts {
+		if len(post.Tags) != 1 || post.Tags[0].ID != tag.ID {
+			t.Errorf("should assign broadcast tag to post %v, but got %+v", post.Title, post.Tags)
+		}
+
+		if count := DB.Model(&post).Association("Tags").Count(); count != 1 {
+			t.Errorf("post %v should have one tag, but got %v", post.Title, count)
+		}
+	}
+
+	newTag := BroadcastTag{Name: "archived"}
+	if err := DB.Model(&posts).Association("Tags").Broadcast().Replace(&newTag); err != nil {
+		t.Fatalf("failed to broadcast replacing tags, got error %v", err)
+	}
+
+	if count := DB.Model(&posts).Association("Tags").Count(); count != 5 {
+		t.Errorf("should replace tags of all posts, but got %v", count)
+	}
+
+	var postTagsCount int64
+	DB.Table("broadcast_post_tags").Where("broadcast_tag_id = ?", newTag.ID).Count(&postTagsCount)
+	if postTagsCount != 5 {
+		t.Errorf("should link new tag to all posts, but got %v", postTagsCount)
+	}
+
+	if err := DB.Model(&[]User{}).Association("Pets").Broadcast().Error; !errors.Is(err, gorm.ErrUnsupportedRelation) {
+		t.Errorf("should return error for broadcasting has many associations, but got %v", err)
+	}
+}
+
+func TestMany2ManyAssociationJoinAlias(t *testing.T) {
+	var (
+		userA = *GetUser("join-alias-a", Config{})
+		userB = *GetUser("join-alias-b", Config{})
+		userC = *GetUser("join-alias-c", Config{})
+		userD = *GetUser("join-alias-d", Config{})
+	)
+	DB.Create(&[]*User{&userA, &userB, &userC, &userD})
+
+	DB.Model(&userA).Association("Friends").Append(&userB, &userC)
+	DB.Model(&userB).Association("Friends").Append(&userD)
+
+	var friendsA, friendsB []User
+	if err := DB.Model(&userA).Association("Friends").JoinAlias("af").Find(&friendsA); err != nil || len(friendsA) != 2 {
+		t.Errorf("should find friends of user a with alias, got %v, error: %v", len(friendsA), err)
+	}
+
+	if err := DB.Model(&userB).Association("Friends").JoinAlias("af").Find(&friendsB); err != nil || len(friendsB) != 1 || friendsB[0].ID != userD.ID {
+		t.Errorf("should find friends of user b with alias, got %+v, error: %v", friendsB, err)
+	}
+
+	// compose the association query with another join of the same join table, find user a's friends having friends
+	var friendsHavingFriends []User
+	if err := DB.Model(&userA).Joins("JOIN user_friends ON user_friends.user_id = users.id").Association("Friends").JoinAlias("af").Find(&friendsHavingFriends); err != nil {
+		t.Fatalf("failed to find friends with composed join, got error %v", err)
+	}
+
+	if len(friendsHavingFriends) != 1 || friendsHavingFriends[0].ID != userB.ID {
+		t.Errorf("should find user b only, but got %+v", friendsHavingFriends)
+	}
+
+	if count := DB.Model(&userA).Association("Friends").JoinAlias("af").JoinWhere("friend_id = ?", userC.ID).Count(); count != 1 {
+		t.Errorf("should count friends with join conditions and alias, but got %v", count)
+	}
+
+	if err := DB.Model(&userA).Association("Pets").JoinAlias("af").Error; !errors.Is(err, gorm.ErrUnsupportedRelation) {
+		t.Errorf("should return error for relation without join table, but got %v", err)
+	}
+}
+
+type retryableErrorDialector struct {
+	gorm.Dialector
+	retryable func(err error) bool
+}
+
+func (dialector retryableErrorDialector) IsRetryableError(err error) bool {
+	return dialector.retryable(err)
+}
+
+func (dialector retryableErrorDialector) SavePoint(tx *gorm.DB, name string) error {
+	return dialector.Dialector.(gorm.SavePointerDialectorInterface).SavePoint(tx, name)
+}
+
+func (dialector retryableErrorDialector) RollbackTo(tx *gorm.DB, name string) error {
+	return dialector.Dialector.(gorm.SavePointerDialectorInterface).RollbackTo(tx, name)
+}
+
+func TestMany2ManyAssociationRetryOnDeadlock(t *testing.T) {
+	var user = *GetUser("many2many-deadlock", Config{Languages: 3})
+
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatalf("errors happened when create: %v", err)
+	}
+
+	var attempts int
+	var failure = errors.New("Error 1213: Deadlock found when trying to get lock; try restarting transaction")
+	DB.Callback().Delete().Before("gorm:delete").Register("simulate_deadlock", func(db *gorm.DB) {
+		if db.Statement.Table == "user_speaks" {
+			if attempts++; attempts == 1 {
+				db.AddError(failure)
+			}
+		}
+	})
+	defer DB.Callback().Delete().Remove("simulate_deadlock")
+
+	if err := DB.Model(&user).Association("Languages").Delete(&user.Languages[0]); !errors.Is(err, failure) || attempts != 1 {
+		t.Errorf("should not retry without deadlock retries setting, got %v attempts, error: %v", attempts, err)
+	}
+
+	attempts = 0
+	noRetryableDB := DB.Set("gorm:association:deadlock_retries", 2).Session(&gorm.Session{})
+	if err := noRetryableDB.Model(&user).Association("Languages").Delete(&user.Languages[0]); !errors.Is(err, failure) || attempts != 1 {
+		t.Errorf("should not retry without retryable error dialector, got %v attempts, error: %v", attempts, err)
+	}
+
+	attempts = 0
+	retryDB := DB.Set("gorm:association:deadlock_retries", 2).Session(&gorm.Session{})
+	retryDB.Dialector = retryableErrorDialector{Dialector: DB.Dialector, retryable: func(err error) bool {
+		return strings.Contains(err.Error(), "Deadlock found")
+	}}
+	if rowsAffected, err := retryDB.Model(&user).Association("Languages").DeleteWithResult(&user.Languages[0]); err != nil || rowsAffected != 1 || attempts != 2 {
+		t.Errorf("should retry deleting on deadlock, got %v attempts, %v rows affected, error: %v", attempts, rowsAffected, err)
+	}
+
+	attempts = 0
+	language := user.Languages[1]
+	if err := retryDB.Model(&user).Association("Languages").Replace(&language); err != nil || attempts != 2 {
+		t.Errorf("should retry replacing on deadlock, got %v attempts, error: %v", attempts, err)
+	}
+
+	if len(user.Languages) != 1 || user.Languages[0].Code != language.Code {
+		t.Errorf("should replace owner's languages once, but got %+v", user.Languages)
+	}
+	AssertAssociationCount(t, user, "Languages", 1, "after retried replace")
+
+	attempts = 0
+	failure = errors.New("constraint failed")
+	if err := retryDB.Model(&user).Association("Languages").Clear(); !errors.Is(err, failure) || attempts != 1 {
+		t.Errorf("should not retry other errors, got %v attempts, error: %v", attempts, err)
+	}
+}
+
+func TestMany2ManyJSONAssociation(t *testing.T) {
+	if name := DB.Dialector.Name(); name != "postgres" && name != "sqlite" {
+		t.Skip("jsonb columns are tested with postgres and sqlite")
+	}
+
+	type JSONTag struct {
+		ID   uint
+		Name string
+	}
+
+	type JSONTagPost struct {
+		ID     uint
+		Title  string
+		TagIDs string    `gorm:"type:jsonb"`
+		Tags   []JSONTag `gorm:"many2many_json:tag_ids"`
+	}
+
+	DB.Migrator().DropTable(&JSONTagPost{}, &JSONTag{})
+	if err := DB.AutoMigrate(&JSONTag{}, &JSONTagPost{}); err != nil {
+		t.Fatalf("failed to migrate, got error %v", err)
+	}
+
+	tag := JSONTag{Name: "tag-json-existing"}
+	DB.Create(&tag)
+
+	post := JSONTagPost{Title: "post-json", TagIDs: "[]"}
+	DB.Create(&post)
+
+	// Append
+	if err := DB.Model(&post).Association("Tags").Append(&tag, &JSONTag{Name: "tag-json-new"}); err != nil {
+		t.Fatalf("failed to append, got error %v", err)
+	}
+
+	if len(post.Tags) != 2 || post.Tags[1].ID == 0 {
+		t.Fatalf("appended tags should be assigned to the post, got %+v", post.Tags)
+	}
+
+	var savedPost JSONTagPost
+	DB.First(&savedPost, post.ID)
+	if savedPost.TagIDs != post.TagIDs || savedPost.TagIDs != fmt.Sprintf("[%d,%d]", tag.ID, post.Tags[1].ID) {
+		t.Fatalf("tag ids should be saved, got %v, expects %v", savedPost.TagIDs, post.TagIDs)
+	}
+
+	// Find
+	var tags []JSONTag
+	if err := DB.Model(&savedPost).Association("Tags").Find(&tags); err != nil || len(tags) != 2 {
+		t.Fatalf("should find 2 tags, got %v, error %v", len(tags), err)
+	}
+
+	if count := DB.Model(&savedPost).Association("Tags").Count(); count != 2 {
+		t.Fatalf("should count 2 tags, got %v", count)
+	}
+
+	var otherPost = JSONTagPost{Title: "post-json-other", TagIDs: fmt.Sprintf("[%d]", tag.ID)}
+	DB.Create(&otherPost)
+
+	if count := DB.Model(&[]JSONTagPost{savedPost, otherPost}).Association("Tags").Count(); count != 2 {
+		t.Fatalf("should count 2 distinct tags of posts, got %v", count)
+	}
+
+	// Replace
+	if err := DB.Model(&savedPost).Association("Tags").Replace(&JSONTag{Name: "tag-json-replace"}); err != nil {
+		t.Fatalf("failed to replace, got error %v", err)
+	}
+
+	tags = nil
+	DB.Model(&savedPost).Association("Tags").Find(&tags)
+	if len(tags) != 1 || tags[0].Name != "tag-json-replace" {
+		t.Fatalf("tags should be replaced, got %+v", tags)
+	}
+
+	// Delete
+	if rowsAffected, err := DB.Model(&otherPost).Association("Tags").DeleteWithResult(&tag); err != nil || rowsAffected != 1 {
+		t.Fatalf("failed to delete, got rows affected %v, error %v", rowsAffected, err)
+	}
+
+	if count := DB.Model(&otherPost).Association("Tags").Count(); count != 0 || otherPost.TagIDs != "[]" {
+		t.Fatalf("tag should be deleted, got count %v, tag ids %v", count, otherPost.TagIDs)
+	}
+
+	// Clear
+	if err := DB.Model(&savedPost).Association("Tags").Clear(); err != nil || len(savedPost.Tags) != 0 {
+		t.Fatalf("failed to clear, got tags %+v, error %v", savedPost.Tags, err)
+	}
+
+	if count := DB.Model(&savedPost).Association("Tags").Count(); count != 0 {
+		t.Fatalf("tags should be cleared, got %v", count)
+	}
+
+	// Preload
+	if err := DB.Preload("Tags").First(&JSONTagPost{}, savedPost.ID).Error; !errors.Is(err, gorm.ErrUnsupportedRelation) {
+		t.Fatalf("should not preload many2many json relations, got %v", err)
+	}
+}
+
+func TestMany2ManyAssociationDeleteAll(t *testing.T) {
+	users := []User{*GetUser("many2many-delete-all-1", Config{Languages: 2}), *GetUser("many2many-delete-all-2", Config{Languages: 1})}
+	DB.Create(&users)
+
+	rowsAffected, err := DB.Model(&users).Association("Languages").DeleteAll()
+	if err != nil {
+		t.Fatalf("failed to delete all languages, got error %v", err)
+	}
+
+	if rowsAffected != 3 {
+		t.Errorf("rows affected should be %v, got %v", 3, rowsAffected)
+	}
+
+	if len(users[0].Languages) != 2 || len(users[1].Languages) != 1 {
+		t.Errorf("owners shouldn't be changed in memory, got %v, %v", users[0].Languages, users[1].Languages)
+	}
+
+	AssertAssociationCount(t, users, "Languages", 0, "after delete all")
+
+	var count int64
+	DB.Model(&Language{}).Where("code IN ?", []string{users[0].Languages[0].Code, users[0].Languages[1].Code, users[1].Languages[0].Code}).Count(&count)
+	if count != 3 {
+		t.Errorf("languages should be kept, expects %v, got %v", 3, count)
+	}
+}
+
+func TestMany2ManyAssociationClear(t *testing.T) {
+	user := *GetUser("many2many-clear", Config{Languages: 2})
+	DB.Create(&user)
+
+	if err := DB.Model(&user).Association("Languages").Clear(); err != nil {
+		t.Fatalf("failed to clear languages, got error %v", err)
+	}
+
+	if user.Languages != nil {
+		t.Errorf("owner's field should be zeroed, got %v", user.Languages)
+	}
+
+	AssertAssociationCount(t, user, "Languages", 0, "after clear")
+
+	var count int64
+	DB.Model(&Language{}).Where("code IN ?", []string{user.Name + "_locale_1", user.Name + "_locale_2"}).Count(&count)
+	if count != 2 {
+		t.Errorf("languages should be kept, expects %v, got %v", 2, count)
+	}
+
+	unsaved := *GetUser("many2many-clear-unsaved", Config{Languages: 2})
+	if writes := countWrites(func() {
+		if err := DB.Model(&unsaved).Association("Languages").Clear(); err != nil {
+			t.Errorf("failed to clear languages of unsaved user, got error %v", err)
+		}
+	}); writes != 0 {
+		t.Errorf("clearing unsaved owner shouldn't touch the database, got %v writes", writes)
+	}
+
+	if unsaved.Languages != nil {
+		t.Errorf("owner's field should be zeroed, got %v", unsaved.Languages)
+	}
+}
+
+type GroupedRole struct {
+	ID       uint
+	Name     string
+	Category string
+}
+
+type GroupedRoleUser struct {
+	ID    uint
+	Name  string
+	Roles []GroupedRole `gorm:"many2many:grouped_role_users_roles"`
+}
+
+func TestMany2ManyAssociationFindGroupBy(t *testing.T) {
+	DB.Migrator().DropTable(&GroupedRoleUser{}, &GroupedRole{}, "grouped_role_users_roles")
+	if err := DB.AutoMigrate(&GroupedRoleUser{}, &GroupedRole{}); err != nil {
+		t.Fatalf("failed to migrate, got error %v", err)
+	}
+
+	user := GroupedRoleUser{Name: "grouped", Roles: []GroupedRole{
+		{Name: "owner", Category: "admin"}, {Name: "editor", Category: "admin"}, {Name: "developer", Category: "dev"},
+	}}
+	other := GroupedRoleUser{Name: "other", Roles: []GroupedRole{user.Roles[0], {Name: "tester", Category: "dev"}}}
+	DB.Create(&user)
+	DB.Create(&other)
+
+	type CategoryCount struct {
+		Category string
+		Total    int
+	}
+
+	var counts []CategoryCount
+	if err := DB.Model(&user).Select("category", "count(*) AS total").Association("Roles").Find(&counts,
+		clause.GroupBy{Columns: []clause.Column{{Name: "category"}}}, clause.OrderBy{Columns: []clause.OrderByColumn{{Column: clause.Column{Name: "category"}}}},
+	); err != nil {
+		t.Fatalf("failed to group roles, got error %v", err)
+	}
+
+	if len(counts) != 2 || counts[0] != (CategoryCount{"admin", 2}) || counts[1] != (CategoryCount{"dev", 1}) {
+		t.Errorf("roles should be grouped by category, got %+v", counts)
+	}
+
+	counts = nil
+	if err := DB.Model(&user).Select("category", "count(*) AS total").Association("Roles").Find(&counts,
+		clause.GroupBy{Columns: []clause.Column{{Name: "category"}}, Having: []clause.Expression{clause.Gt{Column: "total", Value: 1}}},
+	); err != nil {
+		t.Fatalf("failed to group roles with having, got error %v", err)
+	}
+
+	if len(counts) != 1 || counts[0] != (CategoryCount{"admin", 2}) {
+		t.Errorf("groups should be filtered by having, got %+v", counts)
+	}
+
+	var categories []string
+	if err := DB.Model(&other).Association("Roles").Find(&categories, clause.GroupBy{Columns: []clause.Column{{Name: "category"}}}); err != nil {
+		t.Fatalf("failed to find grouped categories, got error %v", err)
+	}
+
+	sort.Strings(categories)
+	if len(categories) != 2 || categories[0] != "admin" || categories[1] != "dev" {
+		t.Errorf("grouped columns should be selected without selects, got %v", categories)
+	}
+}
+
+func TestMany2ManyAssociationFindWithSubQuery(t *testing.T) {
+	type SubQueryPermission struct {
+		ID             uint
+		SubQueryRoleID uint
+		Name           string
+	}
+
+	type SubQueryRole struct {
+		ID          uint
+		Name        string
+		Permissions []SubQueryPermission
+	}
+
+	type SubQueryUser struct {
+		ID    uint
+		Name  string
+		Roles []SubQueryRole `gorm:"many2many:sub_query_user_roles"`
+	}
+
+	DB.Migrator().DropTable(&SubQueryPermission{}, &SubQueryRole{}, &SubQueryUser{}, "sub_query_user_roles")
+	if err := DB.AutoMigrate(&SubQueryUser{}, &SubQueryRole{}, &SubQueryPermission{}); err != nil {
+		t.Fatalf("failed to migrate, got error %v", err)
+	}
+
+	user := SubQueryUser{Name: "sub-query", Roles: []SubQueryRole{
+		{Name: "admin", Permissions: []SubQueryPermission{{Name: "write"}, {Name: "read"}}},
+		{Name: "reader", Permissions: []SubQueryPermission{{Name: "read"}}},
+		{Name: "guest"},
+	}}
+	DB.Create(&user)
+
+	withPermission := func(name string) interface{} {
+		subQuery := DB.Model(&SubQueryPermission{}).Select("1").Where("sub_query_permissions.sub_query_role_id = sub_query_roles.id")
+		if name != "" {
+			subQuery = subQuery.Where("sub_query_permissions.name = ?", name)
+		}
+		return gorm.Expr("EXISTS (?)", subQuery)
+	}
+
+	var roles []SubQueryRole
+	if err := DB.Model(&user).Association("Roles").Find(&roles, withPermission("")); err != nil {
+		t.Fatalf("failed to find roles with permissions, got error %v", err)
+	}
+
+	if len(roles) != 2 || roles[0].Name != "admin" || roles[1].Name != "reader" {
+		t.Errorf("only roles with permissions should be found, got %+v", roles)
+	}
+
+	roles = nil
+	if err := DB.Model(&user).Association("Roles").Find(&roles, withPermission("write"), "name <> ?", "guest"); err != nil {
+		t.Fatalf("failed to find roles with write permission, got error %v", err)
+	}
+
+	if len(roles) != 1 || roles[0].Name != "admin" {
+		t.Errorf("subquery's parameters should be bound, got %+v", roles)
+	}
+
+	if count := DB.Model(&user).Association("Roles").Count(withPermission("read")); count != 2 {
+		t.Errorf("roles with read permission should be counted, got %v", count)
+	}
+}
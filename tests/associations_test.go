@@ -1,9 +1,20 @@
 package tests_test
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
+	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
 	. "gorm.io/gorm/utils/tests"
 )
 
@@ -176,3 +187,1147 @@ func TestForeignKeyConstraintsBelongsTo(t *testing.T) {
 		t.Fatalf("Should not find deleted profile")
 	}
 }
+
+func TestAssociationExists(t *testing.T) {
+	var user = *GetUser("association-exists", Config{Account: true, Pets: 2, Languages: 2})
+
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatalf("errors happened when create: %v", err)
+	}
+
+	for _, name := range []string{"Account", "Pets", "Languages"} {
+		if exists, err := DB.Model(&user).Association(name).Exists(); err != nil || !exists {
+			t.Errorf("%v should exist, got %v, error: %v", name, exists, err)
+		}
+	}
+
+	var emptyUser = *GetUser("association-exists-empty", Config{})
+
+	if err := DB.Create(&emptyUser).Error; err != nil {
+		t.Fatalf("errors happened when create: %v", err)
+	}
+
+	for _, name := range []string{"Account", "Pets", "Languages"} {
+		if exists, err := DB.Model(&emptyUser).Association(name).Exists(); err != nil || exists {
+			t.Errorf("%v should not exist, got %v, error: %v", name, exists, err)
+		}
+	}
+
+	if _, err := DB.Model(&user).Association("Invalid").Exists(); err == nil {
+		t.Errorf("should return error for invalid association")
+	}
+}
+
+func TestAssociationSelfReference(t *testing.T) {
+	var user = *GetUser("association-self-reference", Config{Team: 1, Friends: 1})
+
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatalf("errors happened when create: %v", err)
+	}
+
+	if err := DB.Model(&user).Association("Team").Append(&user); err == nil {
+		t.Errorf("should return error when appending user to its own team")
+	}
+
+	var sameUser User
+	DB.First(&sameUser, user.ID)
+	if err := DB.Model(&user).Association("Friends").Append(&sameUser); err == nil {
+		t.Errorf("should return error when appending user to its own friends")
+	}
+
+	if err := DB.Model(&user).Association("Manager").Replace(&sameUser); err == nil {
+		t.Errorf("should return error when setting user as its own manager")
+	}
+
+	AssertAssociationCount(t, user, "Team", 1, "after appending self reference")
+	AssertAssociationCount(t, user, "Friends", 1, "after appending self reference")
+	AssertAssociationCount(t, user, "Manager", 0, "after appending self reference")
+
+	if err := DB.Model(&user).Association("Team").Append(GetUser("association-self-reference-team", Config{})); err != nil {
+		t.Errorf("no error should happen when appending other user, but got %v", err)
+	}
+}
+
+func TestAssociationWithContext(t *testing.T) {
+	var user = *GetUser("association-with-context", Config{Pets: 2})
+
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatalf("errors happened when create: %v", err)
+	}
+
+	var pets []Pet
+	if err := DB.Model(&user).Association("Pets").WithContext(context.Background()).Find(&pets); err != nil || len(pets) != 2 {
+		t.Fatalf("should find two pets, got %v, error: %v", len(pets), err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := DB.Model(&user).Association("Pets").WithContext(ctx).Find(&pets); err == nil {
+		t.Errorf("should return error when finding with cancelled context")
+	}
+
+	if err := DB.Model(&user).Association("Pets").WithContext(ctx).Append(&Pet{Name: "pet-with-context"}); err == nil {
+		t.Errorf("should return error when appending with cancelled context")
+	}
+
+	AssertAssociationCount(t, user, "Pets", 2, "after appending with cancelled context")
+}
+
+func TestAssociationFindWithLocking(t *testing.T) {
+	var user = *GetUser("association-find-with-locking", Config{Pets: 1, Languages: 1})
+
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatalf("errors happened when create: %v", err)
+	}
+
+	var sqls []string
+	DB.Callback().Query().After("gorm:query").Register("test:capture_locking_sql", func(db *gorm.DB) {
+		sqls = append(sqls, db.Statement.SQL.String())
+	})
+	defer DB.Callback().Query().Remove("test:capture_locking_sql")
+
+	var (
+		pets      []Pet
+		languages []Language
+		dryRunDB  = DB.Session(&gorm.Session{DryRun: true})
+	)
+
+	dryRunDB.Model(&user).Association("Pets").Find(&pets, clause.Locking{Strength: "UPDATE"})
+	dryRunDB.Model(&user).Association("Languages").Find(&languages, clause.Locking{Strength: "UPDATE"})
+	dryRunDB.Model(&user).Clauses(clause.Locking{Strength: "UPDATE"}).Association("Languages").Find(&languages)
+
+	if len(sqls) != 3 {
+		t.Fatalf("should run three queries, but got %v", len(sqls))
+	}
+
+	for _, sql := range sqls {
+		if !strings.HasSuffix(sql, "FOR UPDATE") {
+			t.Errorf("association query should lock rows, but got %v", sql)
+		}
+	}
+
+	if name := DB.Dialector.Name(); name == "sqlite" || name == "sqlserver" {
+		t.Skip("skip locking rows because of database doesn't support FOR UPDATE")
+	}
+
+	tx := DB.Begin()
+	if err := tx.Model(&user).Association("Languages").Find(&languages, clause.Locking{Strength: "UPDATE"}); err != nil || len(languages) != 1 {
+		t.Fatalf("should find one language, got %v, error: %v", len(languages), err)
+	}
+
+	updated := make(chan error)
+	go func() {
+		updated <- DB.Model(&Language{}).Where("code = ?", languages[0].Code).Update("name", "locked").Error
+	}()
+
+	select {
+	case err := <-updated:
+		t.Errorf("update should be blocked by locked rows, but finished with error: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	tx.Commit()
+
+	if err := <-updated; err != nil {
+		t.Errorf("no error should happen after locked rows released, but got %v", err)
+	}
+}
+
+func TestAssociationError(t *testing.T) {
+	var user = *GetUser("association-error", Config{Pets: 1})
+
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatalf("errors happened when create: %v", err)
+	}
+
+	var associationErr *gorm.AssociationError
+	err := DB.Model(&user).Association("Invalid").Find(&user.Pets)
+	if !errors.As(err, &associationErr) || associationErr.Relation != "Invalid" || associationErr.Operation != "" {
+		t.Errorf("should return association error for invalid relation, but got %#v", err)
+	}
+
+	if !errors.Is(err, gorm.ErrUnsupportedRelation) {
+		t.Errorf("association error should wrap ErrUnsupportedRelation, but got %v", err)
+	}
+
+	err = DB.Model(&user).Association("Pets").Append(&Toy{Name: "association-error-toy"})
+	if !errors.As(err, &associationErr) || associationErr.Relation != "Pets" || associationErr.Operation != "append" {
+		t.Errorf("should return association error for invalid values, but got %#v", err)
+	}
+
+	var users = []User{user, *GetUser("association-error-2", Config{})}
+	err = DB.Model(&users).Association("Pets").Append(&Pet{Name: "association-error-pet"})
+	if !errors.As(err, &associationErr) || associationErr.Relation != "Pets" || associationErr.Operation != "append" {
+		t.Errorf("should return association error for values length mismatch, but got %#v", err)
+	} else if err.Error() != "association Pets append: invalid association values, length doesn't match, expects 2 values but got 1" {
+		t.Errorf("invalid association error message, got %v", err)
+	}
+
+	_, err = DB.Model(&user).Association("Pets").JoinAlias("p").Rows()
+	if !errors.As(err, &associationErr) || associationErr.Relation != "Pets" || associationErr.Operation != "rows" {
+		t.Errorf("should return association error for rows, but got %#v", err)
+	}
+}
+
+func TestAssociationQueryConditions(t *testing.T) {
+	var user = *GetUser("association-query-conditions", Config{Pets: 2, Languages: 2})
+
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatalf("errors happened when create: %v", err)
+	}
+
+	conds, err := DB.Model(&user).Association("Pets").QueryConditions()
+	if err != nil {
+		t.Fatalf("no error should happen when get query conditions, but got %v", err)
+	}
+
+	var pets []Pet
+	if err := DB.Clauses(clause.Where{Exprs: conds}).Find(&pets).Error; err != nil || len(pets) != 2 {
+		t.Errorf("should find two pets with query conditions, got %v, error: %v", len(pets), err)
+	}
+
+	conds, err = DB.Model(&user).Association("Languages").QueryConditions()
+	if err != nil {
+		t.Fatalf("no error should happen when get query conditions, but got %v", err)
+	}
+
+	var languages []Language
+	if err := DB.Clauses(
+		clause.From{Tables: []clause.Table{{Name: "languages"}, {Name: "user_speaks"}}},
+		clause.Where{Exprs: conds},
+	).Find(&languages).Error; err != nil || len(languages) != 2 {
+		t.Errorf("should find two languages with query conditions, got %v, error: %v", len(languages), err)
+	}
+
+	conds, err = DB.Model(&user).Association("Languages").JoinAlias("us").QueryConditions()
+	if err != nil {
+		t.Fatalf("no error should happen when get query conditions with join alias, but got %v", err)
+	}
+
+	languages = nil
+	if err := DB.Clauses(
+		clause.From{Tables: []clause.Table{{Name: "languages"}, {Name: "user_speaks", Alias: "us"}}},
+		clause.Where{Exprs: conds},
+	).Find(&languages).Error; err != nil || len(languages) != 2 {
+		t.Errorf("should find two languages with query conditions of join alias, got %v, error: %v", len(languages), err)
+	}
+
+	if _, err := DB.Model(&user).Association("Invalid").QueryConditions(); err == nil {
+		t.Errorf("should return error for invalid association")
+	}
+}
+
+func TestAssociationSoftDeletedOwner(t *testing.T) {
+	var user = *GetUser("association-soft-deleted-owner", Config{Pets: 1})
+
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatalf("errors happened when create: %v", err)
+	}
+
+	DB.Delete(&user)
+
+	var deletedUser User
+	if err := DB.Unscoped().First(&deletedUser, user.ID).Error; err != nil {
+		t.Fatalf("errors happened when query deleted user: %v", err)
+	}
+
+	if err := DB.Model(&deletedUser).Association("Pets").Append(&Pet{Name: "soft-deleted-owner-pet"}); !errors.Is(err, gorm.ErrDeletedOwner) {
+		t.Errorf("should return error when appending to soft deleted owner, but got %v", err)
+	}
+
+	if err := DB.Model(&deletedUser).Association("Pets").Clear(); err != nil {
+		t.Errorf("no error should happen when clearing associations of soft deleted owner, but got %v", err)
+	}
+
+	if err := DB.Set("gorm:association:allow_deleted_owner", true).Model(&deletedUser).Association("Pets").Append(&Pet{Name: "soft-deleted-owner-pet"}); err != nil {
+		t.Errorf("no error should happen when allowing deleted owner, but got %v", err)
+	}
+
+	AssertAssociationCount(t, deletedUser, "Pets", 1, "after appending to soft deleted owner")
+}
+
+func TestAssociationInTransaction(t *testing.T) {
+	var user = *GetUser("association-in-transaction", Config{Pets: 1, Languages: 1})
+
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatalf("errors happened when create: %v", err)
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Association("Pets").Append(&Pet{Name: "association-in-transaction-pet"}); err != nil {
+			return err
+		}
+
+		if count := tx.Model(&user).Association("Pets").Count(); count != 2 {
+			t.Errorf("appended pet should be visible in transaction, but got %v pets", count)
+		}
+
+		return tx.Model(&user).Association("Languages").Replace(&Pet{Name: "invalid-language"})
+	})
+
+	if err == nil {
+		t.Fatalf("should return error when replacing with invalid values")
+	}
+
+	AssertAssociationCount(t, user, "Pets", 1, "after rolling back transaction")
+	AssertAssociationCount(t, user, "Languages", 1, "after rolling back transaction")
+}
+
+func TestAssociationDryRun(t *testing.T) {
+	var user = *GetUser("association-dry-run", Config{Account: true, Company: true, Pets: 2, Languages: 2})
+
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatalf("errors happened when create: %v", err)
+	}
+
+	var sqls []string
+	captureSQL := func(db *gorm.DB) {
+		sqls = append(sqls, db.Statement.SQL.String())
+	}
+
+	DB.Callback().Query().After("gorm:query").Register("test:capture_dry_run_sql", captureSQL)
+	DB.Callback().Create().After("gorm:create").Register("test:capture_dry_run_sql", captureSQL)
+	DB.Callback().Update().After("gorm:update").Register("test:capture_dry_run_sql", captureSQL)
+	DB.Callback().Delete().After("gorm:delete").Register("test:capture_dry_run_sql", captureSQL)
+	defer func() {
+		DB.Callback().Query().Remove("test:capture_dry_run_sql")
+		DB.Callback().Create().Remove("test:capture_dry_run_sql")
+		DB.Callback().Update().Remove("test:capture_dry_run_sql")
+		DB.Callback().Delete().Remove("test:capture_dry_run_sql")
+	}()
+
+	dryRunDB := DB.Session(&gorm.Session{DryRun: true})
+	assertSQL := func(name string, fc func(association *gorm.Association) error, patterns ...string) {
+		sqls = nil
+		if err := fc(dryRunDB.Model(&user).Association(name)); err != nil {
+			t.Errorf("no error should happen for %v in dry run mode, but got %v", name, err)
+		}
+
+		for _, pattern := range patterns {
+			matched := false
+			for _, sql := range sqls {
+				if regexp.MustCompile(pattern).MatchString(sql) {
+					matched = true
+				}
+			}
+
+			if !matched {
+				t.Errorf("%v should generate sql matches %v, but got %v", name, pattern, sqls)
+			}
+		}
+	}
+
+	for name, table := range map[string]string{"Account": "accounts", "Company": "companies", "Pets": "pets", "Languages": "languages"} {
+		assertSQL(name, func(association *gorm.Association) error {
+			var count int64
+			count, err := association.CountI64()
+			if count != 0 {
+				t.Errorf("count should be zero in dry run mode, but got %v", count)
+			}
+			return err
+		}, "SELECT count\\(1\\) FROM ."+table+".")
+	}
+
+	assertSQL("Pets", func(association *gorm.Association) error {
+		return association.Find(&[]Pet{})
+	}, "SELECT \\* FROM .pets. WHERE .pets.\\..user_id. = ")
+
+	assertSQL("Languages", func(association *gorm.Association) error {
+		return association.Find(&[]Language{})
+	}, "SELECT .languages.\\.\\* FROM .languages. JOIN .user_speaks. ON .user_speaks.\\..language_code. = .languages.\\..code.")
+
+	assertSQL("Account", func(association *gorm.Association) error {
+		return association.Append(&Account{Number: "association-dry-run"})
+	}, "INSERT INTO .accounts.", "UPDATE .accounts. SET .user_id.=")
+
+	assertSQL("Company", func(association *gorm.Association) error {
+		return association.Replace(&Company{Name: "association-dry-run"})
+	}, "INSERT INTO .companies.", "UPDATE .users.")
+
+	assertSQL("Pets", func(association *gorm.Association) error {
+		return association.Replace(&Pet{Name: "association-dry-run"})
+	}, "INSERT INTO .pets.", "UPDATE .pets. SET .user_id.=")
+
+	assertSQL("Languages", func(association *gorm.Association) error {
+		return association.Append(&Language{Code: "association-dry-run", Name: "association-dry-run"})
+	}, "INSERT INTO .languages.", "INSERT INTO .user_speaks.")
+
+	assertSQL("Languages", func(association *gorm.Association) error {
+		return association.Delete(user.Languages[0])
+	}, "DELETE FROM .user_speaks.")
+
+	assertSQL("Pets", func(association *gorm.Association) error {
+		return association.Delete(user.Pets[0])
+	}, "UPDATE .pets. SET .user_id.=\\? WHERE .pets.\\..user_id. = \\? AND .pets.\\..id. = \\?")
+
+	if len(user.Pets) != 2 || len(user.Languages) != 2 || user.Account.Number == "association-dry-run" || user.Company.Name == "association-dry-run" {
+		t.Errorf("user's associations should not be changed in dry run mode, but got %+v", user)
+	}
+
+	var result User
+	DB.Preload("Account").Preload("Company").Preload("Pets").Preload("Languages").First(&result, user.ID)
+	if result.Account.ID != user.Account.ID || *result.CompanyID != *user.CompanyID || len(result.Pets) != 2 || len(result.Languages) != 2 {
+		t.Errorf("database should not be changed in dry run mode, but got %+v", result)
+	}
+}
+
+func TestNestedAssociation(t *testing.T) {
+	type HopDepartment struct {
+		ID           uint
+		Name         string
+		HopCompanyID uint
+	}
+
+	type HopCompany struct {
+		ID          uint
+		Name        string
+		Departments []HopDepartment
+	}
+
+	type HopUser struct {
+		ID           uint
+		Name         string
+		HopCompanyID *uint
+		Company      HopCompany `gorm:"foreignKey:HopCompanyID"`
+	}
+
+	DB.Migrator().DropTable(&HopUser{}, &HopCompany{}, &HopDepartment{})
+	if err := DB.AutoMigrate(&HopCompany{}, &HopDepartment{}, &HopUser{}); err != nil {
+		t.Fatalf("Failed to migrate, got %v", err)
+	}
+
+	user := HopUser{Name: "nested", Company: HopCompany{Name: "company", Departments: []HopDepartment{{Name: "sales"}, {Name: "support"}}}}
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatalf("Failed to create user, got error %v", err)
+	}
+	DB.Create(&HopCompany{Name: "other", Departments: []HopDepartment{{Name: "other"}}})
+
+	var departments []HopDepartment
+	if err := DB.Model(&user).Association("Company.Departments").Find(&departments, "name <> ?", "support"); err != nil {
+		t.Fatalf("failed to find nested associations, got error %v", err)
+	}
+
+	if len(departments) != 1 || departments[0].Name != "sales" {
+		t.Errorf("should find company's departments, but got %+v", departments)
+	}
+
+	if count := DB.Model(&user).Association("Company.Departments").Count(); count != 2 {
+		t.Errorf("should count two departments, but got %v", count)
+	}
+
+	if err := DB.Model(&user).Association("Company.Departments").Append(&HopDepartment{Name: "hr"}); err != nil {
+		t.Fatalf("failed to append nested associations, got error %v", err)
+	}
+
+	if count := DB.Model(&HopCompany{ID: user.Company.ID}).Association("Departments").Count(); count != 3 {
+		t.Errorf("should append department to user's company, but got %v departments", count)
+	}
+
+	if err := DB.Model(&HopCompany{}).Association("Departments.Name").Error; !errors.Is(err, gorm.ErrUnsupportedRelation) {
+		t.Errorf("should return error for has many intermediate relation, but got %v", err)
+	}
+
+	if err := DB.Model(&user).Association("Company.Unknown").Error; !errors.Is(err, gorm.ErrUnsupportedRelation) {
+		t.Errorf("should return error for unknown nested relation, but got %v", err)
+	}
+
+	noCompany := HopUser{Name: "no company"}
+	if count := DB.Model(&noCompany).Association("Company.Departments").Count(); count != 0 {
+		t.Errorf("should count no departments without intermediate record, but got %v", count)
+	}
+
+	if err := DB.Model(&noCompany).Association("Company.Departments").Append(&HopDepartment{Name: "hr"}); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("should return error when intermediate record not found, but got %v", err)
+	}
+
+	// intermediate records are selected by subqueries when querying, nothing is queried when building the association
+	var queries int
+	DB.Callback().Query().Before("gorm:query").Register("test:nested_association_queries", func(db *gorm.DB) {
+		queries++
+	})
+	defer DB.Callback().Query().Remove("test:nested_association_queries")
+
+	association := DB.Model(&user).Association("Company.Departments")
+	conds, err := association.QueryConditions()
+	if err != nil || queries != 0 {
+		t.Fatalf("should build nested association without queries, but got %v queries, error %v", queries, err)
+	}
+
+	departments = nil
+	if err := DB.Clauses(clause.Where{Exprs: conds}).Find(&departments).Error; err != nil || len(departments) != 3 {
+		t.Errorf("should find departments with query conditions, but got %+v, error %v", departments, err)
+	}
+}
+
+func TestAssociationWithInvalidOwner(t *testing.T) {
+	if err := DB.Association("Pets").Error; !errors.Is(err, gorm.ErrModelValueRequired) {
+		t.Errorf("should return error when model is not set, but got %v", err)
+	}
+
+	var pets []Pet
+	if err := DB.Model((*User)(nil)).Association("Pets").Find(&pets); !errors.Is(err, gorm.ErrModelValueRequired) {
+		t.Errorf("should return error when model is nil pointer, but got %v", err)
+	}
+
+	var user = *GetUser("invalid-owner", Config{Pets: 2})
+	DB.Create(&user)
+
+	if err := DB.Model(user).Association("Pets").Find(&pets); err != nil || len(pets) != 2 {
+		t.Errorf("should find pets with non-pointer model, got %v, error: %v", len(pets), err)
+	}
+
+	if err := DB.Model(user).Association("Pets").Append(&Pet{Name: "invalid-owner-pet"}); !errors.Is(err, gorm.ErrInvalidData) {
+		t.Errorf("should return error when appending to non-pointer model, but got %v", err)
+	}
+
+	if err := DB.Model(user).Association("Pets").Delete(&pets[0]); !errors.Is(err, gorm.ErrInvalidData) {
+		t.Errorf("should return error when deleting from non-pointer model, but got %v", err)
+	}
+
+	AssertAssociationCount(t, user, "Pets", 2, "after invalid operations")
+}
+
+func TestAssociationIsCollection(t *testing.T) {
+	var user = *GetUser("is-collection", Config{Pets: 1})
+	DB.Create(&user)
+
+	for column, isCollection := range map[string]bool{"Pets": true, "Languages": true, "Account": false, "Company": false} {
+		if association := DB.Model(&user).Association(column); association.IsCollection() != isCollection {
+			t.Errorf("%v's IsCollection should be %v", column, isCollection)
+		}
+	}
+
+	if DB.Model(&user).Association("Invalid").IsCollection() {
+		t.Errorf("invalid association shouldn't be a collection")
+	}
+
+	var pet Pet
+	err := DB.Model(&user).Association("Pets").Find(&pet)
+	if !errors.Is(err, gorm.ErrInvalidData) || !strings.Contains(err.Error(), "collection") {
+		t.Errorf("should return error when finding collection with struct, but got %v", err)
+	}
+
+	var company Company
+	if err := DB.Model(&user).Association("Company").Find(&company); err != nil {
+		t.Errorf("should find single association with struct, but got %v", err)
+	}
+}
+
+func TestAssociationCallbacks(t *testing.T) {
+	var user = *GetUser("association-callbacks", Config{Pets: 2})
+	DB.Create(&user)
+
+	var operations []gorm.AssociationOperation
+	var errs []error
+	DB.Callback().Association().Register("test:association_operations", func(db *gorm.DB) {
+		if operation, ok := db.Get("gorm:association:operation"); ok {
+			operations = append(operations, operation.(gorm.AssociationOperation))
+			errs = append(errs, db.Error)
+		}
+	})
+	defer DB.Callback().Association().Remove("test:association_operations")
+
+	var pets []Pet
+	if err := DB.Model(&user).Association("Pets").Find(&pets); err != nil {
+		t.Fatalf("failed to find pets, got error %v", err)
+	}
+
+	expected := gorm.AssociationOperation{Relation: "Pets", Type: schema.HasMany, Operation: "find"}
+	if len(operations) != 1 || operations[0] != expected || errs[0] != nil {
+		t.Errorf("callback should be called once for find, but got %+v, errors: %v", operations, errs)
+	}
+
+	operations, errs = nil, nil
+	DB.Model(&user).Association("Languages").Count()
+	DB.Model(&user).Association("Pets").Append(&Pet{Name: "association-callbacks-pet"})
+	if len(operations) != 2 || operations[0].Operation != "count" || operations[0].Type != schema.Many2Many || operations[1].Operation != "append" {
+		t.Errorf("callback should be called for each operation, but got %+v", operations)
+	}
+
+	operations, errs = nil, nil
+	if err := DB.Model(&user).Association("Pets").Find(&Pet{}); err == nil || len(errs) != 1 || !errors.Is(errs[0], gorm.ErrInvalidData) {
+		t.Errorf("callback should be called with operation's error, but got %v", errs)
+	}
+}
+
+func TestAssociationAppendWithoutOwnerUpdate(t *testing.T) {
+	var user = *GetUser("append-without-owner-update", Config{})
+	DB.Create(&user)
+
+	var updates []string
+	DB.Callback().Update().After("gorm:update").Register("test:owner_updates", func(db *gorm.DB) {
+		if db.Statement.Table == "users" && db.Statement.SQL.Len() > 0 {
+			updates = append(updates, db.Statement.SQL.String())
+		}
+	})
+	defer DB.Callback().Update().Remove("test:owner_updates")
+
+	if err := DB.Model(&user).Association("Pets").Append(&Pet{Name: "append-without-owner-update"}); err != nil {
+		t.Fatalf("failed to append pets, got error %v", err)
+	}
+
+	if err := DB.Model(&user).Association("Languages").Append(&Language{Code: "append-without-owner-update", Name: "append"}); err != nil {
+		t.Fatalf("failed to append languages, got error %v", err)
+	}
+
+	if len(updates) != 0 {
+		t.Errorf("should not update owner when appending has many, many2many associations, but got %v", updates)
+	}
+
+	if err := DB.Model(&user).Association("Company").Append(&Company{Name: "append-without-owner-update"}); err != nil {
+		t.Fatalf("failed to append company, got error %v", err)
+	}
+
+	if len(updates) != 1 || !strings.Contains(updates[0], "company_id") || strings.Contains(updates[0], "updated_at") {
+		t.Errorf("should only update owner's foreign key for belongs to association, but got %v", updates)
+	}
+}
+
+func TestAssociationAppendSkipOwnerSave(t *testing.T) {
+	var (
+		user  = *GetUser("append-skip-owner-save", Config{})
+		other = *GetUser("append-skip-owner-save-other", Config{Pets: 1})
+	)
+	DB.Create(&user)
+	DB.Create(&other)
+
+	var ownerSaves int
+	DB.Callback().Update().Before("gorm:update").Register("test:owner_saves", func(db *gorm.DB) {
+		if db.Statement.Table == "users" {
+			ownerSaves++
+		}
+	})
+	defer DB.Callback().Update().Remove("test:owner_saves")
+
+	tx := DB.Set("gorm:association:skip_owner_save", true)
+	pets := []*Pet{{Name: "append-skip-owner-save"}, other.Pets[0]}
+	if err := tx.Model(&user).Association("Pets").Append(pets); err != nil {
+		t.Fatalf("failed to append pets, got error %v", err)
+	}
+
+	if ownerSaves != 0 {
+		t.Errorf("owner shouldn't be saved when appending has many associations, but saved %v times", ownerSaves)
+	}
+
+	for _, pet := range pets {
+		if pet.ID == 0 || pet.UserID == nil || *pet.UserID != user.ID {
+			t.Errorf("pet should be linked to the owner, but got %+v", pet)
+		}
+	}
+	AssertAssociationCount(t, user, "Pets", 2, "after append skipping owner save")
+	AssertAssociationCount(t, other, "Pets", 0, "after moving pet to another owner")
+
+	// owners without primary keys are still created
+	newUser := *GetUser("append-skip-owner-save-new", Config{})
+	if err := tx.Model(&newUser).Association("Pets").Append(&Pet{Name: "append-skip-owner-save-new"}); err != nil {
+		t.Fatalf("failed to append pets to new owner, got error %v", err)
+	}
+
+	if newUser.ID == 0 {
+		t.Fatalf("owner without primary key should be created")
+	}
+	AssertAssociationCount(t, newUser, "Pets", 1, "after append to new owner")
+}
+
+func TestReplaceAllAssociations(t *testing.T) {
+	type AggregateProfile struct {
+		ID              uint
+		AggregateUserID uint
+		Bio             string
+	}
+
+	type AggregateOrder struct {
+		ID              uint
+		AggregateUserID *uint
+		Amount          int
+	}
+
+	type AggregateRole struct {
+		ID   uint
+		Name string
+	}
+
+	type AggregateUser struct {
+		ID      uint
+		Name    string
+		Profile AggregateProfile
+		Orders  []AggregateOrder
+		Roles   []AggregateRole `gorm:"many2many:aggregate_user_roles"`
+	}
+
+	DB.Migrator().DropTable(&AggregateProfile{}, &AggregateOrder{}, &AggregateRole{}, "aggregate_user_roles", &AggregateUser{})
+	if err := DB.AutoMigrate(&AggregateUser{}, &AggregateProfile{}, &AggregateOrder{}, &AggregateRole{}); err != nil {
+		t.Fatalf("failed to migrate, got error %v", err)
+	}
+
+	user := AggregateUser{
+		Name:    "replace-all",
+		Profile: AggregateProfile{Bio: "old"},
+		Orders:  []AggregateOrder{{Amount: 1}, {Amount: 2}},
+		Roles:   []AggregateRole{{Name: "admin"}},
+	}
+	DB.Create(&user)
+
+	user.Profile = AggregateProfile{Bio: "new"}
+	user.Orders = []AggregateOrder{user.Orders[1], {Amount: 3}}
+	user.Roles = []AggregateRole{{Name: "editor"}, {Name: "viewer"}}
+
+	if err := DB.ReplaceAllAssociations(&user); err != nil {
+		t.Fatalf("failed to replace all associations, got error %v", err)
+	}
+
+	var result AggregateUser
+	if err := DB.Preload("Profile").Preload("Orders").Preload("Roles").First(&result, user.ID).Error; err != nil {
+		t.Fatalf("failed to find user, got error %v", err)
+	}
+
+	if result.Profile.Bio != "new" || result.Profile.ID != user.Profile.ID {
+		t.Errorf("profile should be replaced, got %+v", result.Profile)
+	}
+
+	if len(result.Orders) != 2 || result.Orders[0].Amount+result.Orders[1].Amount != 5 {
+		t.Errorf("orders should be replaced, got %+v", result.Orders)
+	}
+
+	if len(result.Roles) != 2 || result.Roles[0].Name+result.Roles[1].Name != "editorviewer" {
+		t.Errorf("roles should be replaced, got %+v", result.Roles)
+	}
+
+	var profiles, orphanOrders int64
+	DB.Model(&AggregateProfile{}).Where("aggregate_user_id = ?", user.ID).Count(&profiles)
+	DB.Model(&AggregateOrder{}).Where("aggregate_user_id IS NULL").Count(&orphanOrders)
+	if profiles != 1 || orphanOrders != 1 {
+		t.Errorf("previous associations should be detached, got %v profiles, %v orphan orders", profiles, orphanOrders)
+	}
+
+	// empty fields clear associations
+	user.Orders, user.Roles = nil, nil
+	if err := DB.ReplaceAllAssociations(&user); err != nil {
+		t.Fatalf("failed to clear associations, got error %v", err)
+	}
+
+	AssertAssociationCount(t, &user, "Orders", 0, "after clear")
+	AssertAssociationCount(t, &user, "Roles", 0, "after clear")
+	AssertAssociationCount(t, &user, "Profile", 1, "after clear")
+
+	if err := DB.ReplaceAllAssociations(user); !errors.Is(err, gorm.ErrInvalidData) {
+		t.Errorf("should return error for non pointer model, got %v", err)
+	}
+}
+
+type tagLogWriter struct {
+	logs []string
+}
+
+func (w *tagLogWriter) Printf(format string, args ...interface{}) {
+	w.logs = append(w.logs, fmt.Sprintf(format, args...))
+}
+
+func TestAssociationLogTags(t *testing.T) {
+	user := *GetUser("log-tags", Config{Pets: 2})
+	DB.Create(&user)
+
+	writer := &tagLogWriter{}
+	tx := DB.Session(&gorm.Session{Logger: logger.New(writer, logger.Config{LogLevel: logger.Info})})
+
+	var pets []Pet
+	if err := tx.Model(&user).Association("Pets").Find(&pets); err != nil {
+		t.Fatalf("failed to find pets, got error %v", err)
+	}
+
+	if err := tx.Model(&user).Association("Pets").Append(&Pet{Name: "log-tags-pet"}); err != nil {
+		t.Fatalf("failed to append pet, got error %v", err)
+	}
+
+	logs := strings.Join(writer.logs, "\n")
+	for _, tag := range []string{"[association Pets find] SELECT", "[association Pets append] INSERT"} {
+		if !strings.Contains(logs, tag) {
+			t.Errorf("logs should contain %q, got %v", tag, logs)
+		}
+	}
+
+	association := tx.Model(&user).Association("Pets")
+	if association.Count(); logger.TagFromContext(association.DB.Statement.Context) != "" {
+		t.Errorf("tags should be set on sessions of the operation, got %v", logger.TagFromContext(association.DB.Statement.Context))
+	}
+
+	writer.logs = nil
+	tx.First(&User{}, user.ID)
+	if logs := strings.Join(writer.logs, "\n"); strings.Contains(logs, "[association") {
+		t.Errorf("queries outside association mode should not be tagged, got %v", logs)
+	}
+}
+
+func TestAssociationWithInterfaceOwners(t *testing.T) {
+	user1 := *GetUser("interface-owners-1", Config{Pets: 1})
+	user2 := *GetUser("interface-owners-2", Config{Pets: 2})
+	DB.Create(&user1)
+	DB.Create(&user2)
+
+	err := DB.Model(&[]interface{}{&user1, &Pet{}}).Association("Pets").Append(&Pet{Name: "mixed-1"}, &Pet{Name: "mixed-2"})
+	if !errors.Is(err, gorm.ErrInvalidData) {
+		t.Fatalf("should return ErrInvalidData for owners of different types, got %v", err)
+	}
+
+	if !strings.Contains(err.Error(), "*tests.User at index 0 and *tests.Pet at index 1") {
+		t.Errorf("error should describe types of owners, got %v", err)
+	}
+
+	if err := DB.Model(&[]interface{}{&user1, nil}).Association("Pets").Error; !errors.Is(err, gorm.ErrInvalidData) {
+		t.Errorf("should return ErrInvalidData for nil owners, got %v", err)
+	}
+
+	owners := []interface{}{&user1, &user2}
+	if count := DB.Model(&owners).Association("Pets").Count(); count != 3 {
+		t.Errorf("invalid pets count of owners, expects: %v, got %v", 3, count)
+	}
+
+	if err := DB.Model(&owners).Association("Pets").Append(&Pet{Name: "interface-owners-1"}, &Pet{Name: "interface-owners-2"}); err != nil {
+		t.Fatalf("failed to append pets to owners, got error %v", err)
+	}
+
+	if len(user1.Pets) != 2 || len(user2.Pets) != 3 {
+		t.Errorf("appended pets should be assigned to owners, got %v, %v", len(user1.Pets), len(user2.Pets))
+	}
+
+	AssertAssociationCount(t, &user1, "Pets", 2, "after append to interface owners")
+	AssertAssociationCount(t, &user2, "Pets", 3, "after append to interface owners")
+}
+
+func TestAssociationOperationsWithSameAssociation(t *testing.T) {
+	user := *GetUser("same-association", Config{Pets: 3, Languages: 2})
+	DB.Create(&user)
+
+	pets := DB.Model(&user).Association("Pets")
+
+	var found []Pet
+	if err := pets.Find(&found, "name <> ?", user.Pets[0].Name); err != nil || len(found) != 2 {
+		t.Fatalf("failed to find pets, expects: %v, got %v, error %v", 2, len(found), err)
+	}
+
+	if count := pets.Count(); count != 3 {
+		t.Errorf("conditions of Find shouldn't leak into Count, expects: %v, got %v", 3, count)
+	}
+
+	var pet Pet
+	if err := pets.First(&pet); err != nil || pet.ID != user.Pets[0].ID {
+		t.Errorf("first pet should be found after counting, got %+v, error %v", pet, err)
+	}
+
+	if count := pets.Count(); count != 3 {
+		t.Errorf("conditions of First shouldn't leak into Count, expects: %v, got %v", 3, count)
+	}
+
+	languages := DB.Model(&user).Association("Languages")
+
+	var foundLanguages []Language
+	if err := languages.Find(&foundLanguages); err != nil || len(foundLanguages) != 2 {
+		t.Fatalf("failed to find languages, expects: %v, got %v, error %v", 2, len(foundLanguages), err)
+	}
+
+	if count := languages.Count(); count != 2 {
+		t.Errorf("languages should be counted after finding, expects: %v, got %v", 2, count)
+	}
+
+	if err := languages.Delete(&user.Languages[0]); err != nil {
+		t.Fatalf("failed to delete language, got error %v", err)
+	}
+
+	if count := languages.Count(); count != 1 {
+		t.Errorf("languages should be counted after deleting, expects: %v, got %v", 1, count)
+	}
+}
+
+type replicaConnPool struct {
+	gorm.ConnPool
+	queries *[]string
+}
+
+func (pool replicaConnPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	*pool.queries = append(*pool.queries, query)
+	return pool.ConnPool.QueryContext(ctx, query, args...)
+}
+
+func (pool replicaConnPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	*pool.queries = append(*pool.queries, query)
+	return pool.ConnPool.ExecContext(ctx, query, args...)
+}
+
+func TestAssociationResolverHints(t *testing.T) {
+	user := *GetUser("resolver-hints", Config{Pets: 2})
+	DB.Create(&user)
+
+	var replicaQueries []string
+	var writesToReplica []string
+	resolve := func(db *gorm.DB) {
+		if _, ok := db.Statement.Clauses[gorm.ResolverReadHint]; ok {
+			db.Statement.ConnPool = replicaConnPool{ConnPool: db.Statement.ConnPool, queries: &replicaQueries}
+		}
+	}
+	checkWrite := func(db *gorm.DB) {
+		if _, ok := db.Statement.Clauses[gorm.ResolverReadHint]; ok {
+			writesToReplica = append(writesToReplica, db.Statement.Table)
+		}
+	}
+
+	DB.Callback().Query().Before("gorm:query").Register("test:stub_resolver", resolve)
+	DB.Callback().Row().Before("gorm:row").Register("test:stub_resolver", resolve)
+	DB.Callback().Create().Before("gorm:create").Register("test:stub_resolver", checkWrite)
+	DB.Callback().Update().Before("gorm:update").Register("test:stub_resolver", checkWrite)
+	defer func() {
+		DB.Callback().Query().Remove("test:stub_resolver")
+		DB.Callback().Row().Remove("test:stub_resolver")
+		DB.Callback().Create().Remove("test:stub_resolver")
+		DB.Callback().Update().Remove("test:stub_resolver")
+	}()
+
+	association := DB.Model(&user).Association("Pets")
+
+	var pets []Pet
+	if err := association.Find(&pets); err != nil || len(pets) != 2 {
+		t.Fatalf("failed to find pets, got %v, error %v", len(pets), err)
+	}
+
+	if len(replicaQueries) != 1 || !strings.Contains(replicaQueries[0], "pets") {
+		t.Fatalf("find should read from the replica, got %v", replicaQueries)
+	}
+
+	if count := association.Count(); count != 2 || len(replicaQueries) != 2 {
+		t.Errorf("count should read from the replica, got count %v, queries %v", count, replicaQueries)
+	}
+
+	if exists, err := association.Exists(); err != nil || !exists || len(replicaQueries) != 3 {
+		t.Errorf("exists should read from the replica, got %v, error %v, queries %v", exists, err, replicaQueries)
+	}
+
+	pet := Pet{Name: "resolver-hints-pet"}
+	if err := association.Append(&pet); err != nil {
+		t.Fatalf("failed to append pet, got error %v", err)
+	}
+
+	if len(writesToReplica) != 0 || len(replicaQueries) != 3 {
+		t.Errorf("append after reads shouldn't go to the replica, got writes %v, queries %v", writesToReplica, replicaQueries)
+	}
+
+	if count := association.Count(); count != 3 || len(replicaQueries) != 4 {
+		t.Errorf("count after append should read from the replica, got count %v, queries %v", count, replicaQueries)
+	}
+}
+
+// countWrites count update & delete statements executed by fn
+func countWrites(fn func()) (count int) {
+	counter := func(*gorm.DB) { count++ }
+	DB.Callback().Update().Before("gorm:update").Register("test:count_writes", counter)
+	DB.Callback().Delete().Before("gorm:delete").Register("test:count_writes", counter)
+	defer func() {
+		DB.Callback().Update().Remove("test:count_writes")
+		DB.Callback().Delete().Remove("test:count_writes")
+	}()
+
+	fn()
+	return
+}
+
+func TestAssociationIndexHint(t *testing.T) {
+	mysqlDB, err := gorm.Open(mysql.New(mysql.Config{DSN: "gorm:gorm@tcp(localhost:9910)/gorm", SkipInitializeWithVersion: true}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatalf("failed to open mysql dry run db, got error %v", err)
+	}
+
+	var sqls []string
+	mysqlDB.Callback().Query().After("gorm:query").Register("test:capture_sql", func(db *gorm.DB) {
+		sqls = append(sqls, db.Statement.SQL.String())
+	})
+
+	user := User{Name: "index-hint"}
+	user.ID = 1
+
+	var pets []Pet
+	if err := mysqlDB.Model(&user).Association("Pets").IndexHint("idx_pets_user_id").Find(&pets); err != nil {
+		t.Fatalf("failed to find pets, got error %v", err)
+	}
+
+	if len(sqls) != 1 || !regexp.MustCompile("FROM `pets` USE INDEX \\(`idx_pets_user_id`\\) WHERE").MatchString(sqls[0]) {
+		t.Errorf("index hint should be applied to the associations's table, got %v", sqls)
+	}
+
+	var languages []Language
+	association := mysqlDB.Model(&user).Association("Languages").IndexHint("idx_user_speaks_user_id", "idx_user_speaks_language_code")
+	if err := association.Find(&languages); err != nil {
+		t.Fatalf("failed to find languages, got error %v", err)
+	}
+
+	if count := association.Count(); count != 0 || association.Error != nil {
+		t.Fatalf("failed to count languages, got error %v", association.Error)
+	}
+
+	for _, sql := range sqls[1:] {
+		if !regexp.MustCompile("JOIN `user_speaks` USE INDEX \\(`idx_user_speaks_user_id`,`idx_user_speaks_language_code`\\) ON").MatchString(sql) {
+			t.Errorf("index hint should be applied to the join table, got %v", sql)
+		}
+	}
+
+	if len(sqls) != 3 {
+		t.Errorf("languages should be found and counted, got %v", sqls)
+	}
+
+	// sqlite doesn't support index hints, queries would fail if the hint isn't ignored
+	saved := *GetUser("index-hint", Config{Pets: 2})
+	DB.Create(&saved)
+	if err := DB.Model(&saved).Association("Pets").IndexHint("idx_pets_user_id").Find(&pets); err != nil || len(pets) != 2 {
+		t.Errorf("index hint should be ignored by dialects without index hints, got %v, error %v", len(pets), err)
+	}
+}
+
+func TestAssociationSkipInMemoryAssociationUpdate(t *testing.T) {
+	user := *GetUser("skip-in-memory", Config{Pets: 2, Company: true})
+	DB.Create(&user)
+
+	var (
+		tx         = DB.Session(&gorm.Session{SkipInMemoryAssociationUpdate: true})
+		pets       = user.Pets
+		firstPet   = user.Pets[0]
+		companyID  = *user.CompanyID
+		appendPet  = Pet{Name: "skip-in-memory-append"}
+		newCompany = Company{Name: "skip-in-memory-company"}
+	)
+
+	if err := tx.Model(&user).Association("Pets").Append(&appendPet); err != nil {
+		t.Fatalf("failed to append pet, got error %v", err)
+	}
+
+	if len(user.Pets) != 2 || &user.Pets[0] != &pets[0] || user.Pets[0] != firstPet {
+		t.Errorf("owner's pets should be unchanged after append, got %+v", user.Pets)
+	}
+
+	if appendPet.ID == 0 {
+		t.Errorf("appended pet should be created")
+	}
+	AssertAssociationCount(t, user, "Pets", 3, "after append skipping in-memory update")
+
+	if err := tx.Model(&user).Association("Pets").Delete(firstPet); err != nil {
+		t.Fatalf("failed to delete pet, got error %v", err)
+	}
+
+	if len(user.Pets) != 2 || user.Pets[0] != firstPet {
+		t.Errorf("owner's pets should be unchanged after delete, got %+v", user.Pets)
+	}
+	AssertAssociationCount(t, user, "Pets", 2, "after delete skipping in-memory update")
+
+	if err := tx.Model(&user).Association("Company").Replace(&newCompany); err != nil {
+		t.Fatalf("failed to replace company, got error %v", err)
+	}
+
+	if user.CompanyID == nil || *user.CompanyID != companyID || user.Company.ID != companyID {
+		t.Errorf("owner's company should be unchanged after replace, got %v, %+v", user.CompanyID, user.Company)
+	}
+
+	var result User
+	DB.First(&result, user.ID)
+	if result.CompanyID == nil || *result.CompanyID != newCompany.ID {
+		t.Errorf("company should be replaced in database, got %v", result.CompanyID)
+	}
+}
+
+func TestAssociationLinkCallbacks(t *testing.T) {
+	type TenantPet struct {
+		ID           uint
+		TenantID     uint
+		TenantUserID uint
+		Name         string
+	}
+
+	type TenantUser struct {
+		ID       uint
+		TenantID uint
+		Name     string
+		Pets     []TenantPet
+	}
+
+	DB.Migrator().DropTable(&TenantPet{}, &TenantUser{})
+	if err := DB.AutoMigrate(&TenantUser{}, &TenantPet{}); err != nil {
+		t.Fatalf("failed to migrate, got error %v", err)
+	}
+
+	errCrossTenant := errors.New("cross tenant link")
+	DB.Callback().AssociationLink().Register("test:tenant", func(db *gorm.DB) {
+		link, ok := db.Get("gorm:association:link")
+		if !ok {
+			db.AddError(errors.New("link should be set"))
+			return
+		}
+
+		var (
+			owner = link.(gorm.AssociationLink).Owner.FieldByName("TenantID")
+			value = link.(gorm.AssociationLink).Value.FieldByName("TenantID")
+		)
+
+		if value.Uint() == 0 {
+			value.SetUint(owner.Uint())
+		} else if value.Uint() != owner.Uint() {
+			db.AddError(errCrossTenant)
+		}
+	})
+	defer DB.Callback().AssociationLink().Remove("test:tenant")
+
+	user := TenantUser{TenantID: 1, Name: "tenant"}
+	DB.Create(&user)
+
+	if err := DB.Model(&user).Association("Pets").Append(&TenantPet{Name: "same-tenant", TenantID: 1}, &TenantPet{Name: "no-tenant"}); err != nil {
+		t.Fatalf("failed to append pets of the same tenant, got error %v", err)
+	}
+
+	if len(user.Pets) != 2 || user.Pets[1].TenantID != 1 {
+		t.Errorf("tenant should be assigned to pets by the callback, got %+v", user.Pets)
+	}
+
+	crossTenant := TenantPet{Name: "cross-tenant", TenantID: 2}
+	if err := DB.Model(&user).Association("Pets").Append(&crossTenant); !errors.Is(err, errCrossTenant) {
+		t.Errorf("appending pets of other tenants should be rejected, got error %v", err)
+	}
+
+	if len(user.Pets) != 2 || crossTenant.ID != 0 {
+		t.Errorf("rejected pet shouldn't be appended, got %+v, %+v", user.Pets, crossTenant)
+	}
+
+	var pets []TenantPet
+	DB.Where("tenant_user_id = ?", user.ID).Order("id").Find(&pets)
+	if len(pets) != 2 || pets[0].TenantID != 1 || pets[1].TenantID != 1 {
+		t.Errorf("only pets of the same tenant should be saved, got %+v", pets)
+	}
+}
+
+func TestAssociationUse(t *testing.T) {
+	user := *GetUser("association-use", Config{Account: true, Pets: 2, Toys: 3, Company: true, Manager: true, Team: 1, Languages: 2, Friends: 1})
+	DB.Create(&user)
+
+	association := DB.Model(&user).Association("Account")
+	if association.Error != nil {
+		t.Fatalf("failed to build association, got error %v", association.Error)
+	}
+
+	s := association.DB.Statement.Schema
+	for name, rel := range s.Relationships.Relations {
+		if rel.Schema != s || rel.Type == schema.Many2ManyJSON {
+			continue
+		}
+
+		if reused := association.Use(name); reused != association || association.Relationship != rel || association.Error != nil {
+			t.Fatalf("association should be switched to %v in place, got %v, %v", name, association.Relationship, association.Error)
+		}
+
+		if count, expects := association.Count(), DB.Model(&user).Association(name).Count(); count != expects || count == 0 {
+			t.Errorf("invalid %v count with reused association, expects %v, got %v", name, expects, count)
+		}
+	}
+
+	if err := association.Use("Invalid").Error; !errors.Is(err, gorm.ErrUnsupportedRelation) {
+		t.Errorf("should return ErrUnsupportedRelation for invalid relation, got %v", err)
+	}
+
+	// the error of the previous relation is reset
+	if count := association.Use("Languages").Count(); association.Error != nil || count != 2 {
+		t.Errorf("reused association should be reset, got %v, %v", count, association.Error)
+	}
+}
@@ -3,6 +3,7 @@ package tests_test
 import (
 	"testing"
 
+	"gorm.io/gorm"
 	. "gorm.io/gorm/utils/tests"
 )
 
@@ -42,3 +43,93 @@ func BenchmarkDelete(b *testing.B) {
 		DB.Delete(&user)
 	}
 }
+
+func BenchmarkAssociationAppendForSlice(b *testing.B) {
+	tx := DB.Session(&gorm.Session{PrepareStmt: true})
+
+	var users []User
+	for i := 0; i < 1000; i++ {
+		users = append(users, *GetUser("bench-append", Config{}))
+	}
+	tx.Create(&users)
+
+	b.ResetTimer()
+	for x := 0; x < b.N; x++ {
+		pets := make([]interface{}, len(users))
+		for i := range pets {
+			pets[i] = &Pet{Name: "bench-append-pet"}
+		}
+		tx.Model(&users).Association("Pets").Append(pets...)
+	}
+}
+
+func BenchmarkAssociationCount(b *testing.B) {
+	var user = *GetUser("bench-count", Config{Pets: 3, Languages: 3})
+	DB.Create(&user)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for x := 0; x < b.N; x++ {
+		DB.Model(&user).Association("Pets").Count()
+		DB.Model(&user).Association("Languages").Count()
+	}
+}
+
+type BenchReplaceTag struct {
+	ID   uint
+	Name string
+}
+
+type BenchReplacePost struct {
+	ID   uint
+	Tags []BenchReplaceTag `gorm:"many2many:bench_replace_post_tags"`
+}
+
+// prepareMany2ManyReplace creates a post linked to 50k tags, replacing its tags with the returned values keeps all but
+// the first 100 tags and links 100 new tags
+func prepareMany2ManyReplace(b *testing.B) (BenchReplacePost, []BenchReplaceTag) {
+	DB.Migrator().DropTable(&BenchReplacePost{}, &BenchReplaceTag{}, "bench_replace_post_tags")
+	if err := DB.AutoMigrate(&BenchReplacePost{}, &BenchReplaceTag{}); err != nil {
+		b.Fatalf("failed to migrate, got error %v", err)
+	}
+
+	tags := make([]BenchReplaceTag, 50100)
+	for i := range tags {
+		tags[i].Name = "bench-replace-tag"
+	}
+	DB.CreateInBatches(&tags, 500)
+
+	var post BenchReplacePost
+	DB.Create(&post)
+	if err := DB.Session(&gorm.Session{CreateBatchSize: 500}).Model(&post).Association("Tags").Append(tags[:50000]); err != nil {
+		b.Fatalf("failed to append tags, got error %v", err)
+	}
+	return post, tags[100:]
+}
+
+func BenchmarkMany2ManyReplace(b *testing.B) {
+	// Replace detaches missing tags with one NOT IN condition of all 50k tags
+	if name := DB.Dialector.Name(); name == "sqlite" || name == "sqlserver" {
+		b.Skip("skip sqlite, sqlserver due to they can't bind 50k variables in one statement")
+	}
+
+	post, tags := prepareMany2ManyReplace(b)
+
+	b.ResetTimer()
+	for x := 0; x < b.N; x++ {
+		if err := DB.Model(&post).Association("Tags").Replace(&tags); err != nil {
+			b.Fatalf("failed to replace tags, got error %v", err)
+		}
+	}
+}
+
+func BenchmarkMany2ManyReplaceInBatches(b *testing.B) {
+	post, tags := prepareMany2ManyReplace(b)
+
+	b.ResetTimer()
+	for x := 0; x < b.N; x++ {
+		if err := DB.Model(&post).Association("Tags").ReplaceInBatches(1000, &tags); err != nil {
+			b.Fatalf("failed to replace tags in batches, got error %v", err)
+		}
+	}
+}
@@ -484,3 +484,147 @@ func TestFailedToSaveAssociationShouldRollback(t *testing.T) {
 		t.Errorf("should find product, but got error %v", err)
 	}
 }
+
+type Product5 struct {
+	gorm.Model
+	Name  string
+	Items []Product5Item
+	calls []string
+}
+
+func (p *Product5) BeforeUpdate(*gorm.DB) error {
+	p.calls = append(p.calls, "product:BeforeUpdate")
+	return nil
+}
+
+func (p *Product5) AfterUpdate(*gorm.DB) error {
+	p.calls = append(p.calls, "product:AfterUpdate")
+	return nil
+}
+
+type Product5Item struct {
+	gorm.Model
+	Code       string
+	Product5ID uint
+	calls      *[]string
+}
+
+func (pi *Product5Item) BeforeSave(*gorm.DB) error {
+	*pi.calls = append(*pi.calls, "item:BeforeSave")
+	return nil
+}
+
+func (pi *Product5Item) AfterCreate(*gorm.DB) error {
+	*pi.calls = append(*pi.calls, "item:AfterCreate")
+	return nil
+}
+
+func TestAppendAssociationHooks(t *testing.T) {
+	DB.Migrator().DropTable(&Product5{}, &Product5Item{})
+	DB.AutoMigrate(&Product5{}, &Product5Item{})
+
+	product := Product5{Name: "Product-append-hooks"}
+	if err := DB.Create(&product).Error; err != nil {
+		t.Fatalf("should create product, but got error %v", err)
+	}
+
+	items := []Product5Item{{Code: "item-1", calls: &product.calls}, {Code: "item-2", calls: &product.calls}}
+	if err := DB.Model(&product).Association("Items").Append(&items); err != nil {
+		t.Fatalf("should append items, but got error %v", err)
+	}
+
+	expects := []string{
+		"product:BeforeUpdate",
+		"item:BeforeSave", "item:BeforeSave", "item:AfterCreate", "item:AfterCreate",
+		"product:AfterUpdate",
+	}
+
+	if !reflect.DeepEqual(product.calls, expects) {
+		t.Errorf("hooks should be called in order %v, but got %v", expects, product.calls)
+	}
+}
+
+type Product6 struct {
+	gorm.Model
+	Name  string
+	Items []Product6Item
+	calls []string
+}
+
+func (p *Product6) BeforeSave(*gorm.DB) error {
+	p.calls = append(p.calls, "BeforeSave")
+	return nil
+}
+
+func (p *Product6) BeforeCreate(*gorm.DB) error {
+	p.calls = append(p.calls, "BeforeCreate")
+	return nil
+}
+
+func (p *Product6) BeforeUpdate(*gorm.DB) error {
+	p.calls = append(p.calls, "BeforeUpdate")
+	return nil
+}
+
+func (p *Product6) AfterCreate(*gorm.DB) error {
+	p.calls = append(p.calls, "AfterCreate")
+	return nil
+}
+
+func (p *Product6) AfterUpdate(*gorm.DB) error {
+	p.calls = append(p.calls, "AfterUpdate")
+	return nil
+}
+
+func (p *Product6) AfterSave(*gorm.DB) error {
+	p.calls = append(p.calls, "AfterSave")
+	return nil
+}
+
+type Product6Item struct {
+	gorm.Model
+	Code       string
+	Product6ID uint
+}
+
+func TestAppendAssociationOwnerHooks(t *testing.T) {
+	DB.Migrator().DropTable(&Product6{}, &Product6Item{})
+	DB.AutoMigrate(&Product6{}, &Product6Item{})
+
+	saved := Product6{Name: "Product-owner-hooks-saved"}
+	if err := DB.Create(&saved).Error; err != nil {
+		t.Fatalf("should create product, but got error %v", err)
+	}
+
+	saved.calls = nil
+	if err := DB.Model(&saved).Association("Items").Append(&Product6Item{Code: "item-1"}); err != nil {
+		t.Fatalf("should append items to saved product, but got error %v", err)
+	}
+
+	// hooks are called like DB.Save, saved owners are updated and new owners are created
+	if expects := []string{"BeforeSave", "BeforeUpdate", "AfterSave", "AfterUpdate"}; !reflect.DeepEqual(saved.calls, expects) {
+		t.Errorf("update hooks should be called in order %v, but got %v", expects, saved.calls)
+	}
+
+	unsaved := Product6{Name: "Product-owner-hooks-unsaved"}
+	if err := DB.Model(&unsaved).Association("Items").Append(&Product6Item{Code: "item-2"}); err != nil {
+		t.Fatalf("should append items to unsaved product, but got error %v", err)
+	}
+
+	if expects := []string{"BeforeSave", "BeforeCreate", "AfterSave", "AfterCreate"}; !reflect.DeepEqual(unsaved.calls, expects) {
+		t.Errorf("create hooks should be called in order %v, but got %v", expects, unsaved.calls)
+	}
+
+	if unsaved.ID == 0 || len(unsaved.Items) != 1 || unsaved.Items[0].Product6ID != unsaved.ID {
+		t.Fatalf("unsaved product should be created with its items, but got %+v", unsaved)
+	}
+
+	var result Product6
+	if err := DB.Preload("Items").First(&result, unsaved.ID).Error; err != nil {
+		t.Fatalf("failed to find created product, got error %v", err)
+	}
+
+	if result.Name != unsaved.Name || len(result.Items) != 1 || result.Items[0].Code != "item-2" {
+		t.Errorf("created product should have all its columns and items, but got %+v", result)
+	}
+}
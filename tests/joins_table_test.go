@@ -1,11 +1,14 @@
 package tests_test
 
 import (
+	"errors"
+	"regexp"
 	"testing"
 	"time"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+	. "gorm.io/gorm/utils/tests"
 )
 
 type Person struct {
@@ -114,3 +117,947 @@ func TestOverrideJoinTable(t *testing.T) {
 		t.Errorf("person's addresses expects 2, got %v", count)
 	}
 }
+
+func TestAppendWithJoinAttrs(t *testing.T) {
+	type Skill struct {
+		ID   uint
+		Name string
+	}
+
+	type Developer struct {
+		ID     uint
+		Name   string
+		Skills []Skill `gorm:"many2many:developer_skills;"`
+	}
+
+	type DeveloperSkill struct {
+		DeveloperID uint `gorm:"primaryKey"`
+		SkillID     uint `gorm:"primaryKey"`
+		Level       string
+	}
+
+	DB.Migrator().DropTable(&Developer{}, &Skill{}, "developer_skills")
+
+	if err := DB.SetupJoinTable(&Developer{}, "Skills", &DeveloperSkill{}); err != nil {
+		t.Fatalf("Failed to setup join table for developer, got error %v", err)
+	}
+
+	if err := DB.AutoMigrate(&Developer{}, &Skill{}); err != nil {
+		t.Fatalf("Failed to migrate, got %v", err)
+	}
+
+	developer := Developer{Name: "developer", Skills: []Skill{{Name: "go"}}}
+	DB.Create(&developer)
+
+	skills := []Skill{{Name: "sql"}, {Name: "rust"}}
+	if err := DB.Model(&developer).Association("Skills").AppendWith(map[string]interface{}{"level": "expert"}, &skills); err != nil {
+		t.Fatalf("Failed to append with join attrs, got error %v", err)
+	}
+
+	var developerSkills []DeveloperSkill
+	DB.Find(&developerSkills, "developer_id = ?", developer.ID)
+	if len(developerSkills) != 3 {
+		t.Fatalf("Should have three developer skills, but got %v", len(developerSkills))
+	}
+
+	for _, developerSkill := range developerSkills {
+		if developerSkill.SkillID == developer.Skills[0].ID {
+			if developerSkill.Level != "" {
+				t.Errorf("existing join record should not be changed, but got %v", developerSkill.Level)
+			}
+		} else if developerSkill.Level != "expert" {
+			t.Errorf("join attrs should be assigned to appended record, but got %v", developerSkill.Level)
+		}
+	}
+
+	if err := DB.Model(&developer).Association("Skills").AppendWith(map[string]interface{}{"invalid": "expert"}, &Skill{Name: "c"}); err == nil {
+		t.Errorf("should return error for unknown join attrs")
+	}
+
+	if err := DB.Model(&User{}).Association("Pets").AppendWith(map[string]interface{}{"level": "expert"}, &Pet{}); !errors.Is(err, gorm.ErrUnsupportedRelation) {
+		t.Errorf("should return ErrUnsupportedRelation for has many association, but got %v", err)
+	}
+}
+
+func TestAppendWithJoinAttrExprs(t *testing.T) {
+	type Certificate struct {
+		ID   uint
+		Name string
+	}
+
+	type Engineer struct {
+		ID           uint
+		Name         string
+		Certificates []Certificate `gorm:"many2many:engineer_certificates;"`
+	}
+
+	type EngineerCertificate struct {
+		EngineerID    uint `gorm:"primaryKey"`
+		CertificateID uint `gorm:"primaryKey"`
+		Level         string
+		Note          string
+		CertifiedAt   time.Time
+	}
+
+	DB.Migrator().DropTable(&Engineer{}, &Certificate{}, "engineer_certificates")
+
+	if err := DB.SetupJoinTable(&Engineer{}, "Certificates", &EngineerCertificate{}); err != nil {
+		t.Fatalf("Failed to setup join table for engineer, got error %v", err)
+	}
+
+	if err := DB.AutoMigrate(&Engineer{}, &Certificate{}); err != nil {
+		t.Fatalf("Failed to migrate, got %v", err)
+	}
+
+	engineer := Engineer{Name: "engineer", Certificates: []Certificate{{Name: "cka"}}}
+	DB.Create(&engineer)
+
+	// CURRENT_TIMESTAMP as now() isn't supported by all databases
+	certificates := []Certificate{engineer.Certificates[0], {Name: "ckad"}, {Name: "cks"}}
+	attrs := map[string]interface{}{"Level": gorm.Expr("upper(?)", "expert"), "CertifiedAt": gorm.Expr("CURRENT_TIMESTAMP"), "note": "renewed"}
+	if err := DB.Model(&engineer).Association("Certificates").AppendWith(attrs, &certificates); err != nil {
+		t.Fatalf("Failed to append with join attr expressions, got error %v", err)
+	}
+
+	var engineerCertificates []EngineerCertificate
+	DB.Order("certificate_id").Find(&engineerCertificates, "engineer_id = ?", engineer.ID)
+	if len(engineerCertificates) != 3 {
+		t.Fatalf("Should have three engineer certificates, but got %v", len(engineerCertificates))
+	}
+
+	if existing := engineerCertificates[0]; existing.Level != "" || existing.Note != "" {
+		t.Errorf("existing join record should not be changed, but got %+v", existing)
+	}
+
+	for _, engineerCertificate := range engineerCertificates[1:] {
+		if engineerCertificate.Level != "EXPERT" || engineerCertificate.Note != "renewed" || engineerCertificate.CertifiedAt.IsZero() {
+			t.Errorf("join attr expressions should be assigned to appended record, but got %+v", engineerCertificate)
+		}
+	}
+
+	AssertAssociationCount(t, engineer, "Certificates", 3, "after append with expressions")
+}
+
+func TestAppendReturningJoin(t *testing.T) {
+	type Permission struct {
+		ID   uint
+		Name string
+	}
+
+	type Operator struct {
+		ID          uint
+		Name        string
+		Permissions []Permission `gorm:"many2many:operator_permissions;"`
+	}
+
+	type OperatorPermission struct {
+		OperatorID   uint `gorm:"primaryKey"`
+		PermissionID uint `gorm:"primaryKey"`
+		GrantedBy    string
+		CreatedAt    time.Time
+	}
+
+	type GrantDTO struct {
+		PermissionID uint
+	}
+
+	DB.Migrator().DropTable(&Operator{}, &Permission{}, "operator_permissions")
+
+	if err := DB.SetupJoinTable(&Operator{}, "Permissions", &OperatorPermission{}); err != nil {
+		t.Fatalf("Failed to setup join table for operator, got error %v", err)
+	}
+
+	if err := DB.AutoMigrate(&Operator{}, &Permission{}); err != nil {
+		t.Fatalf("Failed to migrate, got %v", err)
+	}
+
+	operator := Operator{Name: "operator", Permissions: []Permission{{Name: "read"}}}
+	DB.Create(&operator)
+
+	var joinRows []OperatorPermission
+	permissions := []Permission{operator.Permissions[0], {Name: "write"}, {Name: "admin"}}
+	if err := DB.Model(&operator).Association("Permissions").AppendReturningJoin(&joinRows, &permissions); err != nil {
+		t.Fatalf("Failed to append returning join rows, got error %v", err)
+	}
+
+	if len(joinRows) != 2 {
+		t.Fatalf("join rows of appended permissions should be returned, but got %+v", joinRows)
+	}
+
+	for idx, joinRow := range joinRows {
+		if joinRow.OperatorID != operator.ID || joinRow.PermissionID != permissions[idx+1].ID || joinRow.CreatedAt.IsZero() {
+			t.Errorf("join row should be populated with keys and created_at, but got %+v", joinRow)
+		}
+	}
+
+	var grants []*GrantDTO
+	audit := Permission{Name: "audit"}
+	if err := DB.Model(&operator).Association("Permissions").AppendReturningJoin(&grants, &audit); err != nil {
+		t.Fatalf("Failed to append returning join rows into dto, got error %v", err)
+	}
+
+	if len(grants) != 1 || grants[0].PermissionID != audit.ID {
+		t.Errorf("join rows should be assigned to dto by column names, but got %+v", grants)
+	}
+
+	if err := DB.Model(&operator).Association("Permissions").AppendReturningJoin(joinRows, &Permission{Name: "invalid"}); !errors.Is(err, gorm.ErrInvalidData) {
+		t.Errorf("should return ErrInvalidData for non pointer join rows, but got %v", err)
+	}
+
+	if err := DB.Model(&User{}).Association("Pets").AppendReturningJoin(&joinRows, &Pet{}); !errors.Is(err, gorm.ErrUnsupportedRelation) {
+		t.Errorf("should return ErrUnsupportedRelation for has many association, but got %v", err)
+	}
+}
+
+func TestReplaceKeepJoinTableColumns(t *testing.T) {
+	type Badge struct {
+		ID   uint
+		Name string
+	}
+
+	type Document struct {
+		ID     uint
+		Name   string
+		Badges []Badge `gorm:"many2many:document_badges;"`
+	}
+
+	type DocumentBadge struct {
+		DocumentID uint `gorm:"primaryKey"`
+		BadgeID    uint `gorm:"primaryKey"`
+		Note       string
+	}
+
+	DB.Migrator().DropTable(&Document{}, &Badge{}, "document_badges")
+
+	if err := DB.SetupJoinTable(&Document{}, "Badges", &DocumentBadge{}); err != nil {
+		t.Fatalf("Failed to setup join table for document, got error %v", err)
+	}
+
+	if err := DB.AutoMigrate(&Document{}, &Badge{}); err != nil {
+		t.Fatalf("Failed to migrate, got %v", err)
+	}
+
+	document := Document{Name: "document", Badges: []Badge{{Name: "badge 1"}, {Name: "badge 2"}}}
+	DB.Create(&document)
+
+	if err := DB.Model(&DocumentBadge{}).Where("document_id = ?", document.ID).Update("note", "keep me").Error; err != nil {
+		t.Fatalf("Failed to update join table note, got error %v", err)
+	}
+
+	badge3 := Badge{Name: "badge 3"}
+	if err := DB.Model(&document).Association("Badges").Replace(&document.Badges[0], &badge3); err != nil {
+		t.Fatalf("Failed to replace badges, got error %v", err)
+	}
+
+	var documentBadges []DocumentBadge
+	DB.Order("badge_id").Find(&documentBadges, "document_id = ?", document.ID)
+	if len(documentBadges) != 2 {
+		t.Fatalf("Should have two document badges, but got %v", len(documentBadges))
+	}
+
+	if documentBadges[0].BadgeID != document.Badges[0].ID || documentBadges[0].Note != "keep me" {
+		t.Errorf("kept join record's note should be preserved, but got %+v", documentBadges[0])
+	}
+
+	if documentBadges[1].BadgeID != badge3.ID || documentBadges[1].Note != "" {
+		t.Errorf("new join record should be inserted, but got %+v", documentBadges[1])
+	}
+}
+
+func TestUnscopedAssociationDeleteJoinTable(t *testing.T) {
+	DB.Migrator().DropTable(&Person{}, &Address{}, &PersonAddress{})
+
+	if err := DB.SetupJoinTable(&Person{}, "Addresses", &PersonAddress{}); err != nil {
+		t.Fatalf("Failed to setup join table for person, got error %v", err)
+	}
+
+	if err := DB.AutoMigrate(&Person{}, &Address{}); err != nil {
+		t.Fatalf("Failed to migrate, got %v", err)
+	}
+
+	person := Person{Name: "person_unscoped", Addresses: []Address{{Name: "address unscoped 1"}, {Name: "address unscoped 2"}}}
+	DB.Create(&person)
+
+	if err := DB.Model(&person).Association("Addresses").Delete(&person.Addresses[0]); err != nil {
+		t.Fatalf("Failed to delete address, got error %v", err)
+	}
+
+	if DB.Unscoped().Find(&[]PersonAddress{}, "person_id = ?", person.ID).RowsAffected != 2 {
+		t.Fatalf("join record should be soft deleted")
+	}
+
+	if err := DB.Model(&person).Association("Addresses").Unscoped().Delete(&person.Addresses[0]); err != nil {
+		t.Fatalf("Failed to delete address, got error %v", err)
+	}
+
+	if len(person.Addresses) != 0 {
+		t.Errorf("deleted address should be removed from person's addresses, but got %v", len(person.Addresses))
+	}
+
+	if DB.Unscoped().Find(&[]PersonAddress{}, "person_id = ?", person.ID).RowsAffected != 1 {
+		t.Fatalf("join record should be deleted permanently with unscoped association")
+	}
+
+	if count := DB.Model(&person).Association("Addresses").Unscoped().Count(); count != 1 {
+		t.Errorf("unscoped association should count soft deleted join records, but got %v", count)
+	}
+
+	if count := DB.Model(&person).Association("Addresses").Count(); count != 0 {
+		t.Errorf("association should not count soft deleted join records, but got %v", count)
+	}
+}
+
+func TestAssociationJoinWhere(t *testing.T) {
+	type JoinWhereSkill struct {
+		ID   uint
+		Name string
+	}
+
+	type JoinWhereDeveloper struct {
+		ID     uint
+		Name   string
+		Skills []JoinWhereSkill `gorm:"many2many:join_where_developer_skills;"`
+	}
+
+	type JoinWhereDeveloperSkill struct {
+		JoinWhereDeveloperID uint `gorm:"primaryKey"`
+		JoinWhereSkillID     uint `gorm:"primaryKey"`
+		Level                string
+	}
+
+	DB.Migrator().DropTable(&JoinWhereDeveloper{}, &JoinWhereSkill{}, "join_where_developer_skills")
+
+	if err := DB.SetupJoinTable(&JoinWhereDeveloper{}, "Skills", &JoinWhereDeveloperSkill{}); err != nil {
+		t.Fatalf("Failed to setup join table for developer, got error %v", err)
+	}
+
+	if err := DB.AutoMigrate(&JoinWhereDeveloper{}, &JoinWhereSkill{}); err != nil {
+		t.Fatalf("Failed to migrate, got %v", err)
+	}
+
+	developer := JoinWhereDeveloper{Name: "developer", Skills: []JoinWhereSkill{{Name: "go"}}}
+	DB.Create(&developer)
+
+	skills := []JoinWhereSkill{{Name: "sql"}, {Name: "rust"}}
+	if err := DB.Model(&developer).Association("Skills").AppendWith(map[string]interface{}{"level": "expert"}, &skills); err != nil {
+		t.Fatalf("Failed to append with join attrs, got error %v", err)
+	}
+
+	var expertSkills []JoinWhereSkill
+	if err := DB.Model(&developer).Association("Skills").JoinWhere("level = ?", "expert").Find(&expertSkills); err != nil || len(expertSkills) != 2 {
+		t.Fatalf("should find two expert skills, got %v, error: %v", len(expertSkills), err)
+	}
+
+	if count := DB.Model(&developer).Association("Skills").JoinWhere(map[string]interface{}{"Level": "expert"}).Count(); count != 2 {
+		t.Errorf("should count two expert skills, but got %v", count)
+	}
+
+	if count := DB.Model(&developer).Association("Skills").JoinWhere(JoinWhereDeveloperSkill{Level: "expert"}).JoinWhere("level <> ?", "").Count(); count != 2 {
+		t.Errorf("should count two expert skills with merged conditions, but got %v", count)
+	}
+
+	if rowsAffected, err := DB.Model(&developer).Association("Skills").JoinWhere("level = ?", "expert").DeleteWithResult(developer.Skills); err != nil || rowsAffected != 2 {
+		t.Errorf("should only delete expert skills, got %v, error: %v", rowsAffected, err)
+	}
+
+	if count := DB.Model(&developer).Association("Skills").Count(); count != 1 {
+		t.Errorf("should keep one skill, but got %v", count)
+	}
+
+	if err := DB.Model(&developer).Association("Pets").JoinWhere("level = ?", "expert").Error; err == nil {
+		t.Errorf("should return error for invalid relation")
+	}
+
+	if err := DB.Model(&User{}).Association("Pets").JoinWhere("level = ?", "expert").Error; !errors.Is(err, gorm.ErrUnsupportedRelation) {
+		t.Errorf("should return error for relation without join table, but got %v", err)
+	}
+}
+
+func TestAssociationFindWithJoin(t *testing.T) {
+	type JoinRole struct {
+		ID   uint
+		Name string
+	}
+
+	type JoinUser struct {
+		ID    uint
+		Name  string
+		Roles []JoinRole `gorm:"many2many:user_roles;"`
+	}
+
+	type UserRole struct {
+		JoinUserID uint `gorm:"primaryKey"`
+		JoinRoleID uint `gorm:"primaryKey"`
+		GrantedAt  time.Time
+	}
+
+	DB.Migrator().DropTable(&JoinUser{}, &JoinRole{}, "user_roles")
+
+	if err := DB.SetupJoinTable(&JoinUser{}, "Roles", &UserRole{}); err != nil {
+		t.Fatalf("Failed to setup join table for user, got error %v", err)
+	}
+
+	if err := DB.AutoMigrate(&JoinUser{}, &JoinRole{}); err != nil {
+		t.Fatalf("Failed to migrate, got %v", err)
+	}
+
+	user := JoinUser{Name: "user"}
+	DB.Create(&user)
+
+	grantedAt := time.Now().Add(-time.Hour).Round(time.Second)
+	roles := []JoinRole{{Name: "admin"}, {Name: "editor"}}
+	if err := DB.Model(&user).Association("Roles").AppendWith(map[string]interface{}{"GrantedAt": grantedAt}, &roles); err != nil {
+		t.Fatalf("Failed to append roles, got error %v", err)
+	}
+
+	var (
+		foundRoles []JoinRole
+		userRoles  []UserRole
+	)
+
+	if err := DB.Model(&user).Association("Roles").FindWithJoin(&foundRoles, &userRoles, clause.OrderBy{
+		Columns: []clause.OrderByColumn{{Column: clause.Column{Table: "join_roles", Name: "name"}, Desc: true}},
+	}); err != nil {
+		t.Fatalf("Failed to find roles with join records, got error %v", err)
+	}
+
+	if len(foundRoles) != 2 || len(userRoles) != 2 {
+		t.Fatalf("should find two roles with join records, but got %v, %v", len(foundRoles), len(userRoles))
+	}
+
+	for idx, role := range foundRoles {
+		if userRoles[idx].JoinRoleID != role.ID || userRoles[idx].JoinUserID != user.ID {
+			t.Errorf("join record #%v should belong to role %v, but got %+v", idx, role.ID, userRoles[idx])
+		}
+
+		AssertEqual(t, userRoles[idx].GrantedAt, grantedAt)
+	}
+
+	if err := DB.Model(&User{}).Association("Pets").FindWithJoin(&[]Pet{}, &userRoles); !errors.Is(err, gorm.ErrUnsupportedRelation) {
+		t.Errorf("should return error for relation without join table, but got %v", err)
+	}
+}
+
+func TestAssociationActive(t *testing.T) {
+	type ActiveMember struct {
+		ID   uint
+		Name string
+	}
+
+	type ActiveGroup struct {
+		ID      uint
+		Name    string
+		Members []ActiveMember `gorm:"many2many:active_group_members;"`
+	}
+
+	type ActiveGroupMember struct {
+		ActiveGroupID  uint `gorm:"primaryKey"`
+		ActiveMemberID uint `gorm:"primaryKey"`
+		ValidFrom      time.Time
+		ValidTo        *time.Time
+	}
+
+	DB.Migrator().DropTable(&ActiveGroup{}, &ActiveMember{}, "active_group_members")
+
+	if err := DB.SetupJoinTable(&ActiveGroup{}, "Members", &ActiveGroupMember{}); err != nil {
+		t.Fatalf("Failed to setup join table for group, got error %v", err)
+	}
+
+	if err := DB.AutoMigrate(&ActiveGroup{}, &ActiveMember{}); err != nil {
+		t.Fatalf("Failed to migrate, got %v", err)
+	}
+
+	var (
+		now      = time.Now().Round(time.Second)
+		lastWeek = now.Add(-7 * 24 * time.Hour)
+		tomorrow = now.Add(24 * time.Hour)
+	)
+
+	group := ActiveGroup{Name: "group"}
+	DB.Create(&group)
+
+	for name, window := range map[string][2]interface{}{
+		"former":   {lastWeek, &now},
+		"current":  {lastWeek, nil},
+		"upcoming": {tomorrow, nil},
+	} {
+		member := ActiveMember{Name: name}
+		if err := DB.Model(&group).Association("Members").AppendWith(map[string]interface{}{"ValidFrom": window[0], "ValidTo": window[1]}, &member); err != nil {
+			t.Fatalf("Failed to append member, got error %v", err)
+		}
+	}
+
+	var members []ActiveMember
+	if err := DB.Model(&group).Association("Members").Active(now, "ValidFrom", "valid_to").Find(&members); err != nil || len(members) != 2 {
+		t.Fatalf("should find two active members including the inclusive end, got %v, error: %v", len(members), err)
+	}
+
+	if count := DB.Model(&group).Association("Members").Active(now.Add(time.Second), "ValidFrom", "ValidTo").Count(); count != 1 {
+		t.Errorf("should count one active member after former's window, but got %v", count)
+	}
+
+	if count := DB.Model(&group).Association("Members").Active(tomorrow, "ValidFrom", "ValidTo").Count(); count != 2 {
+		t.Errorf("should count two active members from the inclusive start, but got %v", count)
+	}
+
+	if err := DB.Model(&User{}).Association("Pets").Active(now, "ValidFrom", "ValidTo").Error; !errors.Is(err, gorm.ErrUnsupportedRelation) {
+		t.Errorf("should return error for relation without join table, but got %v", err)
+	}
+}
+
+func TestAssociationFindDistinct(t *testing.T) {
+	type DistinctTag struct {
+		ID   uint
+		Name string
+	}
+
+	type DistinctPost struct {
+		ID   uint
+		Name string
+		Tags []DistinctTag `gorm:"many2many:distinct_post_tags;"`
+	}
+
+	type DistinctPostTag struct {
+		ID             uint
+		DistinctPostID uint
+		DistinctTagID  uint
+		Source         string
+	}
+
+	DB.Migrator().DropTable(&DistinctPost{}, &DistinctTag{}, "distinct_post_tags")
+
+	if err := DB.SetupJoinTable(&DistinctPost{}, "Tags", &DistinctPostTag{}); err != nil {
+		t.Fatalf("Failed to setup join table for post, got error %v", err)
+	}
+
+	if err := DB.AutoMigrate(&DistinctPost{}, &DistinctTag{}, &DistinctPostTag{}); err != nil {
+		t.Fatalf("Failed to migrate, got %v", err)
+	}
+
+	post := DistinctPost{Name: "post", Tags: []DistinctTag{{Name: "go"}, {Name: "sql"}}}
+	DB.Create(&post)
+
+	// link the same tag twice with different join attributes
+	if err := DB.Create(&DistinctPostTag{DistinctPostID: post.ID, DistinctTagID: post.Tags[0].ID, Source: "import"}).Error; err != nil {
+		t.Fatalf("Failed to create duplicated link, got error %v", err)
+	}
+
+	var tags []DistinctTag
+	if err := DB.Model(&post).Association("Tags").Find(&tags); err != nil || len(tags) != 3 {
+		t.Fatalf("should find duplicated tags without distinct, got %v, error: %v", len(tags), err)
+	}
+
+	var distinctTags []DistinctTag
+	if err := DB.Model(&post).Distinct().Association("Tags").Find(&distinctTags); err != nil || len(distinctTags) != 2 {
+		t.Fatalf("should find two distinct tags, got %v, error: %v", len(distinctTags), err)
+	}
+
+	var names []DistinctTag
+	if err := DB.Model(&post).Distinct("name").Association("Tags").Find(&names); err != nil || len(names) != 2 {
+		t.Fatalf("should find two distinct tag names, got %v, error: %v", len(names), err)
+	}
+
+	for _, tag := range names {
+		if tag.ID != 0 || tag.Name == "" {
+			t.Errorf("should only select distinct columns, but got %+v", tag)
+		}
+	}
+
+}
+
+func TestAssociationAppendOnConflict(t *testing.T) {
+	type OnConflictTag struct {
+		ID   uint
+		Name string
+	}
+
+	type OnConflictPost struct {
+		ID   uint
+		Name string
+		Tags []OnConflictTag `gorm:"many2many:on_conflict_post_tags;"`
+	}
+
+	type OnConflictPostTag struct {
+		OnConflictPostID uint `gorm:"primaryKey"`
+		OnConflictTagID  uint `gorm:"primaryKey"`
+		Source           string
+	}
+
+	DB.Migrator().DropTable(&OnConflictPost{}, &OnConflictTag{}, "on_conflict_post_tags")
+
+	if err := DB.SetupJoinTable(&OnConflictPost{}, "Tags", &OnConflictPostTag{}); err != nil {
+		t.Fatalf("Failed to setup join table for post, got error %v", err)
+	}
+
+	if err := DB.AutoMigrate(&OnConflictPost{}, &OnConflictTag{}); err != nil {
+		t.Fatalf("Failed to migrate, got %v", err)
+	}
+
+	post := OnConflictPost{Name: "post"}
+	tag := OnConflictTag{Name: "tag"}
+	DB.Create(&post)
+
+	for i := 0; i < 2; i++ {
+		if err := DB.Model(&post).Association("Tags").AppendWith(map[string]interface{}{"source": "manual"}, &tag); err != nil {
+			t.Fatalf("re-appending existing tag should be idempotent, got error %v", err)
+		}
+	}
+
+	var postTags []OnConflictPostTag
+	if DB.Find(&postTags, "on_conflict_post_id = ?", post.ID); len(postTags) != 1 || postTags[0].Source != "manual" {
+		t.Fatalf("should have single join record, but got %+v", postTags)
+	}
+
+	onConflict := clause.OnConflict{
+		Columns:   []clause.Column{{Name: "on_conflict_post_id"}, {Name: "on_conflict_tag_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"source"}),
+	}
+
+	if err := DB.Clauses(onConflict).Model(&post).Association("Tags").AppendWith(map[string]interface{}{"source": "import"}, &tag); err != nil {
+		t.Fatalf("failed to append tag with on conflict clause, got error %v", err)
+	}
+
+	if DB.Find(&postTags, "on_conflict_post_id = ?", post.ID); len(postTags) != 1 || postTags[0].Source != "import" {
+		t.Errorf("should update existing join record with on conflict clause, but got %+v", postTags)
+	}
+
+	var tags []OnConflictTag
+	if DB.Find(&tags); len(tags) != 1 {
+		t.Errorf("should not duplicate tags, but got %+v", tags)
+	}
+}
+
+func TestAssociationConditionsQualifiedWithJoinTable(t *testing.T) {
+	type QualifiedCourse struct {
+		ID   uint
+		Name string
+	}
+
+	type QualifiedStudent struct {
+		ID      uint
+		Name    string
+		Courses []QualifiedCourse `gorm:"many2many:qualified_enrollments"`
+	}
+
+	type QualifiedEnrollment struct {
+		ID                 uint
+		Name               string
+		QualifiedStudentID uint
+		QualifiedCourseID  uint
+	}
+
+	DB.Migrator().DropTable(&QualifiedStudent{}, &QualifiedCourse{}, &QualifiedEnrollment{})
+	if err := DB.SetupJoinTable(&QualifiedStudent{}, "Courses", &QualifiedEnrollment{}); err != nil {
+		t.Fatalf("failed to setup join table, got error %v", err)
+	}
+
+	if err := DB.AutoMigrate(&QualifiedStudent{}, &QualifiedCourse{}, &QualifiedEnrollment{}); err != nil {
+		t.Fatalf("failed to migrate, got error %v", err)
+	}
+
+	student := QualifiedStudent{Name: "qualified", Courses: []QualifiedCourse{{Name: "math"}, {Name: "physics"}}}
+	if err := DB.Create(&student).Error; err != nil {
+		t.Fatalf("failed to create, got error %v", err)
+	}
+
+	var courses []QualifiedCourse
+	if err := DB.Model(&student).Association("Courses").Find(&courses, map[string]interface{}{"id": student.Courses[1].ID}); err != nil {
+		t.Fatalf("failed to find with conditions, got error %v", err)
+	}
+
+	if len(courses) != 1 || courses[0].Name != "physics" {
+		t.Errorf("should find course by its id, got %+v", courses)
+	}
+
+	var course QualifiedCourse
+	if err := DB.Model(&student).Association("Courses").First(&course, map[string]interface{}{"name": "math"}); err != nil || course.ID != student.Courses[0].ID {
+		t.Errorf("should find first course by name, got %+v, error %v", course, err)
+	}
+
+	if count := DB.Model(&student).Association("Courses").Count("id", student.Courses[0].ID); count != 1 {
+		t.Errorf("should count course by its id, got %v", count)
+	}
+
+	var sqls []string
+	DB.Callback().Query().After("gorm:query").Register("test:qualified_conditions", func(db *gorm.DB) {
+		sqls = append(sqls, db.Statement.SQL.String())
+	})
+	defer DB.Callback().Query().Remove("test:qualified_conditions")
+
+	DB.Model(&student).Association("Courses").Find(&courses, map[string]interface{}{"id": 1, "name": "math"})
+	if len(sqls) != 1 || !regexp.MustCompile("WHERE .*qualified_courses.\\..id. = .* AND .qualified_courses.\\..name. = ").MatchString(sqls[0]) {
+		t.Errorf("conditions should be qualified with the associations's table, got %v", sqls)
+	}
+}
+
+func TestJoinAssociation(t *testing.T) {
+	type JoinRole struct {
+		ID   uint
+		Name string
+	}
+
+	type JoinRoleUser struct {
+		ID    uint
+		Name  string
+		Roles []JoinRole `gorm:"many2many:join_role_users_roles;"`
+	}
+
+	type JoinRoleUserRole struct {
+		JoinRoleUserID uint `gorm:"primaryKey"`
+		JoinRoleID     uint `gorm:"primaryKey"`
+		SortOrder      int
+	}
+
+	DB.Migrator().DropTable(&JoinRoleUser{}, &JoinRole{}, &JoinRoleUserRole{})
+	if err := DB.SetupJoinTable(&JoinRoleUser{}, "Roles", &JoinRoleUserRole{}); err != nil {
+		t.Fatalf("failed to setup join table, got error %v", err)
+	}
+
+	if err := DB.AutoMigrate(&JoinRoleUser{}, &JoinRole{}); err != nil {
+		t.Fatalf("failed to migrate, got error %v", err)
+	}
+
+	user := JoinRoleUser{Name: "join-association", Roles: []JoinRole{{Name: "admin"}, {Name: "editor"}, {Name: "viewer"}}}
+	otherUser := JoinRoleUser{Name: "join-association-other", Roles: []JoinRole{{Name: "guest"}}}
+	DB.Create(&user)
+	DB.Create(&otherUser)
+
+	for idx, role := range user.Roles {
+		if err := DB.Model(&user).JoinAssociation("Roles").Update("SortOrder", idx+1, &role); err != nil {
+			t.Fatalf("failed to update sort order, got error %v", err)
+		}
+	}
+
+	var userRoles []JoinRoleUserRole
+	if err := DB.Model(&user).JoinAssociation("Roles").Find(&userRoles); err != nil || len(userRoles) != 3 {
+		t.Fatalf("should find 3 join records, got %v, error %v", len(userRoles), err)
+	}
+
+	for _, userRole := range userRoles {
+		for idx, role := range user.Roles {
+			if role.ID == userRole.JoinRoleID && userRole.SortOrder != idx+1 {
+				t.Errorf("invalid sort order of role %v, expects %v, got %v", role.Name, idx+1, userRole.SortOrder)
+			}
+		}
+	}
+
+	var results []map[string]interface{}
+	if err := DB.Model(&user).JoinAssociation("Roles").Find(&results, &user.Roles[1]); err != nil || len(results) != 1 || results[0]["sort_order"] != int64(2) {
+		t.Errorf("should find join record of the role, got %v, error %v", results, err)
+	}
+
+	if err := DB.Model(&user).JoinAssociation("Roles").Updates(map[string]interface{}{"sort_order": 9}); err != nil {
+		t.Fatalf("failed to update all join records, got error %v", err)
+	}
+
+	var count int64
+	DB.Model(&JoinRoleUserRole{}).Where("sort_order = ?", 9).Count(&count)
+	if count != 3 {
+		t.Errorf("should only update join records of the owner, got %v", count)
+	}
+
+	if err := DB.Model(&user).JoinAssociation("Roles").Update("invalid", 1); !errors.Is(err, gorm.ErrInvalidField) {
+		t.Errorf("should return error for invalid join table column, got %v", err)
+	}
+
+	if err := DB.Model(&User{}).JoinAssociation("Pets").Find(&results); !errors.Is(err, gorm.ErrUnsupportedRelation) {
+		t.Errorf("should return error for relations without join table, got %v", err)
+	}
+}
+
+func TestMany2ManyAssociationPositions(t *testing.T) {
+	type PositionSong struct {
+		ID   uint
+		Name string
+	}
+
+	type PositionPlaylist struct {
+		ID    uint
+		Name  string
+		Songs []PositionSong `gorm:"many2many:position_playlist_songs;position:sort_order"`
+	}
+
+	type PositionPlaylistSong struct {
+		PositionPlaylistID uint `gorm:"primaryKey"`
+		PositionSongID     uint `gorm:"primaryKey"`
+		SortOrder          int
+	}
+
+	DB.Migrator().DropTable(&PositionPlaylist{}, &PositionSong{}, &PositionPlaylistSong{})
+	if err := DB.SetupJoinTable(&PositionPlaylist{}, "Songs", &PositionPlaylistSong{}); err != nil {
+		t.Fatalf("failed to setup join table, got error %v", err)
+	}
+
+	if err := DB.AutoMigrate(&PositionPlaylist{}, &PositionSong{}); err != nil {
+		t.Fatalf("failed to migrate, got error %v", err)
+	}
+
+	assertPositions := func(playlist PositionPlaylist, songs ...PositionSong) {
+		t.Helper()
+		var joins []PositionPlaylistSong
+		DB.Where("position_playlist_id = ?", playlist.ID).Order("sort_order").Find(&joins)
+		if len(joins) != len(songs) {
+			t.Fatalf("expects %v songs, got %+v", len(songs), joins)
+		}
+
+		for idx, join := range joins {
+			if join.SortOrder != idx || join.PositionSongID != songs[idx].ID {
+				t.Errorf("song %v should be at position %v, got %+v", songs[idx].Name, idx, join)
+			}
+		}
+	}
+
+	playlist := PositionPlaylist{Name: "positions"}
+	DB.Create(&playlist)
+	if err := DB.Model(&playlist).Association("Songs").Append(&PositionSong{Name: "a"}, &PositionSong{Name: "b"}); err != nil {
+		t.Fatalf("failed to append songs, got error %v", err)
+	}
+	assertPositions(playlist, playlist.Songs...)
+
+	otherPlaylist := PositionPlaylist{Name: "positions-other"}
+	DB.Create(&otherPlaylist)
+	if err := DB.Model(&otherPlaylist).Association("Songs").Append(&PositionSong{Name: "x"}); err != nil {
+		t.Fatalf("failed to append songs, got error %v", err)
+	}
+	assertPositions(otherPlaylist, otherPlaylist.Songs...)
+
+	// positions are only assigned by association mode, saving the owner doesn't query its join records
+	var queries int
+	DB.Callback().Query().Before("gorm:query").Register("test:count_position_queries", func(tx *gorm.DB) {
+		queries++
+	})
+	DB.Save(&playlist)
+	DB.Callback().Query().Remove("test:count_position_queries")
+	if queries != 0 {
+		t.Errorf("saving the owner shouldn't query join records, got %v queries", queries)
+	}
+
+	if err := DB.Model(&playlist).Association("Songs").Append(&PositionSong{Name: "c"}, &PositionSong{Name: "d"}); err != nil {
+		t.Fatalf("failed to append songs, got error %v", err)
+	}
+	songs := append([]PositionSong{}, playlist.Songs...)
+	assertPositions(playlist, songs...)
+
+	// remove song in the middle
+	if err := DB.Model(&playlist).Association("Songs").Delete(&songs[1]); err != nil {
+		t.Fatalf("failed to delete song, got error %v", err)
+	}
+	assertPositions(playlist, songs[0], songs[2], songs[3])
+
+	if err := DB.Model(&playlist).Association("Songs").Replace(&songs[3], &songs[0]); err != nil {
+		t.Fatalf("failed to replace songs, got error %v", err)
+	}
+	assertPositions(playlist, songs[3], songs[0])
+	assertPositions(otherPlaylist, otherPlaylist.Songs...)
+
+	// songs replaced in batches are appended after linked songs
+	song := PositionSong{Name: "e"}
+	if err := DB.Model(&playlist).Association("Songs").ReplaceInBatches(1, &songs[3], &songs[0], &song); err != nil {
+		t.Fatalf("failed to replace songs in batches, got error %v", err)
+	}
+	assertPositions(playlist, songs[3], songs[0], song)
+}
+
+func TestJoinTableWithDeleted(t *testing.T) {
+	DB.Migrator().DropTable(&PersonAddress{}, &Person{}, &Address{})
+	if err := DB.SetupJoinTable(&Person{}, "Addresses", &PersonAddress{}); err != nil {
+		t.Fatalf("Failed to setup join table for person, got error %v", err)
+	}
+
+	if err := DB.AutoMigrate(&Person{}, &Address{}); err != nil {
+		t.Fatalf("Failed to migrate, got %v", err)
+	}
+
+	person := Person{Name: "person", Addresses: []Address{{Name: "address 1"}, {Name: "address 2"}}}
+	DB.Create(&person)
+
+	association := DB.Model(&person).Association("Addresses").WithDeleted()
+	if err := association.Delete(&person.Addresses[0]); err != nil {
+		t.Fatalf("Failed to delete address, got error %v", err)
+	}
+
+	if DB.Unscoped().Find(&[]PersonAddress{}, "person_id = ?", person.ID).RowsAffected != 2 {
+		t.Errorf("join records should still be soft deleted with deleted")
+	}
+
+	var addresses []Address
+	if err := association.Find(&addresses); err != nil || len(addresses) != 1 {
+		t.Errorf("soft deleted join records should be excluded with deleted, got %v, error %v", len(addresses), err)
+	}
+
+	if count := association.Count(); count != 1 {
+		t.Errorf("soft deleted join records should be excluded when counting with deleted, got %v", count)
+	}
+
+	conds, err := DB.Model(&person).Association("Addresses").QueryConditions()
+	if err != nil {
+		t.Fatalf("Failed to get query conditions, got error %v", err)
+	}
+
+	addresses = nil
+	if err := DB.Clauses(
+		clause.From{Tables: []clause.Table{{Name: "addresses"}, {Name: "person_addresses"}}},
+		clause.Where{Exprs: conds},
+	).Find(&addresses).Error; err != nil || len(addresses) != 1 {
+		t.Errorf("soft deleted join records should be excluded by query conditions, got %v, error %v", len(addresses), err)
+	}
+}
+
+func TestJoinTableTimestampsUseNowFunc(t *testing.T) {
+	type Stamp struct {
+		ID   uint
+		Name string
+	}
+
+	type Album struct {
+		ID     uint
+		Name   string
+		Stamps []Stamp `gorm:"many2many:album_stamps;"`
+	}
+
+	type AlbumStamp struct {
+		AlbumID   uint      `gorm:"primaryKey"`
+		StampID   uint      `gorm:"primaryKey"`
+		CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+		UpdatedAt time.Time
+	}
+
+	DB.Migrator().DropTable(&Album{}, &Stamp{}, "album_stamps")
+
+	if err := DB.SetupJoinTable(&Album{}, "Stamps", &AlbumStamp{}); err != nil {
+		t.Fatalf("Failed to setup join table for album, got error %v", err)
+	}
+
+	if err := DB.AutoMigrate(&Album{}, &Stamp{}); err != nil {
+		t.Fatalf("Failed to migrate, got %v", err)
+	}
+
+	now := time.Date(2001, 2, 3, 4, 5, 6, 0, time.FixedZone("UTC+9", 9*60*60))
+	tx := DB.Session(&gorm.Session{NowFunc: func() time.Time { return now }})
+
+	album := Album{Name: "album"}
+	tx.Create(&album)
+
+	stamps := []Stamp{{Name: "stamp 1"}, {Name: "stamp 2"}}
+	if err := tx.Model(&album).Association("Stamps").Append(&stamps); err != nil {
+		t.Fatalf("Failed to append stamps, got error %v", err)
+	}
+
+	stamp3 := Stamp{Name: "stamp 3"}
+	DB.Create(&stamp3)
+	if err := tx.Model(&album).Association("Stamps").AppendByID(stamp3.ID); err != nil {
+		t.Fatalf("Failed to append stamp by id, got error %v", err)
+	}
+
+	var albumStamps []AlbumStamp
+	DB.Find(&albumStamps, "album_id = ?", album.ID)
+	if len(albumStamps) != 3 {
+		t.Fatalf("Should have three album stamps, but got %v", len(albumStamps))
+	}
+
+	for _, albumStamp := range albumStamps {
+		if !albumStamp.CreatedAt.Equal(tx.NowFunc()) || !albumStamp.UpdatedAt.Equal(tx.NowFunc()) {
+			t.Errorf("join record's timestamps should come from NowFunc %v, but got %+v", tx.NowFunc(), albumStamp)
+		}
+	}
+}
@@ -47,6 +47,27 @@ func TestMigrate(t *testing.T) {
 	}
 }
 
+func TestMigrateWithInvalidRelationship(t *testing.T) {
+	type InvalidRelationProfile struct {
+		ID     uint
+		UserID uint
+	}
+
+	type InvalidRelationUser struct {
+		ID      uint
+		Profile InvalidRelationProfile `gorm:"foreignKey:OwnerID"`
+	}
+
+	err := DB.AutoMigrate(&InvalidRelationUser{})
+	if err == nil || !strings.Contains(err.Error(), "invalid foreign key OwnerID for relation InvalidRelationUser.Profile") {
+		t.Fatalf("should fail to migrate with invalid foreign key, got error %v", err)
+	}
+
+	if DB.Migrator().HasTable(&InvalidRelationUser{}) {
+		t.Errorf("table of invalid relationship shouldn't be created")
+	}
+}
+
 func TestSmartMigrateColumn(t *testing.T) {
 	fullSupported := map[string]bool{"mysql": true, "postgres": true}[DB.Dialector.Name()]
 
@@ -3,7 +3,9 @@ package tests_test
 import (
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"gorm.io/gorm"
 	. "gorm.io/gorm/utils/tests"
@@ -446,3 +448,108 @@ func TestCompositePrimaryKeysAssociations(t *testing.T) {
 
 	AssertEqual(t, book, result)
 }
+
+func TestCompositePrimaryKeysAssociationsDeleteWithDelimiter(t *testing.T) {
+	if name := DB.Dialector.Name(); name == "sqlite" || name == "sqlserver" {
+		t.Skip("skip sqlite, sqlserver due to it doesn't support row values with IN conditions")
+	}
+
+	type Option struct {
+		Name      string `gorm:"primarykey"`
+		Value     string `gorm:"primarykey"`
+		ProductID uint
+	}
+
+	type Product struct {
+		ID      uint
+		Name    string
+		Options []Option
+	}
+
+	DB.Migrator().DropTable(&Option{}, &Product{})
+	if err := DB.AutoMigrate(&Option{}, &Product{}); err != nil {
+		t.Fatalf("failed to migrate, got error: %v", err)
+	}
+
+	product := Product{
+		Name:    "product",
+		Options: []Option{{Name: "color_dark", Value: "red"}, {Name: "color", Value: "dark_red"}},
+	}
+
+	if err := DB.Create(&product).Error; err != nil {
+		t.Fatalf("failed to create product, got error: %v", err)
+	}
+
+	if err := DB.Model(&product).Association("Options").Delete(&Option{Name: "color_dark", Value: "red"}); err != nil {
+		t.Fatalf("failed to delete option, got error: %v", err)
+	}
+
+	if len(product.Options) != 1 || product.Options[0].Name != "color" || product.Options[0].Value != "dark_red" {
+		t.Errorf("only the deleted option should be removed, but got %+v", product.Options)
+	}
+
+	if count := DB.Model(&product).Association("Options").Count(); count != 1 {
+		t.Errorf("product should have one option, but got %v", count)
+	}
+}
+
+func TestCompositePrimaryKeysHasManyAppend(t *testing.T) {
+	type CompositeKeyItem struct {
+		ID        uint
+		TenantID  uint
+		OwnerCode string
+		Name      string
+	}
+
+	type CompositeKeyOwner struct {
+		TenantID  uint   `gorm:"primaryKey;autoIncrement:false"`
+		Code      string `gorm:"primaryKey"`
+		Name      string
+		UpdatedAt time.Time
+		Items     []CompositeKeyItem `gorm:"foreignKey:TenantID,OwnerCode;references:TenantID,Code"`
+	}
+
+	DB.Migrator().DropTable(&CompositeKeyItem{}, &CompositeKeyOwner{})
+	if err := DB.AutoMigrate(&CompositeKeyOwner{}, &CompositeKeyItem{}); err != nil {
+		t.Fatalf("failed to migrate, got error %v", err)
+	}
+
+	owner := CompositeKeyOwner{TenantID: 1, Code: "a", Name: "owner-a"}
+	other := CompositeKeyOwner{TenantID: 1, Code: "b", Name: "owner-b"}
+	DB.Create(&owner)
+	DB.Create(&other)
+
+	var sqls []string
+	DB.Callback().Update().After("gorm:update").Register("test:composite_key_sql", func(tx *gorm.DB) {
+		sqls = append(sqls, tx.Statement.SQL.String())
+	})
+	defer DB.Callback().Update().Remove("test:composite_key_sql")
+
+	if err := DB.Omit("Code").Model(&owner).Association("Items").Append(&CompositeKeyItem{Name: "item-1"}, &CompositeKeyItem{Name: "item-2"}); err != nil {
+		t.Fatalf("failed to append items, got error %v", err)
+	}
+
+	for _, sql := range sqls {
+		if strings.Contains(sql, "composite_key_owners") && (!strings.Contains(sql, "tenant_id") || !strings.Contains(sql, "code")) {
+			t.Errorf("owner should be updated by all primary keys, got %v", sql)
+		}
+	}
+
+	var items []CompositeKeyItem
+	DB.Find(&items)
+	if len(items) != 2 {
+		t.Fatalf("should create %v items, got %v", 2, len(items))
+	}
+
+	for _, item := range items {
+		if item.TenantID != owner.TenantID || item.OwnerCode != owner.Code {
+			t.Errorf("item should reference the owner's primary keys, got %+v", item)
+		}
+	}
+
+	var result CompositeKeyOwner
+	DB.First(&result, "tenant_id = ? AND code = ?", other.TenantID, other.Code)
+	if result.Name != other.Name || !result.UpdatedAt.Equal(other.UpdatedAt) {
+		t.Errorf("another owner with the same tenant shouldn't be changed, got %+v", result)
+	}
+}
@@ -2,6 +2,8 @@ package tests_test
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -50,3 +52,48 @@ func TestPreparedStmt(t *testing.T) {
 		t.Fatalf("no error should happen but got %v", err)
 	}
 }
+
+func TestPreparedStmtAssociationAppendForSlice(t *testing.T) {
+	tx := DB.Session(&gorm.Session{PrepareStmt: true})
+	preparedStmt, ok := tx.ConnPool.(*gorm.PreparedStmtDB)
+	if !ok {
+		t.Fatalf("should assign PreparedStatement Manager back to database when using PrepareStmt mode")
+	}
+
+	var users []User
+	var pets []interface{}
+	for i := 0; i < 10; i++ {
+		users = append(users, *GetUser(fmt.Sprintf("prepared_stmt_append_%v", i), Config{}))
+		pets = append(pets, &Pet{Name: fmt.Sprintf("prepared_stmt_append_pet_%v", i)})
+	}
+
+	if err := tx.Create(&users).Error; err != nil {
+		t.Fatalf("errors happened when create: %v", err)
+	}
+
+	countPrepared := func(prefix string) (count int) {
+		preparedStmt.Mux.RLock()
+		defer preparedStmt.Mux.RUnlock()
+		for _, query := range preparedStmt.PreparedSQL {
+			if strings.HasPrefix(query, prefix) {
+				count++
+			}
+		}
+		return
+	}
+
+	prepared := countPrepared("INSERT INTO `pets`")
+	if err := tx.Model(&users).Association("Pets").Append(pets...); err != nil {
+		t.Fatalf("errors happened when append pets: %v", err)
+	}
+
+	if count := countPrepared("INSERT INTO `pets`"); count-prepared > 1 {
+		t.Errorf("inserting pets for each user should reuse the prepared statement, but prepared %v statements", count-prepared)
+	}
+
+	for idx, user := range users {
+		if len(user.Pets) != 1 || user.Pets[0].ID == 0 {
+			t.Errorf("pet should be appended to user #%v, but got %+v", idx, user.Pets)
+		}
+	}
+}
@@ -46,27 +46,81 @@ func CheckTruth(val interface{}) bool {
 	return !reflect.ValueOf(val).IsZero()
 }
 
+// ToStringKey build a key from values joined with "_", delimiters in values are escaped to avoid collisions between different values
 func ToStringKey(values ...interface{}) string {
-	results := make([]string, len(values))
+	return ToStringKeyWithSep("_", values...)
+}
+
+// ToStringKeyWithSep build a key from values joined with sep, backslashes and bytes of sep in values are escaped with backslashes,
+// so different values won't get the same key, sep shouldn't contain backslashes, values are joined with ToLengthPrefixedKey if sep is empty
+func ToStringKeyWithSep(sep string, values ...interface{}) string {
+	if sep == "" {
+		return ToLengthPrefixedKey(values...)
+	}
 
+	results := make([]string, len(values))
 	for idx, value := range values {
-		if valuer, ok := value.(driver.Valuer); ok {
-			value, _ = valuer.Value()
-		}
+		results[idx] = escapeKey(toKeyString(value), sep)
+	}
 
-		switch v := value.(type) {
-		case string:
-			results[idx] = v
-		case []byte:
-			results[idx] = string(v)
-		case uint:
-			results[idx] = strconv.FormatUint(uint64(v), 10)
-		default:
-			results[idx] = fmt.Sprint(reflect.Indirect(reflect.ValueOf(v)).Interface())
+	return strings.Join(results, sep)
+}
+
+// ToLengthPrefixedKey build a key from values, each value is prefixed with its length, e.g: "1:a3:b_c",
+// which is collision free without escaping values
+func ToLengthPrefixedKey(values ...interface{}) string {
+	var builder strings.Builder
+	for _, value := range values {
+		str := toKeyString(value)
+		builder.WriteString(strconv.Itoa(len(str)))
+		builder.WriteByte(':')
+		builder.WriteString(str)
+	}
+	return builder.String()
+}
+
+// escapeKey escapes backslashes and bytes of sep in str with backslashes
+func escapeKey(str, sep string) string {
+	for i := 0; i < len(str); i++ {
+		if str[i] == '\\' || strings.IndexByte(sep, str[i]) != -1 {
+			var builder strings.Builder
+			builder.WriteString(str[:i])
+			for ; i < len(str); i++ {
+				if str[i] == '\\' || strings.IndexByte(sep, str[i]) != -1 {
+					builder.WriteByte('\\')
+				}
+				builder.WriteByte(str[i])
+			}
+			return builder.String()
 		}
 	}
+	return str
+}
 
-	return strings.Join(results, "_")
+func toKeyString(value interface{}) string {
+	if valuer, ok := value.(driver.Valuer); ok {
+		value, _ = valuer.Value()
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case uint:
+		return strconv.FormatUint(uint64(v), 10)
+	default:
+		return fmt.Sprint(reflect.Indirect(reflect.ValueOf(v)).Interface())
+	}
+}
+
+func Contains(elems []string, elem string) bool {
+	for _, e := range elems {
+		if elem == e {
+			return true
+		}
+	}
+	return false
 }
 
 func AssertEqual(src, dst interface{}) bool {
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"math/rand"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -12,3 +14,86 @@ func TestIsValidDBNameChar(t *testing.T) {
 		}
 	}
 }
+
+func TestToStringKey(t *testing.T) {
+	cases := [][2][]interface{}{
+		{{"a_b", "c"}, {"a", "b_c"}},
+		{{`a\`, "b"}, {`a\_b`}},
+		{{"a", "_b"}, {"a_", "b"}},
+		{{`a\\`, "_"}, {`a\`, `\_`}},
+	}
+
+	for _, c := range cases {
+		if key1, key2 := ToStringKey(c[0]...), ToStringKey(c[1]...); key1 == key2 {
+			t.Errorf("%#v and %#v should have different keys, but both got %v", c[0], c[1], key1)
+		}
+	}
+
+	if key := ToStringKey("a", uint(1), 2); key != "a_1_2" {
+		t.Errorf("invalid key, got %v", key)
+	}
+}
+
+func TestToStringKeyWithSep(t *testing.T) {
+	if key := ToStringKeyWithSep("::", "a", "b:c", `d\`); key != `a::b\:c::d\\` {
+		t.Errorf("invalid key, got %v", key)
+	}
+
+	if key := ToStringKeyWithSep("", "a", "b_c"); key != "1:a3:b_c" {
+		t.Errorf("invalid length prefixed key, got %v", key)
+	}
+
+	cases := [][2][]interface{}{
+		{{":", "x"}, {"", ":x"}},
+		{{"a::", "b"}, {"a", "::b"}},
+		{{`a\`, ":b"}, {`a\:`, "b"}},
+	}
+
+	for _, c := range cases {
+		if key1, key2 := ToStringKeyWithSep("::", c[0]...), ToStringKeyWithSep("::", c[1]...); key1 == key2 {
+			t.Errorf("%#v and %#v should have different keys, but both got %v", c[0], c[1], key1)
+		}
+	}
+}
+
+func TestToStringKeyCollisions(t *testing.T) {
+	var (
+		random   = rand.New(rand.NewSource(1))
+		alphabet = []string{"a", "1", "_", ":", "|", `\`, "\xc3", "\xa9", "é"}
+	)
+
+	// keys are built from the same number of values, e.g: values of primary fields
+	randomValues := func() []interface{} {
+		values := make([]interface{}, 3)
+		for idx := range values {
+			var str strings.Builder
+			for i := random.Intn(5); i > 0; i-- {
+				str.WriteString(alphabet[random.Intn(len(alphabet))])
+			}
+			values[idx] = str.String()
+		}
+		return values
+	}
+
+	for _, sep := range []string{"_", "::", "|_", "é", ""} {
+		keys := map[string][]interface{}{}
+		for i := 0; i < 20000; i++ {
+			values := randomValues()
+			key := ToStringKeyWithSep(sep, values...)
+			if existing, ok := keys[key]; ok && !reflect.DeepEqual(existing, values) {
+				t.Fatalf("%#v and %#v should have different keys with sep %q, but both got %q", existing, values, sep, key)
+			}
+			keys[key] = values
+		}
+	}
+
+	keys := map[string][]interface{}{}
+	for i := 0; i < 20000; i++ {
+		values := randomValues()
+		key := ToLengthPrefixedKey(values...)
+		if existing, ok := keys[key]; ok && !reflect.DeepEqual(existing, values) {
+			t.Fatalf("%#v and %#v should have different length prefixed keys, but both got %q", existing, values, key)
+		}
+		keys[key] = values
+	}
+}